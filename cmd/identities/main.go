@@ -0,0 +1,164 @@
+// identities finds accounts whose emails or usernames collide once normalized,
+// meaning they differ only by case or Unicode form, and fills in the normalized
+// columns used for lookups:
+//
+//	go run ./cmd/identities collisions
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/db"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+const usage = `usage: identities <command>
+
+commands:
+  collisions  list accounts sharing a normalized email or username, exiting
+              with status 1 if there are any
+  backfill    recompute every normalized email and username; in each group of
+              colliding accounts only one keeps the normalized value, the
+              others can still sign in with their exact email or username
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	config.Init()
+	db.Init(config.App.DbConnectionString)
+	db.RunMigrations()
+
+	if err := run(repo.NewRepos(db.DB), os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repos *repo.Repos, command string) error {
+	ctx := context.Background()
+	switch command {
+	case "collisions":
+		return collisions(ctx, repos)
+	case "backfill":
+		return backfill(ctx, repos)
+	default:
+		return errors.New("unknown command " + command)
+	}
+}
+
+// group maps each normalized value to the users sharing it, oldest first.
+func group(users []model.User, normalize func(model.User) string) map[string][]model.User {
+	groups := make(map[string][]model.User)
+	for _, user := range users {
+		key := normalize(user)
+		groups[key] = append(groups[key], user)
+	}
+	return groups
+}
+
+func normalizedEmail(user model.User) string {
+	return utils.NormalizeEmail(user.Email)
+}
+
+func normalizedUsername(user model.User) string {
+	return utils.NormalizeUsername(user.Username)
+}
+
+func collisions(ctx context.Context, repos *repo.Repos) error {
+	users, err := repos.User.GetIdentities(ctx)
+	if err != nil {
+		return err
+	}
+
+	found := printCollisions("email", group(users, normalizedEmail), func(u model.User) string { return u.Email })
+	found += printCollisions("username", group(users, normalizedUsername), func(u model.User) string { return u.Username })
+
+	if found > 0 {
+		return fmt.Errorf("%d collisions found", found)
+	}
+	fmt.Println("no collisions")
+	return nil
+}
+
+func printCollisions(kind string, groups map[string][]model.User, value func(model.User) string) int {
+	keys := make([]string, 0, len(groups))
+	for key, members := range groups {
+		if len(members) > 1 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%s %q:\n", kind, key)
+		for _, user := range groups[key] {
+			fmt.Printf("  %-20d %s\n", user.ID, value(user))
+		}
+	}
+	return len(keys)
+}
+
+// owner returns the normalized value the user should hold: their own, unless
+// it belongs to another account sharing it. The account already holding the
+// value keeps it, otherwise the oldest one gets it.
+func owner(groups map[string][]model.User, key string, user model.User, held func(model.User) string) string {
+	members := groups[key]
+	first := members[0]
+	for _, member := range members {
+		if held(member) == key {
+			first = member
+			break
+		}
+	}
+	if first.ID != user.ID {
+		return ""
+	}
+	return key
+}
+
+func backfill(ctx context.Context, repos *repo.Repos) error {
+	users, err := repos.User.GetIdentities(ctx)
+	if err != nil {
+		return err
+	}
+
+	emails := group(users, normalizedEmail)
+	usernames := group(users, normalizedUsername)
+
+	var changed []model.User
+	for _, user := range users {
+		email := owner(emails, normalizedEmail(user), user, func(u model.User) string { return u.EmailNormalized })
+		username := owner(usernames, normalizedUsername(user), user, func(u model.User) string { return u.UsernameNormalized })
+		if email != user.EmailNormalized || username != user.UsernameNormalized {
+			user.EmailNormalized = email
+			user.UsernameNormalized = username
+			changed = append(changed, user)
+		}
+	}
+
+	// clear first so a value moving between accounts never exists twice
+	for _, user := range changed {
+		if err := repos.User.SetNormalizedIdentity(ctx, user.ID, "", ""); err != nil {
+			return err
+		}
+	}
+	for _, user := range changed {
+		if err := repos.User.SetNormalizedIdentity(ctx, user.ID, user.EmailNormalized, user.UsernameNormalized); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("updated %d of %d accounts\n", len(changed), len(users))
+	return nil
+}
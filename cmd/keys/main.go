@@ -0,0 +1,242 @@
+// keys manages the JWT signing keyring in JWT_KEY_DIR.
+//
+// Rotating without logging anyone out:
+//
+//	go run ./cmd/keys generate -alg ES256   # new key, published for verification only
+//	                                        # wait for JWT_KEY_RELOAD_INTERVAL and any JWKS caches
+//	go run ./cmd/keys promote <kid>         # new key signs, old key keeps verifying
+//	                                        # wait for the longest token lifetime (refresh)
+//	go run ./cmd/keys retire <old kid>      # old tokens stop validating
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/joho/godotenv"
+)
+
+var validKeyID = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+const usage = `usage: keys [-dir path] <command> [args]
+
+commands:
+  list                                   show every key and its status
+  generate -alg HS256|RS256|ES256|EdDSA  create a key (active if the ring is empty, otherwise verify-only)
+  add -alg ALG -file path [-kid id]      import an existing key file the same way
+  promote <kid>                          make a key the active signing key, demoting the current one
+  retire <kid>                           stop accepting a non-active key
+`
+
+func main() {
+	godotenv.Load()
+
+	dir := flag.String("dir", os.Getenv("JWT_KEY_DIR"), "keyring directory (defaults to JWT_KEY_DIR)")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if *dir == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*dir, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, command string, args []string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	m, err := jwt.LoadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		return list(m)
+	case "generate":
+		return generate(dir, m, args)
+	case "add":
+		return add(dir, m, args)
+	case "promote":
+		return promote(dir, m, args)
+	case "retire":
+		return retire(dir, m, args)
+	default:
+		return errors.New("unknown command " + command)
+	}
+}
+
+func list(m *jwt.Manifest) error {
+	for _, k := range m.Keys {
+		fmt.Printf("%-8s %-6s %s  (created %s)\n", k.Status, k.Algorithm, k.KeyID, time.Unix(k.CreatedAt, 0).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func generate(dir string, m *jwt.Manifest, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	alg := fs.String("alg", jwt.ES256, "signing algorithm")
+	fs.Parse(args)
+
+	key, contents, err := jwt.GenerateKey(*alg)
+	if err != nil {
+		return err
+	}
+	return store(dir, m, *alg, "", key, contents)
+}
+
+func add(dir string, m *jwt.Manifest, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	alg := fs.String("alg", "", "signing algorithm of the key")
+	file := fs.String("file", "", "key file to import")
+	kid := fs.String("kid", "", "key id (defaults to one derived from the key)")
+	fs.Parse(args)
+
+	if *alg == "" || *file == "" {
+		return errors.New("add requires -alg and -file")
+	}
+
+	key, err := jwt.LoadKeyFile(*alg, *file)
+	if err != nil {
+		return err
+	}
+
+	contents, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	return store(dir, m, *alg, *kid, key, contents)
+}
+
+// store writes the key file and adds it to the manifest. The first key becomes
+// active straight away; later ones are verify-only until promoted.
+func store(dir string, m *jwt.Manifest, alg, kid string, key any, contents []byte) error {
+	signer, err := jwt.NewSigner(alg, kid, key)
+	if err != nil {
+		return err
+	}
+
+	kid = signer.KeyID()
+	if !validKeyID.MatchString(kid) {
+		return errors.New("key id may only contain letters, digits, '.', '_' and '-'")
+	}
+	if m.Find(kid) != nil {
+		return errors.New("key " + kid + " is already in the keyring")
+	}
+
+	ext := ".pem"
+	if alg == jwt.HS256 {
+		ext = ".key"
+	}
+	file := kid + ext
+	if err := os.WriteFile(filepath.Join(dir, file), contents, 0600); err != nil {
+		return err
+	}
+
+	status := jwt.KeyVerify
+	if activeKey(m) == nil {
+		status = jwt.KeyActive
+	}
+
+	now := time.Now().UTC().Unix()
+	m.Keys = append(m.Keys, jwt.ManifestKey{
+		KeyID:     kid,
+		Algorithm: alg,
+		File:      file,
+		Status:    status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err := m.Save(dir); err != nil {
+		return err
+	}
+
+	fmt.Printf("added %s key %s as %s\n", alg, kid, status)
+	return nil
+}
+
+func promote(dir string, m *jwt.Manifest, args []string) error {
+	key, err := findArg(m, args)
+	if err != nil {
+		return err
+	}
+
+	switch key.Status {
+	case jwt.KeyActive:
+		return errors.New("key " + key.KeyID + " is already active")
+	case jwt.KeyRetired:
+		return errors.New("key " + key.KeyID + " is retired and cannot be promoted")
+	}
+
+	now := time.Now().UTC().Unix()
+	if current := activeKey(m); current != nil {
+		current.Status = jwt.KeyVerify
+		current.UpdatedAt = now
+		fmt.Printf("demoted %s to verify\n", current.KeyID)
+	}
+
+	key.Status = jwt.KeyActive
+	key.UpdatedAt = now
+	if err := m.Save(dir); err != nil {
+		return err
+	}
+
+	fmt.Printf("promoted %s to active\n", key.KeyID)
+	return nil
+}
+
+func retire(dir string, m *jwt.Manifest, args []string) error {
+	key, err := findArg(m, args)
+	if err != nil {
+		return err
+	}
+
+	switch key.Status {
+	case jwt.KeyActive:
+		return errors.New("key " + key.KeyID + " is active, promote another key first")
+	case jwt.KeyRetired:
+		return errors.New("key " + key.KeyID + " is already retired")
+	}
+
+	key.Status = jwt.KeyRetired
+	key.UpdatedAt = time.Now().UTC().Unix()
+	if err := m.Save(dir); err != nil {
+		return err
+	}
+
+	fmt.Printf("retired %s, tokens it signed are no longer accepted\n", key.KeyID)
+	return nil
+}
+
+func findArg(m *jwt.Manifest, args []string) (*jwt.ManifestKey, error) {
+	if len(args) != 1 {
+		return nil, errors.New("expected a single key id")
+	}
+
+	key := m.Find(args[0])
+	if key == nil {
+		return nil, errors.New("no key " + args[0] + " in the keyring")
+	}
+	return key, nil
+}
+
+func activeKey(m *jwt.Manifest) *jwt.ManifestKey {
+	for i := range m.Keys {
+		if m.Keys[i].Status == jwt.KeyActive {
+			return &m.Keys[i]
+		}
+	}
+	return nil
+}
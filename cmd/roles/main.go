@@ -0,0 +1,99 @@
+// roles lists roles and assigns them to users straight from the database, which
+// is how the first admin gets created:
+//
+//	go run ./cmd/roles assign john@example.com admin
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/db"
+	"github.com/akramboussanni/gocode/internal/repo"
+)
+
+const usage = `usage: roles <command> [args]
+
+commands:
+  list                  show every role and its permissions
+  assign <email> <role> give a user a role
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	config.Init()
+	db.Init(config.App.DbConnectionString)
+	db.RunMigrations()
+
+	if err := run(repo.NewRepos(db.DB), os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(repos *repo.Repos, command string, args []string) error {
+	ctx := context.Background()
+	switch command {
+	case "list":
+		return list(ctx, repos)
+	case "assign":
+		return assign(ctx, repos, args)
+	default:
+		return errors.New("unknown command " + command)
+	}
+}
+
+func list(ctx context.Context, repos *repo.Repos) error {
+	roles, err := repos.Role.GetRoles(ctx)
+	if err != nil {
+		return err
+	}
+
+	perms, err := repos.Role.GetAllPermissions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		fmt.Printf("%-12s %s\n", role.Name, strings.Join(perms[role.Name], " "))
+	}
+	return nil
+}
+
+func assign(ctx context.Context, repos *repo.Repos, args []string) error {
+	if len(args) != 2 {
+		return errors.New("assign requires an email and a role")
+	}
+
+	exists, err := repos.Role.RoleExists(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("no role " + args[1])
+	}
+
+	user, err := repos.User.GetUserByEmail(ctx, args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("no user with email " + args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := repos.User.ChangeRole(ctx, user.ID, args[1]); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now %s, effective from their next sign-in or token refresh\n", user.Email, args[1])
+	return nil
+}
@@ -19,6 +19,11 @@
 // @name session
 // @description JWT session cookie for authenticated endpoints. Automatically set by login endpoint. Required for endpoints marked with @Security CookieAuth.
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Credential token as "Bearer <token>", for clients that cannot use cookies. Obtain tokens by sending X-Token-Delivery: body to the login endpoints. Accepted wherever CookieAuth is.
+
 // @securityDefinitions.apikey RecaptchaToken
 // @in header
 // @name X-Recaptcha-Token
@@ -30,12 +35,36 @@
 // @tag.name Account
 // @tag.description User profile and account management endpoints. All endpoints require session cookie authentication.
 
+// @tag.name Multi-Factor Authentication
+// @tag.description TOTP enrollment and the second login step. Enrollment endpoints require session cookie authentication and password re-entry.
+
+// @tag.name Passkeys
+// @tag.description WebAuthn passkey registration, management and passwordless login. Registration and management endpoints require session cookie authentication.
+
+// @tag.name Social Login
+// @tag.description Sign-in with external OpenID Connect providers such as Google, configured through OIDC_PROVIDERS.
+
+// @tag.name OAuth
+// @tag.description OAuth 2.0 and OpenID Connect provider for other apps to sign users in through this server. Apps are registered under /admin/clients.
+
+// @tag.name Sessions
+// @tag.description Per-device session listing and revocation. All endpoints require session cookie authentication.
+
+// @tag.name API Keys
+// @tag.description Long-lived personal API keys with limited scopes. Management endpoints require session cookie authentication.
+
 // @tag.name Email Verification
 // @tag.description Email confirmation and verification endpoints. reCAPTCHA verification is optional if configured.
 
 // @tag.name Password Management
 // @tag.description Password reset, change, and recovery endpoints. Public endpoints have optional reCAPTCHA, authenticated endpoints require session cookie.
 
+// @tag.name Admin
+// @tag.description Account and role management for operators. Every endpoint requires session authentication and a specific permission.
+
+// @tag.name Well-Known
+// @tag.description Public discovery documents, such as the keys for verifying session tokens and the OpenID Connect provider metadata.
+
 package main
 
 import (
@@ -52,13 +81,24 @@ import (
 	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/api/routes"
 	"github.com/akramboussanni/gocode/internal/db"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/oidc"
 	"github.com/akramboussanni/gocode/internal/repo"
 	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/akramboussanni/gocode/internal/worker"
 )
 
 func main() {
 	config.Init()
 
+	if err := jwt.Init(); err != nil {
+		log.Fatalf("failed to initialize jwt signing: %v", err)
+	}
+
+	if err := oidc.Init(config.App.OidcProviders); err != nil {
+		log.Fatalf("failed to load oidc providers: %v", err)
+	}
+
 	err := utils.InitSnowflake(1)
 	if err != nil {
 		panic(err)
@@ -68,6 +108,18 @@ func main() {
 	db.RunMigrations()
 
 	repos := repo.NewRepos(db.DB)
+
+	// normalization needs Unicode rules SQL lacks, so accounts from before the
+	// normalized columns, or created by an older instance, are filled in here
+	if n, err := repos.User.FillNormalizedIdentities(context.Background()); err != nil {
+		log.Printf("failed to normalize emails and usernames: %v", err)
+	} else if n > 0 {
+		log.Printf("normalized the email and username of %d accounts", n)
+	}
+
+	go worker.DeleteExpiredAccounts(repos.User, time.Duration(config.App.AccountDeletionInterval)*time.Second)
+	go worker.PurgeExpiredExports(repos.Export, time.Hour)
+
 	r := routes.SetupRouter(repos)
 
 	port := strconv.Itoa(config.App.AppPort)
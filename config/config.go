@@ -12,6 +12,7 @@ import (
 type AppConfig struct {
 	AppPort            int    `env:"APP_PORT" default:"9520"`
 	JwtSecret          string `env:"JWT_SECRET" panic:"true"`
+	CodeSecret         string `env:"CODE_SECRET"` // keys stored code hashes, kept when JWT_SECRET rotates
 	DbConnectionString string `env:"DB_CONNECTION_STRING" panic:"warn"`
 	TrustIpHeaders     bool   `env:"TRUST_PROXY_IP_HEADERS" default:"false"`
 
@@ -20,6 +21,7 @@ type AppConfig struct {
 	FailedLoginBacktrack int64 `env:"FAILED_LOGIN_BACKTRACK" default:"1800"` // sec (30min)
 	ForgotPasswordExpiry int64 `env:"FORGOT_PASSWORD_EXPIRY" default:"3600"` // sec (1h)
 	EmailConfirmExpiry   int64 `env:"EMAIL_CONFIRM_EXPIRY" default:"86400"`  // sec (24h)
+	EmailChangeExpiry    int64 `env:"EMAIL_CHANGE_EXPIRY" default:"86400"`   // sec (24h)
 
 	RecaptchaEnabled   bool    `env:"RECAPTCHA_V3_ENABLED" default:"false"`
 	RecaptchaSecret    string  `env:"RECAPTCHA_V3_SECRET"`
@@ -32,17 +34,114 @@ type AppConfig struct {
 	TLSCertFile string `env:"TLS_CERT_FILE"`
 	TLSKeyFile  string `env:"TLS_KEY_FILE"`
 
-	JwtExpirations map[string]int64 `env:"JWT_EXPIRATIONS" default:"{\"credential\":900,\"refresh\":129600}"` // 15min, 36h
+	TotpIssuer        string `env:"TOTP_ISSUER"`
+	RecoveryCodeCount int    `env:"RECOVERY_CODE_COUNT" default:"10"`
+
+	WebAuthnRPID    string `env:"WEBAUTHN_RP_ID"`
+	WebAuthnRPName  string `env:"WEBAUTHN_RP_NAME"`
+	WebAuthnOrigins string `env:"WEBAUTHN_RP_ORIGINS"`            // comma separated
+	WebAuthnTimeout int64  `env:"WEBAUTHN_TIMEOUT" default:"300"` // sec (5min)
+
+	JwtAlgorithm      string `env:"JWT_ALGORITHM"`        // HS256, RS256, ES256 or EdDSA
+	JwtPrivateKeyFile string `env:"JWT_PRIVATE_KEY_FILE"` // PEM private key, required unless HS256
+	JwtKeyID          string `env:"JWT_KEY_ID"`           // defaults to one derived from the key
+
+	JwtKeyDir            string `env:"JWT_KEY_DIR"`                          // keyring directory, replaces the single key settings above
+	JwtKeyReloadInterval int64  `env:"JWT_KEY_RELOAD_INTERVAL" default:"30"` // sec
+
+	TokenDelivery string `env:"TOKEN_DELIVERY"` // cookie, body or both; clients can override per request with X-Token-Delivery
+
+	LoginIdentifier string `env:"LOGIN_IDENTIFIER"` // email, username or both
+
+	MagicLinkEnabled bool  `env:"MAGIC_LINK_ENABLED" default:"true"`
+	MagicLinkExpiry  int64 `env:"MAGIC_LINK_EXPIRY" default:"900"` // sec (15min)
+
+	EmailCodeLoginEnabled bool  `env:"EMAIL_CODE_LOGIN_ENABLED" default:"true"`
+	EmailCodeExpiry       int64 `env:"EMAIL_CODE_EXPIRY" default:"600"`     // sec (10min)
+	EmailCodeMaxAttempts  int   `env:"EMAIL_CODE_MAX_ATTEMPTS" default:"5"` // wrong codes before a code stops working
+
+	OidcProviders    string `env:"OIDC_PROVIDERS"`                   // JSON array of providers, see README
+	OidcStateTimeout int64  `env:"OIDC_STATE_TIMEOUT" default:"600"` // sec (10min) to complete a provider login
+
+	OAuthIssuer             string `env:"OAUTH_ISSUER"`                                 // public base URL of this server, as other apps reach it
+	OAuthConsentURL         string `env:"OAUTH_CONSENT_URL"`                            // frontend page that signs the user in and asks for consent
+	OAuthCodeExpiry         int64  `env:"OAUTH_CODE_EXPIRY" default:"60"`               // sec for a client to redeem an authorization code
+	OAuthAccessTokenExpiry  int64  `env:"OAUTH_ACCESS_TOKEN_EXPIRY" default:"3600"`     // sec (1h), also used for ID tokens
+	OAuthRefreshTokenExpiry int64  `env:"OAUTH_REFRESH_TOKEN_EXPIRY" default:"2592000"` // sec (30d)
+	OAuthDeviceURL          string `env:"OAUTH_DEVICE_URL"`                             // frontend page where users type the code shown by a device
+	OAuthDeviceCodeExpiry   int64  `env:"OAUTH_DEVICE_CODE_EXPIRY" default:"600"`       // sec (10min) for the user to approve a device
+	OAuthDevicePollInterval int64  `env:"OAUTH_DEVICE_POLL_INTERVAL" default:"5"`       // sec devices must wait between polls
+
+	AccountDeletionGrace    int64 `env:"ACCOUNT_DELETION_GRACE" default:"2592000"` // sec (30d) before a deletion request is carried out
+	AccountDeletionInterval int64 `env:"ACCOUNT_DELETION_INTERVAL" default:"3600"` // sec between checks for accounts due for deletion
+
+	UsernameChangeCooldown int64 `env:"USERNAME_CHANGE_COOLDOWN" default:"2592000"` // sec (30d) between username changes
+	ProfileMetadataMaxSize int   `env:"PROFILE_METADATA_MAX_SIZE" default:"4096"`   // bytes of JSON per user
+
+	DataExportInlineLimit int   `env:"DATA_EXPORT_INLINE_LIMIT" default:"1000"` // records; larger exports are generated in the background and emailed
+	DataExportExpiry      int64 `env:"DATA_EXPORT_EXPIRY" default:"172800"`     // sec (48h) an emailed export can be downloaded for
+
+	ApiKeyLimit       int   `env:"API_KEY_LIMIT" default:"25"`       // per user
+	ApiKeyMaxLifetime int64 `env:"API_KEY_MAX_LIFETIME" default:"0"` // sec, 0 allows keys that never expire
+
+	RefreshReuseRevokeAll bool `env:"REFRESH_REUSE_REVOKE_ALL" default:"false"` // sign out every session when a rotated refresh token is replayed
+
+	JwtExpirations map[string]int64 `env:"JWT_EXPIRATIONS" default:"{\"credential\":900,\"refresh\":129600,\"mfa\":300}"` // 15min, 36h, 5min
 }
 
 var App AppConfig
+
+// jwtExpiryFallbacks are lifetimes for token types added after JWT_EXPIRATIONS,
+// used when a deployment's value does not mention them.
+var jwtExpiryFallbacks = map[string]int64{
+	"mfa": 300, // 5min
+}
+
+// stringDefaults are the values of string settings left unset. The loader only
+// applies default tags to other kinds of fields, where an unset variable would
+// otherwise fail to parse.
+func stringDefaults() map[*string]string {
+	return map[*string]string{
+		&App.TotpIssuer:      "gocode",
+		&App.WebAuthnRPID:    "localhost",
+		&App.WebAuthnRPName:  "gocode",
+		&App.WebAuthnOrigins: "http://localhost:9520",
+		&App.JwtAlgorithm:    "HS256",
+		&App.TokenDelivery:   "cookie",
+		&App.LoginIdentifier: "both",
+		&App.OAuthIssuer:     "http://localhost:9520",
+		&App.OAuthConsentURL: "http://localhost:3000/consent",
+		&App.OAuthDeviceURL:  "http://localhost:3000/device",
+	}
+}
+
 var JwtSecretBytes []byte
 
+// CodeSecretBytes keys the hashes of recovery, email and device codes. It must
+// outlive JWT_SECRET, since changing it invalidates every stored code.
+var CodeSecretBytes []byte
+
 func Init() {
 	godotenv.Load()
 
 	App = DeconstructConfigObject[AppConfig]()
 
+	for field, value := range stringDefaults() {
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	// JWT_EXPIRATIONS values written before a token type existed leave it out
+	for typ, expiry := range jwtExpiryFallbacks {
+		if _, ok := App.JwtExpirations[typ]; !ok {
+			if App.JwtExpirations == nil {
+				App.JwtExpirations = map[string]int64{}
+			}
+			App.JwtExpirations[typ] = expiry
+		}
+	}
+
 	var err error
 	JwtSecretBytes, err = base64.StdEncoding.DecodeString(App.JwtSecret)
 	if err != nil {
@@ -53,6 +152,19 @@ func Init() {
 		panic("JWT_SECRET must be at least 32 bytes when decoded")
 	}
 
+	CodeSecretBytes = JwtSecretBytes
+	if App.CodeSecret == "" {
+		log.Println("CODE_SECRET is not set, code hashes are keyed with JWT_SECRET and rotating it invalidates stored recovery codes")
+	} else {
+		CodeSecretBytes, err = base64.StdEncoding.DecodeString(App.CodeSecret)
+		if err != nil {
+			panic("invalid CODE_SECRET: " + err.Error())
+		}
+		if len(CodeSecretBytes) < 32 {
+			panic("CODE_SECRET must be at least 32 bytes when decoded")
+		}
+	}
+
 	// services
 	if err := mailer.Init(DeconstructConfigObject[mailer.MailerConfig]()); err != nil {
 		log.Fatalf("Failed to initialize mailer: %v", err)
@@ -0,0 +1,274 @@
+// Package apitest runs the API routes against a throwaway database for tests,
+// with clients that keep cookies like a browser.
+package apitest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/mailer"
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// Password is the password Register gives every account.
+const Password = "SecurePass123"
+
+// Init loads a test configuration and the services the routes depend on. Call
+// it from TestMain.
+func Init() {
+	os.Setenv("JWT_SECRET", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("x"), 32)))
+	os.Setenv("MAILER_TYPE", "mock")
+	os.Setenv("MAILER_PORT", "25")
+	os.Setenv("LOGGER_TYPE", "std")
+	config.Init()
+
+	if err := jwt.Init(); err != nil {
+		panic(err)
+	}
+	if err := utils.InitSnowflake(1); err != nil {
+		panic(err)
+	}
+}
+
+// UseKey signs tokens with a new alg key for the rest of the test, then goes
+// back to the HS256 secret Init set up.
+func UseKey(t *testing.T, alg string) {
+	t.Helper()
+
+	_, pem, err := jwt.GenerateKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt-key.pem")
+	if err := os.WriteFile(path, pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config.App.JwtAlgorithm, config.App.JwtPrivateKeyFile = alg, path
+	if err := jwt.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		config.App.JwtAlgorithm, config.App.JwtPrivateKeyFile = jwt.HS256, ""
+		if err := jwt.Init(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// Server runs routes over TLS, since session cookies are Secure, against a
+// fresh SQLite database with every migration applied.
+type Server struct {
+	*httptest.Server
+	DB    *sqlx.DB
+	Repos *repo.Repos
+
+	emails int // emails sent before the server started, left to other tests
+}
+
+// NewServer serves the routes mount adds, for the length of the test.
+func NewServer(t *testing.T, mount func(r chi.Router, repos *repo.Repos)) *Server {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	_, file, _, _ := runtime.Caller(0)
+	migrations, err := filepath.Glob(filepath.Join(filepath.Dir(file), "..", "..", "db", "migrations", "*.up.sql"))
+	if err != nil || len(migrations) == 0 {
+		t.Fatal("no migrations found", err)
+	}
+	sort.Strings(migrations)
+	for _, path := range migrations {
+		sql, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(sql)); err != nil {
+			t.Fatalf("migration %s: %v", filepath.Base(path), err)
+		}
+	}
+
+	repos := repo.NewRepos(db)
+	r := chi.NewRouter()
+	mount(r, repos)
+
+	srv := httptest.NewTLSServer(r)
+	t.Cleanup(srv.Close)
+	server := &Server{Server: srv, DB: db, Repos: repos}
+	if mock, ok := mailer.Mock(); ok {
+		server.emails = len(mock.GetSentEmails())
+	}
+	return server
+}
+
+// UserID returns the id of the account registered with email.
+func (s *Server) UserID(t *testing.T, email string) int64 {
+	t.Helper()
+	var id int64
+	if err := s.DB.Get(&id, "SELECT id FROM users WHERE email = $1", email); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// Client is a browser stand-in that keeps the cookies the server sets.
+type Client struct {
+	T      *testing.T
+	Server *Server
+	HTTP   *http.Client
+	Header http.Header // sent with every request
+}
+
+// NewClient returns a client with its own cookie jar.
+func (s *Server) NewClient(t *testing.T) *Client {
+	jar, _ := cookiejar.New(nil)
+	c := &http.Client{
+		Transport:     s.Client().Transport,
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &Client{T: t, Server: s, HTTP: c, Header: http.Header{}}
+}
+
+// Cookie returns the value of the cookie the client would send to path.
+func (c *Client) Cookie(path, name string) string {
+	u, _ := url.Parse(c.Server.URL + path)
+	for _, cookie := range c.HTTP.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// Do sends body as JSON, or as is when it is a []byte, and decodes a JSON
+// response into out, if given.
+func (c *Client) Do(method, path string, body, out any) int {
+	c.T.Helper()
+	resp := c.Send(method, path, "application/json", body)
+	defer resp.Body.Close()
+
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// Form posts values as a form, the way OAuth clients call token endpoints, and
+// decodes a JSON response into out, if given.
+func (c *Client) Form(path string, values url.Values, out any) int {
+	c.T.Helper()
+	resp := c.Send("POST", path, "application/x-www-form-urlencoded", []byte(values.Encode()))
+	defer resp.Body.Close()
+
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// Send makes a request and returns the response for the caller to close.
+func (c *Client) Send(method, path, contentType string, body any) *http.Response {
+	c.T.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			c.T.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequest(method, c.Server.URL+path, reader)
+	if err != nil {
+		c.T.Fatal(err)
+	}
+	req.Header = c.Header.Clone()
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		c.T.Fatal(err)
+	}
+	return resp
+}
+
+// Register creates a user with a confirmed email and signs the client in.
+// The auth routes must be mounted at /auth.
+func (c *Client) Register(username, email string) {
+	c.T.Helper()
+
+	status := c.Do("POST", "/auth/register", map[string]string{"username": username, "email": email, "password": Password, "url": "https://example.com/confirm"}, nil)
+	if status != 200 {
+		c.T.Fatalf("register %s: status %d", username, status)
+	}
+
+	if _, err := c.Server.DB.Exec("UPDATE users SET email_confirmed = true WHERE email = $1", email); err != nil {
+		c.T.Fatal(err)
+	}
+
+	c.Login(username)
+}
+
+// Login signs the client in with the password Register sets.
+func (c *Client) Login(identifier string) {
+	c.T.Helper()
+	if status := c.Do("POST", "/auth/login", map[string]string{"identifier": identifier, "password": Password}, nil); status != 200 {
+		c.T.Fatalf("login %s: status %d", identifier, status)
+	}
+}
+
+// Email waits for the latest email with template tmpl sent to address since
+// the test started, and returns the data its template was rendered with.
+func (s *Server) Email(t *testing.T, tmpl, address string) map[string]any {
+	t.Helper()
+
+	mock, ok := mailer.Mock()
+	if !ok {
+		t.Fatal("the mock mailer is not configured")
+	}
+
+	// emails are often sent in the background
+	for range 100 {
+		sent := mock.GetSentEmails()
+		for i := len(sent) - 1; i >= s.emails; i-- {
+			if sent[i].Template == tmpl && slices.Contains(sent[i].To, address) {
+				data, _ := sent[i].Data.(map[string]any)
+				return data
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("no %s email sent to %s", tmpl, address)
+	return nil
+}
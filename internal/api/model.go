@@ -10,6 +10,19 @@ type ErrorResponse struct {
 	Error string `json:"error" example:"Invalid request format" description:"Error message describing what went wrong"`
 }
 
+// @Description Response when the account is suspended
+type AccountSuspendedResponse struct {
+	Error  string `json:"error" example:"account suspended" description:"Error message"`
+	Reason string `json:"reason,omitempty" example:"spam" description:"Reason given for the suspension"`
+	Until  int64  `json:"until,omitempty" example:"1640995200" description:"When the suspension ends, absent when it is indefinite"`
+}
+
+// @Description Response when the account is scheduled for deletion
+type AccountPendingDeletionResponse struct {
+	Error    string `json:"error" example:"account scheduled for deletion" description:"Error message"`
+	DeleteAt int64  `json:"delete_at" example:"1640995200" description:"When the account will be deleted; use the link from the deletion email to cancel before then"`
+}
+
 // @Description Rate limit exceeded response
 type RateLimitResponse struct {
 	Error      string `json:"error" example:"Rate limit exceeded" description:"Rate limit error message"`
@@ -0,0 +1,30 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// audit records an admin action against a user in their security events, so
+// every change can be traced back to the admin who made it. Failures are only
+// logged since the action itself already happened.
+func (ar *AdminRouter) audit(r *http.Request, admin *model.User, userID int64, event model.SecurityEventType, details string) {
+	err := ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    userID,
+		Type:      event,
+		IPAddress: utils.GetClientIP(r),
+		Details:   details,
+		CreatedAt: time.Now().UTC().Unix(),
+		ActorID:   admin.ID,
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	applog.Info("Admin action", "event:", event, "userID:", userID, "adminID:", admin.ID)
+}
@@ -0,0 +1,249 @@
+package admin
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary List OAuth clients
+// @Description List the apps registered to sign users in through this server. Requires the clients:read permission.
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {array} ClientResponse "Registered clients"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the clients:read permission"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/clients [get]
+func (ar *AdminRouter) HandleListClients(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListClients called")
+	clients, err := ar.OAuthRepo.GetClients(r.Context())
+	if err != nil {
+		applog.Error("Failed to list oauth clients:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := make([]ClientResponse, 0, len(clients))
+	for _, c := range clients {
+		resp = append(resp, ClientResponse{OAuthClient: c, Public: c.IsPublic()})
+	}
+
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Register an OAuth client
+// @Description Register an app that signs users in through this server. Confidential clients get a secret, returned once and never again. The openid scope needs an RS256, ES256 or EdDSA signing key. Requires the clients:write permission. Recorded in the admin's own security events.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body ClientCreateRequest true "Client settings"
+// @Success 201 {object} ClientCreatedResponse "Registered client, including its secret"
+// @Failure 400 {object} api.ErrorResponse "Invalid name, redirect URIs, scopes or grant types"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the clients:write permission"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/clients [post]
+func (ar *AdminRouter) HandleCreateClient(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleCreateClient called")
+	req, err := api.DecodeJSON[ClientCreateRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode client request:", err)
+		return
+	}
+
+	admin, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		api.WriteMessage(w, 400, "error", "invalid name")
+		return
+	}
+
+	grants, ok := uniqueIn(req.GrantTypes, model.OAuthGrantTypes)
+	if !ok || len(grants) == 0 {
+		api.WriteMessage(w, 400, "error", "grant_types must be among "+strings.Join(model.OAuthGrantTypes, ", "))
+		return
+	}
+
+	usesCode := slices.Contains(grants, model.GrantAuthorizationCode)
+	signsUsersIn := usesCode || slices.Contains(grants, model.GrantDeviceCode)
+	if slices.Contains(grants, model.GrantRefreshToken) && !signsUsersIn {
+		api.WriteMessage(w, 400, "error", "refresh_token requires authorization_code or the device code grant")
+		return
+	}
+	if req.Public && slices.Contains(grants, model.GrantClientCredentials) {
+		api.WriteMessage(w, 400, "error", "public clients cannot use client_credentials")
+		return
+	}
+
+	scopes, ok := uniqueIn(req.Scopes, model.OAuthScopes)
+	if !ok {
+		api.WriteMessage(w, 400, "error", "scopes must be among "+strings.Join(model.OAuthScopes, ", "))
+		return
+	}
+	if slices.Contains(scopes, model.ScopeOpenID) && !jwt.CanSignPublic() {
+		api.WriteMessage(w, 400, "error", "openid needs JWT_ALGORITHM to be RS256, ES256 or EdDSA, since HS256 ID tokens cannot be verified by clients")
+		return
+	}
+
+	redirectURIs, _ := uniqueIn(req.RedirectURIs, nil)
+	for _, uri := range redirectURIs {
+		if !validRedirectURI(uri) {
+			api.WriteMessage(w, 400, "error", "invalid redirect uri "+uri)
+			return
+		}
+	}
+	if usesCode != (len(redirectURIs) > 0) {
+		api.WriteMessage(w, 400, "error", "redirect_uris are required with authorization_code, and only then")
+		return
+	}
+	if signsUsersIn != (len(scopes) > 0) {
+		api.WriteMessage(w, 400, "error", "scopes are required with authorization_code or the device code grant, and only then")
+		return
+	}
+
+	id, err := utils.GetRandomToken(12)
+	if err != nil {
+		applog.Error("Failed to generate client id:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	client := model.OAuthClient{
+		ID:           id.Raw,
+		Name:         name,
+		RedirectURIs: strings.Join(redirectURIs, " "),
+		Scopes:       strings.Join(scopes, " "),
+		GrantTypes:   strings.Join(grants, " "),
+		CreatedBy:    admin.ID,
+		CreatedAt:    time.Now().UTC().Unix(),
+	}
+
+	var secret string
+	if !req.Public {
+		token, err := utils.GetRandomToken(32)
+		if err != nil {
+			applog.Error("Failed to generate client secret:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		secret = token.Raw
+		client.SecretHash = token.Hash
+	}
+
+	if err := ar.OAuthRepo.CreateClient(r.Context(), &client); err != nil {
+		applog.Error("Failed to store oauth client:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, admin.ID, model.OAuthClientCreatedEvent, client.ID+" "+client.Name)
+	api.WriteJSON(w, 201, ClientCreatedResponse{
+		ClientResponse: ClientResponse{OAuthClient: client, Public: req.Public},
+		ClientSecret:   secret,
+	})
+}
+
+// @Summary Delete an OAuth client
+// @Description Delete a registered app. Every session it was authorized for is revoked, so its access and refresh tokens stop working, and the consents users gave it are forgotten. Requires the clients:write permission. Recorded in the admin's own security events.
+// @Tags Admin
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path string true "Client ID"
+// @Success 200 {object} api.SuccessResponse "Client deleted"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the clients:write permission"
+// @Failure 404 {object} api.ErrorResponse "Client not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/clients/{id} [delete]
+func (ar *AdminRouter) HandleDeleteClient(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleDeleteClient called")
+	admin, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	deleted, err := ar.OAuthRepo.DeleteClient(r.Context(), id)
+	if err != nil {
+		applog.Error("Failed to delete oauth client:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "client not found")
+		return
+	}
+
+	if err := ar.SessionRepo.RevokeClientSessions(r.Context(), id); err != nil {
+		applog.Error("Failed to revoke client sessions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, admin.ID, model.OAuthClientDeletedEvent, id)
+	api.WriteMessage(w, 200, "message", "client deleted")
+}
+
+// uniqueIn returns values without duplicates, reporting false if one is not in
+// allowed. A nil allowed accepts anything.
+func uniqueIn(values, allowed []string) ([]string, bool) {
+	unique := []string{}
+	for _, v := range values {
+		if allowed != nil && !slices.Contains(allowed, v) {
+			return nil, false
+		}
+		if !slices.Contains(unique, v) {
+			unique = append(unique, v)
+		}
+	}
+	return unique, true
+}
+
+// validRedirectURI accepts absolute URIs without a fragment (RFC 6749 section
+// 3.1.2) that use https, http on a loopback host, or a private-use scheme in
+// reverse domain form for native apps (RFC 8252 section 7.1).
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(raw, " #") {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return u.Host != ""
+	case "http":
+		host := u.Hostname()
+		ip := net.ParseIP(host)
+		return host == "localhost" || (ip != nil && ip.IsLoopback())
+	default:
+		return strings.Contains(u.Scheme, ".")
+	}
+}
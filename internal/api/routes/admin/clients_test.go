@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/jwt"
+)
+
+func TestCreateClientOpenIDNeedsPublishedKey(t *testing.T) {
+	srv := newTestServer(t)
+	admin := newAdmin(t, srv)
+
+	req := ClientCreateRequest{
+		Name:         "Wiki",
+		RedirectURIs: []string{"https://wiki.example.com/callback"},
+		Scopes:       []string{"openid", "profile"},
+		GrantTypes:   []string{"authorization_code"},
+	}
+	if status := admin.Do("POST", "/admin/clients", req, nil); status != 400 {
+		t.Fatalf("openid with an HS256 key: want 400, got %d", status)
+	}
+
+	req.Scopes = []string{"profile"}
+	var created ClientCreatedResponse
+	if status := admin.Do("POST", "/admin/clients", req, &created); status != 201 || created.ClientSecret == "" {
+		t.Fatalf("client without openid: status %d", status)
+	}
+
+	// the session token was signed with the old key
+	apitest.UseKey(t, jwt.EdDSA)
+	admin.Login("root")
+
+	req.Scopes = []string{"openid", "profile"}
+	if status := admin.Do("POST", "/admin/clients", req, nil); status != 201 {
+		t.Fatalf("openid with an EdDSA key: want 201, got %d", status)
+	}
+}
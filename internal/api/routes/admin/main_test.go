@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"os"
+	"testing"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/api/routes/auth"
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMain(m *testing.M) {
+	apitest.Init()
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T) *apitest.Server {
+	return apitest.NewServer(t, func(r chi.Router, repos *repo.Repos) {
+		r.Mount("/auth", auth.NewAuthRouter(repos.User, repos.Token, repos.Lockout, repos.Recovery, repos.Passkey, repos.Security, repos.Session, repos.ApiKey, repos.Role, repos.Export, repos.Code, repos.Identity, repos.OAuth))
+		r.Mount("/admin", NewAdminRouter(repos.User, repos.Token, repos.Session, repos.Lockout, repos.Role, repos.Security, repos.OAuth))
+	})
+}
+
+// newAdmin registers an account with the admin role and signs it in, so its
+// session carries the role's permissions.
+func newAdmin(t *testing.T, srv *apitest.Server) *apitest.Client {
+	t.Helper()
+	c := srv.NewClient(t)
+	c.Register("root", "root@example.com")
+	srv.DB.MustExec("UPDATE users SET user_role = 'admin' WHERE email = 'root@example.com'")
+	c.Login("root")
+	return c
+}
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+)
+
+// @Description Role together with the permissions it grants
+type RoleResponse struct {
+	model.Role
+	Permissions []string `json:"permissions" example:"users:read,roles:read" description:"Permissions granted to users with this role"`
+}
+
+// @Description Role assignment request
+type RoleAssignRequest struct {
+	Role string `json:"role" example:"admin" binding:"required" description:"Name of an existing role"`
+}
+
+// @Description User account as seen by an administrator
+type UserResponse struct {
+	ID             int64  `json:"id" example:"123456789"`
+	Username       string `json:"username" example:"johndoe"`
+	Email          string `json:"email" example:"john@example.com"`
+	Role           string `json:"role" example:"user"`
+	CreatedAt      int64  `json:"created_at" example:"1640995200"`
+	EmailConfirmed bool   `json:"email_confirmed" example:"true"`
+	MfaEnabled     bool   `json:"mfa_enabled" example:"false"`
+	Status         string `json:"status" example:"suspended" description:"active, suspended or pending_deletion; a suspension whose end time has passed reports active"`
+	StatusReason   string `json:"status_reason,omitempty" example:"spam"`
+	StatusUntil    int64  `json:"status_until,omitempty" example:"1640995200" description:"End of the suspension, absent when indefinite, or when a pending deletion is carried out"`
+}
+
+func newUserResponse(u *model.User) UserResponse {
+	resp := UserResponse{
+		ID:             u.ID,
+		Username:       u.Username,
+		Email:          u.Email,
+		Role:           u.Role,
+		CreatedAt:      u.CreatedAt,
+		EmailConfirmed: u.EmailConfirmed,
+		MfaEnabled:     u.TotpEnabled,
+	}
+	if u.IsPendingDeletion() {
+		resp.Status = string(model.StatusPendingDeletion)
+		resp.StatusUntil = u.StatusUntil
+	} else if u.IsSuspended(time.Now().UTC().Unix()) {
+		resp.Status = string(model.StatusSuspended)
+		resp.StatusReason = u.StatusReason
+		resp.StatusUntil = u.StatusUntil
+	} else {
+		resp.Status = string(model.StatusActive)
+	}
+	return resp
+}
+
+// @Description One page of users
+type UserListResponse struct {
+	Users []UserResponse `json:"users"`
+	Total int            `json:"total" example:"42" description:"Number of users matching the filters across all pages"`
+	Page  int            `json:"page" example:"1"`
+	Limit int            `json:"limit" example:"20"`
+}
+
+// @Description Suspension request
+type SuspendRequest struct {
+	Reason string `json:"reason" example:"spam" maxLength:"255" description:"Optional reason, shown to the user when they try to sign in"`
+	Until  int64  `json:"until" example:"1640995200" description:"Unix time the suspension ends, 0 or absent to suspend indefinitely"`
+}
+
+// @Description Reason recorded in the audit trail
+type ReasonRequest struct {
+	Reason string `json:"reason" example:"spam" maxLength:"255" description:"Optional reason, kept in the user's security events"`
+}
+
+// @Description Forced password reset request
+type ResetPasswordRequest struct {
+	Url string `json:"url" example:"https://example.com/reset" format:"uri" description:"URL for the reset email template"`
+}
+
+// @Description OAuth client registration request
+type ClientCreateRequest struct {
+	Name         string   `json:"name" example:"Wiki" binding:"required" maxLength:"64" description:"Name shown to users on the consent page"`
+	RedirectURIs []string `json:"redirect_uris" example:"https://wiki.example.com/callback" description:"Exact URIs codes may be sent to, required with authorization_code. https, http on loopback hosts, or a private-use scheme such as com.example.app for native apps"`
+	Scopes       []string `json:"scopes" example:"openid,profile,email" description:"Scopes the client may request, among openid, profile and email, required with authorization_code or the device code grant"`
+	GrantTypes   []string `json:"grant_types" example:"authorization_code,refresh_token" binding:"required" description:"authorization_code, urn:ietf:params:oauth:grant-type:device_code, refresh_token (with one of those) and client_credentials (confidential clients only)"`
+	Public       bool     `json:"public" example:"false" description:"Single page or native app that cannot keep a secret; it gets none and must rely on PKCE"`
+}
+
+// @Description Registered OAuth client
+type ClientResponse struct {
+	model.OAuthClient
+	Public bool `json:"public" example:"false" description:"Whether the client has no secret"`
+}
+
+// @Description Newly registered OAuth client, including its secret
+type ClientCreatedResponse struct {
+	ClientResponse
+	ClientSecret string `json:"client_secret,omitempty" example:"q3ZgE1rXk3vQ9aBc1dE2fG3hJ4kL5mN6pQ7rS8tU9vw=" description:"Only returned now, absent for public clients"`
+}
@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+)
+
+// @Summary List roles
+// @Description List every role and the permissions it grants. Requires the roles:read permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {array} RoleResponse "Roles and their permissions"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the roles:read permission"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/roles [get]
+func (ar *AdminRouter) HandleListRoles(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListRoles called")
+	roles, err := ar.RoleRepo.GetRoles(r.Context())
+	if err != nil {
+		applog.Error("Failed to list roles:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	perms, err := ar.RoleRepo.GetAllPermissions(r.Context())
+	if err != nil {
+		applog.Error("Failed to list role permissions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := make([]RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		p := perms[role.Name]
+		if p == nil {
+			p = []string{}
+		}
+		resp = append(resp, RoleResponse{Role: role, Permissions: p})
+	}
+
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Assign a role
+// @Description Change the role of a user. Requires the roles:write permission. A demotion applies to the user's existing tokens immediately; new permissions are picked up on their next login or token refresh.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body RoleAssignRequest true "Role to assign"
+// @Success 200 {object} api.SuccessResponse "Role assigned"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID or unknown role, or attempt to change your own role"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the roles:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/role [put]
+func (ar *AdminRouter) HandleAssignRole(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleAssignRole called")
+	req, err := api.DecodeJSON[RoleAssignRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode role assign request:", err)
+		return
+	}
+
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.ID == admin.ID {
+		api.WriteMessage(w, 400, "error", "cannot change your own role")
+		return
+	}
+
+	exists, err := ar.RoleRepo.RoleExists(r.Context(), req.Role)
+	if err != nil {
+		applog.Error("Failed to check role:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !exists {
+		api.WriteMessage(w, 400, "error", "unknown role")
+		return
+	}
+
+	if err := ar.UserRepo.ChangeRole(r.Context(), user.ID, req.Role); err != nil {
+		applog.Error("Failed to change role:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, user.ID, model.RoleChangedEvent, user.Role+" -> "+req.Role)
+	api.WriteMessage(w, 200, "message", "role assigned")
+}
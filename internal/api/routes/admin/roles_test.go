@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	srv := newTestServer(t)
+	admin := newAdmin(t, srv)
+	bob := srv.NewClient(t)
+	bob.Register("bob", "bob@example.com")
+	bobRole := fmt.Sprintf("/admin/users/%d/role", srv.UserID(t, "bob@example.com"))
+
+	if status := bob.Do("GET", "/admin/users", nil, nil); status != 403 {
+		t.Fatalf("user without users:read: want 403, got %d", status)
+	}
+	if status := srv.NewClient(t).Do("GET", "/admin/users", nil, nil); status != 401 {
+		t.Fatalf("signed out: want 401, got %d", status)
+	}
+
+	var roles []RoleResponse
+	if status := admin.Do("GET", "/admin/roles", nil, &roles); status != 200 {
+		t.Fatalf("list roles: status %d", status)
+	}
+	i := slices.IndexFunc(roles, func(r RoleResponse) bool { return r.Name == "admin" })
+	if i < 0 || !slices.Contains(roles[i].Permissions, "roles:write") {
+		t.Fatalf("admin role missing or without roles:write: %+v", roles)
+	}
+
+	if status := admin.Do("PUT", bobRole, RoleAssignRequest{Role: "superuser"}, nil); status != 400 {
+		t.Fatalf("unknown role: want 400, got %d", status)
+	}
+	if status := admin.Do("PUT", fmt.Sprintf("/admin/users/%d/role", srv.UserID(t, "root@example.com")), RoleAssignRequest{Role: "user"}, nil); status != 400 {
+		t.Fatalf("own role: want 400, got %d", status)
+	}
+	if status := bob.Do("PUT", bobRole, RoleAssignRequest{Role: "admin"}, nil); status != 403 {
+		t.Fatalf("user assigning roles: want 403, got %d", status)
+	}
+
+	if status := admin.Do("PUT", bobRole, RoleAssignRequest{Role: "admin"}, nil); status != 200 {
+		t.Fatalf("promote: status %d", status)
+	}
+	if status := bob.Do("GET", "/admin/users", nil, nil); status != 403 {
+		t.Fatalf("promoted user before signing in again: want 403, got %d", status)
+	}
+	bob.Login("bob")
+	if status := bob.Do("GET", "/admin/users", nil, nil); status != 200 {
+		t.Fatalf("promoted user after signing in again: status %d", status)
+	}
+
+	if status := admin.Do("PUT", bobRole, RoleAssignRequest{Role: "user"}, nil); status != 200 {
+		t.Fatalf("demote: status %d", status)
+	}
+	if status := bob.Do("GET", "/admin/users", nil, nil); status != 403 {
+		t.Fatalf("demoted user's existing session: want 403, got %d", status)
+	}
+}
@@ -0,0 +1,73 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/middleware"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminRouter struct {
+	UserRepo     *repo.UserRepo
+	TokenRepo    *repo.TokenRepo
+	SessionRepo  *repo.SessionRepo
+	LockoutRepo  *repo.LockoutRepo
+	RoleRepo     *repo.RoleRepo
+	SecurityRepo *repo.SecurityRepo
+	OAuthRepo    *repo.OAuthRepo
+}
+
+func NewAdminRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, sessionRepo *repo.SessionRepo, lockoutRepo *repo.LockoutRepo, roleRepo *repo.RoleRepo, securityRepo *repo.SecurityRepo, oauthRepo *repo.OAuthRepo) http.Handler {
+	ar := &AdminRouter{UserRepo: userRepo, TokenRepo: tokenRepo, SessionRepo: sessionRepo, LockoutRepo: lockoutRepo, RoleRepo: roleRepo, SecurityRepo: securityRepo, OAuthRepo: oauthRepo}
+	r := chi.NewRouter()
+
+	r.Use(middleware.MaxBytesMiddleware(1 << 20))
+
+	//30/min+auth+permission
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 30, 1*time.Minute)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(model.PermUsersRead))
+			r.Get("/users", ar.HandleListUsers)
+			r.Get("/users/{id}", ar.HandleGetUser)
+			r.Get("/users/{id}/events", ar.HandleGetUserEvents)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(model.PermUsersWrite))
+			r.Post("/users/{id}/confirm-email", ar.HandleConfirmEmail)
+			r.Post("/users/{id}/reset-password", ar.HandleResetPassword)
+			r.Post("/users/{id}/suspend", ar.HandleSuspendUser)
+			r.Post("/users/{id}/reactivate", ar.HandleReactivateUser)
+			r.Post("/users/{id}/unlock", ar.HandleUnlockUser)
+			r.Post("/users/{id}/revoke-sessions", ar.HandleRevokeUserSessions)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(model.PermRolesRead))
+			r.Get("/roles", ar.HandleListRoles)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(model.PermRolesWrite))
+			r.Put("/users/{id}/role", ar.HandleAssignRole)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(model.PermClientsRead))
+			r.Get("/clients", ar.HandleListClients)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequirePermission(model.PermClientsWrite))
+			r.Post("/clients", ar.HandleCreateClient)
+			r.Delete("/clients/{id}", ar.HandleDeleteClient)
+		})
+	})
+
+	return r
+}
@@ -0,0 +1,513 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/api/routes/auth"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxEvents       = 200
+	maxReasonLength = 255
+)
+
+// @Summary List users
+// @Description List user accounts, newest first, one page at a time. Requires the users:read permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param page query int false "Page number, starting at 1" default(1)
+// @Param limit query int false "Users per page, at most 100" default(20)
+// @Param q query string false "Case-insensitive search in username and email"
+// @Param role query string false "Only users with this role"
+// @Param confirmed query bool false "Only users whose email is (or is not) confirmed"
+// @Param suspended query bool false "Only currently suspended (or not suspended) users"
+// @Success 200 {object} UserListResponse "Page of users"
+// @Failure 400 {object} api.ErrorResponse "Invalid query parameter"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:read permission"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users [get]
+func (ar *AdminRouter) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListUsers called")
+	query := r.URL.Query()
+
+	page, ok := intParam(w, query.Get("page"), 1, "page")
+	if !ok {
+		return
+	}
+	limit, ok := intParam(w, query.Get("limit"), defaultPageSize, "limit")
+	if !ok {
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	filter := repo.UserFilter{
+		Search: strings.TrimSpace(query.Get("q")),
+		Role:   query.Get("role"),
+	}
+	if filter.EmailConfirmed, ok = boolParam(w, query.Get("confirmed"), "confirmed"); !ok {
+		return
+	}
+	if filter.Suspended, ok = boolParam(w, query.Get("suspended"), "suspended"); !ok {
+		return
+	}
+
+	users, total, err := ar.UserRepo.ListUsers(r.Context(), filter, limit, (page-1)*limit)
+	if err != nil {
+		applog.Error("Failed to list users:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := UserListResponse{Users: make([]UserResponse, 0, len(users)), Total: total, Page: page, Limit: limit}
+	for i := range users {
+		resp.Users = append(resp.Users, newUserResponse(&users[i]))
+	}
+
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Get a user
+// @Description View a single user account. Requires the users:read permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} UserResponse "User account"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:read permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id} [get]
+func (ar *AdminRouter) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleGetUser called")
+	_, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	api.WriteJSON(w, 200, newUserResponse(user))
+}
+
+// @Summary List a user's security events
+// @Description View the audit trail of a user account, newest first. Events caused by an admin carry their ID in actor_id. Requires the users:read permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param limit query int false "Number of events, at most 200" default(50)
+// @Success 200 {array} model.SecurityEvent "Security events"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID or limit"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:read permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/events [get]
+func (ar *AdminRouter) HandleGetUserEvents(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleGetUserEvents called")
+	limit, ok := intParam(w, r.URL.Query().Get("limit"), 50, "limit")
+	if !ok {
+		return
+	}
+
+	_, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := ar.SecurityRepo.GetEventsByUser(r.Context(), user.ID, min(limit, maxEvents))
+	if err != nil {
+		applog.Error("Failed to list security events:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, events)
+}
+
+// @Summary Confirm a user's email
+// @Description Mark a user's email address as confirmed without them following the confirmation link. Requires the users:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} api.SuccessResponse "Email confirmed"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID or email already confirmed"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/confirm-email [post]
+func (ar *AdminRouter) HandleConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleConfirmEmail called")
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.EmailConfirmed {
+		api.WriteMessage(w, 400, "error", "email already confirmed")
+		return
+	}
+
+	if err := ar.UserRepo.MarkUserConfirmed(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to confirm email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, user.ID, model.EmailConfirmedEvent, user.Email)
+	api.WriteMessage(w, 200, "message", "email confirmed")
+}
+
+// @Summary Send a password reset email
+// @Description Send the user a password reset email, as if they had requested it themselves. Requires the users:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body ResetPasswordRequest true "Reset URL for the email"
+// @Success 200 {object} api.SuccessResponse "Password reset email sent"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID or request"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
+// @Router /admin/users/{id}/reset-password [post]
+func (ar *AdminRouter) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleResetPassword called")
+	req, err := api.DecodeJSON[ResetPasswordRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode reset password request:", err)
+		return
+	}
+
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	expiryStr := utils.ExpiryToString(int(config.App.ForgotPasswordExpiry))
+	token, err := auth.GenerateTokenAndSendEmail(user.Email, "forgotpassword", "Password reset", req.Url, map[string]any{"Expiry": expiryStr, "Url": req.Url})
+	if err != nil {
+		applog.Error("Failed to generate token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.AssignUserResetToken(r.Context(), token.Hash, time.Now().UTC().Unix(), user.ID); err != nil {
+		applog.Error("Failed to assign reset token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, user.ID, model.PasswordResetSentEvent, user.Email)
+	api.WriteMessage(w, 200, "message", "password reset sent")
+}
+
+// @Summary Suspend a user
+// @Description Suspend a user account, indefinitely or until the given time. The user is signed out everywhere and can no longer log in, refresh tokens or use API keys; they are shown the reason and end time when they try. Requires the users:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body SuspendRequest false "Reason and end of the suspension"
+// @Success 200 {object} api.SuccessResponse "User suspended"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID, reason or end time, attempt to suspend yourself, or account scheduled for deletion"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/suspend [post]
+func (ar *AdminRouter) HandleSuspendUser(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleSuspendUser called")
+	req, ok := decodeOptional[SuspendRequest](w, r)
+	if !ok {
+		return
+	}
+
+	reason, ok := validReason(w, req.Reason)
+	if !ok {
+		return
+	}
+
+	if req.Until != 0 && req.Until <= time.Now().UTC().Unix() {
+		api.WriteMessage(w, 400, "error", "until must be in the future")
+		return
+	}
+
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if user.ID == admin.ID {
+		api.WriteMessage(w, 400, "error", "cannot suspend yourself")
+		return
+	}
+
+	if user.IsPendingDeletion() {
+		api.WriteMessage(w, 400, "error", "account is scheduled for deletion")
+		return
+	}
+
+	if err := ar.UserRepo.SuspendUser(r.Context(), user.ID, reason, req.Until); err != nil {
+		applog.Error("Failed to suspend user:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.revokeAllSessions(r, user.ID); err != nil {
+		applog.Error("Failed to revoke sessions of suspended user:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	details := reason
+	if req.Until != 0 {
+		details += " (until " + time.Unix(req.Until, 0).UTC().Format(time.RFC3339) + ")"
+	}
+	ar.audit(r, admin, user.ID, model.AccountSuspendedEvent, strings.TrimSpace(details))
+	api.WriteMessage(w, 200, "message", "user suspended")
+}
+
+// @Summary Reactivate a user
+// @Description Lift a user's suspension before it ends, or cancel a scheduled account deletion. Requires the users:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body ReasonRequest false "Reason for the audit trail"
+// @Success 200 {object} api.SuccessResponse "User reactivated"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID or reason"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/reactivate [post]
+func (ar *AdminRouter) HandleReactivateUser(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleReactivateUser called")
+	req, ok := decodeOptional[ReasonRequest](w, r)
+	if !ok {
+		return
+	}
+
+	reason, ok := validReason(w, req.Reason)
+	if !ok {
+		return
+	}
+
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := ar.UserRepo.ReactivateUser(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to reactivate user:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, user.ID, model.AccountReactivatedEvent, reason)
+	api.WriteMessage(w, 200, "message", "user reactivated")
+}
+
+// @Summary Clear a user's lockouts
+// @Description Lift every active login lockout on a user and reset their failed login counts, from all addresses. Requires the users:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} api.SuccessResponse "Lockouts cleared"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/unlock [post]
+func (ar *AdminRouter) HandleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleUnlockUser called")
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	ips, err := ar.LockoutRepo.GetLockedIPs(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list lockouts:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	for _, ip := range ips {
+		if err := ar.LockoutRepo.UnlockAccount(r.Context(), user.ID, ip); err != nil {
+			applog.Error("Failed to unlock account:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
+	ar.audit(r, admin, user.ID, model.LockoutClearedEvent, strings.Join(ips, ", "))
+	api.WriteMessage(w, 200, "message", "lockouts cleared")
+}
+
+// @Summary Revoke all of a user's sessions
+// @Description Sign a user out on every device. Their session and refresh tokens stop working immediately. Requires the users:write permission.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} api.SuccessResponse "Sessions revoked"
+// @Failure 400 {object} api.ErrorResponse "Invalid user ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "Missing the users:write permission"
+// @Failure 404 {object} api.ErrorResponse "User not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /admin/users/{id}/revoke-sessions [post]
+func (ar *AdminRouter) HandleRevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRevokeUserSessions called")
+	admin, user, ok := ar.targetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := ar.revokeAllSessions(r, user.ID); err != nil {
+		applog.Error("Failed to revoke sessions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ar.audit(r, admin, user.ID, model.SessionsRevokedEvent, "")
+	api.WriteMessage(w, 200, "message", "sessions revoked")
+}
+
+// targetUser returns the acting admin and the user named by the id URL
+// parameter, writing the response itself when either cannot be found.
+func (ar *AdminRouter) targetUser(w http.ResponseWriter, r *http.Request) (*model.User, *model.User, bool) {
+	admin, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return nil, nil, false
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "invalid user id")
+		return nil, nil, false
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		api.WriteMessage(w, 404, "error", "user not found")
+		return nil, nil, false
+	}
+	if err != nil {
+		applog.Error("Failed to get user:", err)
+		api.WriteInternalError(w)
+		return nil, nil, false
+	}
+
+	return admin, user, true
+}
+
+// revokeAllSessions signs the user out everywhere, like a logout-all of their own.
+func (ar *AdminRouter) revokeAllSessions(r *http.Request, userID int64) error {
+	if err := ar.UserRepo.ChangeJwtSessionID(r.Context(), userID, utils.GenerateSnowflakeID()); err != nil {
+		return err
+	}
+	return ar.SessionRepo.RevokeAllSessions(r.Context(), userID)
+}
+
+// decodeOptional reads an optional JSON body. An empty body gives the zero value.
+func decodeOptional[T any](w http.ResponseWriter, r *http.Request) (T, bool) {
+	var req T
+	if r.ContentLength == 0 {
+		return req, true
+	}
+
+	req, err := api.DecodeJSON[T](w, r)
+	if err != nil {
+		applog.Error("Failed to decode request:", err)
+		return req, false
+	}
+	return req, true
+}
+
+func validReason(w http.ResponseWriter, reason string) (string, bool) {
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxReasonLength {
+		api.WriteMessage(w, 400, "error", "reason too long")
+		return "", false
+	}
+	return reason, true
+}
+
+func intParam(w http.ResponseWriter, value string, fallback int, name string) (int, bool) {
+	if value == "" {
+		return fallback, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		api.WriteMessage(w, 400, "error", "invalid "+name)
+		return 0, false
+	}
+	return n, true
+}
+
+func boolParam(w http.ResponseWriter, value, name string) (*bool, bool) {
+	if value == "" {
+		return nil, true
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "invalid "+name)
+		return nil, false
+	}
+	return &b, true
+}
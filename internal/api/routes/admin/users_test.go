@@ -0,0 +1,165 @@
+package admin
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/model"
+)
+
+func TestListUsersSearchIsLiteral(t *testing.T) {
+	srv := newTestServer(t)
+	admin := newAdmin(t, srv)
+	srv.NewClient(t).Register("bob_smith", "bob@example.com")
+	srv.NewClient(t).Register("bobxsmith", "bobx@example.com")
+	srv.NewClient(t).Register("percent", "100%off@example.com")
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"bob_s", []string{"bob_smith"}},
+		{"BOB", []string{"bobxsmith", "bob_smith"}},
+		{"100%", []string{"percent"}},
+		{"%", []string{"percent"}},
+		{`\`, nil},
+	}
+	for _, tt := range tests {
+		var resp UserListResponse
+		if status := admin.Do("GET", "/admin/users?q="+url.QueryEscape(tt.q), nil, &resp); status != 200 {
+			t.Fatalf("search %q: status %d", tt.q, status)
+		}
+
+		var got []string
+		for _, u := range resp.Users {
+			got = append(got, u.Username)
+		}
+		if len(got) != len(tt.want) || resp.Total != len(tt.want) {
+			t.Errorf("search %q: got %v, want %v", tt.q, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("search %q: got %v, want %v", tt.q, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAdminUserManagement(t *testing.T) {
+	srv := newTestServer(t)
+	admin := newAdmin(t, srv)
+	bob := srv.NewClient(t)
+	bob.Register("bob", "bob@example.com")
+	register := map[string]string{"username": "carol", "email": "carol@example.com", "password": apitest.Password, "url": "https://example.com/confirm"}
+	if status := srv.NewClient(t).Do("POST", "/auth/register", register, nil); status != 200 {
+		t.Fatalf("register carol: status %d", status)
+	}
+	bobID, carolID := srv.UserID(t, "bob@example.com"), srv.UserID(t, "carol@example.com")
+
+	var page UserListResponse
+	if status := admin.Do("GET", "/admin/users?limit=1&page=2", nil, &page); status != 200 {
+		t.Fatalf("list: status %d", status)
+	}
+	if page.Total != 3 || len(page.Users) != 1 || page.Page != 2 {
+		t.Fatalf("second page of one: got %+v", page)
+	}
+	if status := admin.Do("GET", "/admin/users?confirmed=false", nil, &page); status != 200 || page.Total != 1 || page.Users[0].ID != carolID {
+		t.Fatalf("unconfirmed users: status %d, got %+v", status, page)
+	}
+	if status := admin.Do("GET", "/admin/users?confirmed=maybe", nil, nil); status != 400 {
+		t.Fatalf("invalid filter: want 400, got %d", status)
+	}
+
+	if status := admin.Do("GET", "/admin/users/12345", nil, nil); status != 404 {
+		t.Fatalf("unknown user: want 404, got %d", status)
+	}
+	if status := bob.Do("POST", fmt.Sprintf("/admin/users/%d/confirm-email", carolID), nil, nil); status != 403 {
+		t.Fatalf("confirm as a user: want 403, got %d", status)
+	}
+	if status := admin.Do("POST", fmt.Sprintf("/admin/users/%d/confirm-email", carolID), nil, nil); status != 200 {
+		t.Fatalf("confirm: status %d", status)
+	}
+	var carol UserResponse
+	if status := admin.Do("GET", fmt.Sprintf("/admin/users/%d", carolID), nil, &carol); status != 200 || !carol.EmailConfirmed {
+		t.Fatalf("confirmed user: status %d, got %+v", status, carol)
+	}
+
+	if status := admin.Do("POST", fmt.Sprintf("/admin/users/%d/revoke-sessions", bobID), ReasonRequest{Reason: "stolen laptop"}, nil); status != 200 {
+		t.Fatalf("revoke sessions: status %d", status)
+	}
+	if status := bob.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("session after revocation: want 401, got %d", status)
+	}
+
+	var events []model.SecurityEvent
+	if status := admin.Do("GET", fmt.Sprintf("/admin/users/%d/events", bobID), nil, &events); status != 200 || len(events) == 0 {
+		t.Fatalf("events: status %d, %d events", status, len(events))
+	}
+	if events[0].ActorID != srv.UserID(t, "root@example.com") {
+		t.Fatalf("latest event not attributed to the admin: %+v", events[0])
+	}
+}
+
+func TestSuspendUser(t *testing.T) {
+	srv := newTestServer(t)
+	admin := newAdmin(t, srv)
+	bob := srv.NewClient(t)
+	bob.Register("bob", "bob@example.com")
+	suspend := fmt.Sprintf("/admin/users/%d/suspend", srv.UserID(t, "bob@example.com"))
+	refresh := bob.Cookie("/auth/refresh", "refresh")
+
+	until := time.Now().UTC().Unix() + 3600
+	if status := admin.Do("POST", suspend, SuspendRequest{Until: time.Now().UTC().Unix() - 1}, nil); status != 400 {
+		t.Fatalf("end time in the past: want 400, got %d", status)
+	}
+	if status := admin.Do("POST", fmt.Sprintf("/admin/users/%d/suspend", srv.UserID(t, "root@example.com")), nil, nil); status != 400 {
+		t.Fatalf("suspend yourself: want 400, got %d", status)
+	}
+	if status := admin.Do("POST", suspend, SuspendRequest{Reason: "spam", Until: until}, nil); status != 200 {
+		t.Fatalf("suspend: status %d", status)
+	}
+
+	if status := bob.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("session of a suspended user: want 401, got %d", status)
+	}
+	replay := srv.NewClient(t)
+	replay.Header.Set("Authorization", "Bearer "+refresh)
+	if status := replay.Do("POST", "/auth/refresh", nil, nil); status == 200 {
+		t.Fatal("refresh token of a suspended user still works")
+	}
+
+	login := func() (int, api.AccountSuspendedResponse) {
+		var resp api.AccountSuspendedResponse
+		status := srv.NewClient(t).Do("POST", "/auth/login", map[string]string{"identifier": "bob", "password": apitest.Password}, &resp)
+		return status, resp
+	}
+	if status, resp := login(); status != 403 || resp.Reason != "spam" || resp.Until != until {
+		t.Fatalf("login while suspended: want 403 with the reason and end, got %d %+v", status, resp)
+	}
+
+	var page UserListResponse
+	if status := admin.Do("GET", "/admin/users?suspended=true", nil, &page); status != 200 || page.Total != 1 || page.Users[0].Status != "suspended" {
+		t.Fatalf("suspended users: status %d, got %+v", status, page)
+	}
+
+	srv.DB.MustExec("UPDATE users SET status_until = 1 WHERE email = 'bob@example.com'")
+	if status, _ := login(); status != 200 {
+		t.Fatalf("login after the suspension ended: status %d", status)
+	}
+
+	if status := admin.Do("POST", suspend, SuspendRequest{Reason: "spam"}, nil); status != 200 {
+		t.Fatalf("indefinite suspension: status %d", status)
+	}
+	if status := admin.Do("POST", fmt.Sprintf("/admin/users/%d/reactivate", srv.UserID(t, "bob@example.com")), nil, nil); status != 200 {
+		t.Fatalf("reactivate: status %d", status)
+	}
+	if status, _ := login(); status != 200 {
+		t.Fatalf("login after reactivation: status %d", status)
+	}
+}
@@ -1,21 +1,32 @@
 package auth
 
 import (
+	"encoding/json"
+	"maps"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/api"
 	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
 	"github.com/akramboussanni/gocode/internal/utils"
 )
 
 // @Summary Get current user profile
-// @Description Retrieve the current authenticated user's profile information. Returns safe user data (excluding sensitive fields like password hash).
+// @Description Retrieve the current authenticated user's profile information. Returns safe user data (excluding sensitive fields like password hash) along with MFA status and the number of unused recovery codes. Also accepts an API key with the profile:read scope.
 // @Tags Account
 // @Accept json
 // @Produce json
 // @Security CookieAuth
-// @Success 200 {object} model.User "User profile information (safe fields only)"
+// @Security BearerAuth
+// @Success 200 {object} ProfileResponse "User profile information (safe fields only)"
 // @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "API key is missing the profile:read scope"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
 // @Router /auth/me [get]
@@ -28,7 +39,174 @@ func (ar *AuthRouter) HandleProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.StripUnsafeFields(user)
+	ar.writeProfile(w, r, user)
 	applog.Info("Profile retrieved", "userID:", user.ID)
-	api.WriteJSON(w, 200, user)
+}
+
+// @Summary Update current user profile
+// @Description Change the current user's profile: username, display name, locale, time zone, avatar URL and free-form metadata. Only the fields present in the body are changed. Usernames must be unique and can only be changed once per cooldown period.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body ProfileUpdateRequest true "Fields to change"
+// @Success 200 {object} ProfileResponse "Updated profile"
+// @Failure 400 {object} api.ErrorResponse "Invalid field, username taken or changed too recently, or metadata too large"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/me [patch]
+func (ar *AuthRouter) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleUpdateProfile called")
+	req, err := api.DecodeJSON[ProfileUpdateRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode profile update request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	oldUsername := user.Username
+	if req.Username != nil && utils.CleanUsername(*req.Username) != user.Username {
+		if !ar.applyUsername(w, r, user, utils.CleanUsername(*req.Username)) {
+			return
+		}
+	}
+
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if utf8.RuneCountInString(name) > 64 || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			api.WriteMessage(w, 400, "error", "invalid display name")
+			return
+		}
+		user.DisplayName = name
+	}
+
+	if req.Locale != nil {
+		if *req.Locale != "" && !utils.IsValidLocale(*req.Locale) {
+			api.WriteMessage(w, 400, "error", "invalid locale")
+			return
+		}
+		user.Locale = *req.Locale
+	}
+
+	if req.Timezone != nil {
+		if *req.Timezone != "" && !utils.IsValidTimezone(*req.Timezone) {
+			api.WriteMessage(w, 400, "error", "invalid timezone")
+			return
+		}
+		user.Timezone = *req.Timezone
+	}
+
+	if req.AvatarURL != nil {
+		if *req.AvatarURL != "" && !utils.IsValidAvatarURL(*req.AvatarURL) {
+			api.WriteMessage(w, 400, "error", "invalid avatar url")
+			return
+		}
+		user.AvatarURL = *req.AvatarURL
+	}
+
+	if req.Metadata != nil {
+		metadata := maps.Clone(user.Metadata)
+		if metadata == nil {
+			metadata = model.Metadata{}
+		}
+		for key, value := range req.Metadata {
+			if value == nil {
+				delete(metadata, key)
+			} else {
+				metadata[key] = value
+			}
+		}
+
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			applog.Error("Failed to encode metadata:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		if len(encoded) > config.App.ProfileMetadataMaxSize {
+			api.WriteMessage(w, 400, "error", "metadata too large, at most "+strconv.Itoa(config.App.ProfileMetadataMaxSize)+" bytes")
+			return
+		}
+		user.Metadata = metadata
+	}
+
+	if err := ar.UserRepo.UpdateProfile(r.Context(), user); err != nil {
+		applog.Error("Failed to update profile:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.Username != oldUsername {
+		err := ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+			ID:        utils.GenerateSnowflakeID(),
+			UserID:    user.ID,
+			Type:      model.UsernameChangedEvent,
+			IPAddress: utils.GetClientIP(r),
+			Details:   oldUsername + " to " + user.Username,
+			CreatedAt: user.UsernameChangedAt,
+		})
+		if err != nil {
+			applog.Error("Failed to log security event:", err)
+		}
+	}
+
+	ar.writeProfile(w, r, user)
+	applog.Info("Profile updated", "userID:", user.ID)
+}
+
+// applyUsername validates a username change and sets it on user, writing the
+// response itself when it is refused.
+func (ar *AuthRouter) applyUsername(w http.ResponseWriter, r *http.Request, user *model.User, username string) bool {
+	if !utils.IsValidUsername(username) {
+		api.WriteMessage(w, 400, "error", "invalid username")
+		return false
+	}
+
+	now := time.Now().UTC().Unix()
+	if next := user.UsernameChangedAt + config.App.UsernameChangeCooldown; user.UsernameChangedAt != 0 && next > now {
+		api.WriteMessage(w, 400, "error", "username can be changed again in "+utils.ExpiryToString(int(next-now)))
+		return false
+	}
+
+	// changing only the case or form keeps the name, which is already theirs
+	if utils.NormalizeUsername(username) != utils.NormalizeUsername(user.Username) {
+		duplicate, err := ar.UserRepo.DuplicateName(r.Context(), username)
+		if err != nil {
+			applog.Error("Failed to check duplicate username:", err)
+			api.WriteInternalError(w)
+			return false
+		}
+
+		if duplicate {
+			api.WriteMessage(w, 400, "error", "username already taken")
+			return false
+		}
+	}
+
+	user.Username = username
+	user.UsernameChangedAt = now
+	return true
+}
+
+// writeProfile responds with the user's profile and security summary.
+func (ar *AuthRouter) writeProfile(w http.ResponseWriter, r *http.Request, user *model.User) {
+	remaining, err := ar.RecoveryRepo.CountUnused(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to count recovery codes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := ProfileResponse{User: user, MfaEnabled: user.TotpEnabled, RecoveryCodesRemaining: remaining, PendingEmail: user.PendingEmail, HasPassword: user.HasPassword()}
+
+	utils.StripUnsafeFields(user)
+	api.WriteJSON(w, 200, resp)
 }
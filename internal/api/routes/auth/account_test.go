@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+
+	var me map[string]any
+	update := `{"display_name":"Alice Liddell","locale":"en-GB","timezone":"Europe/London","avatar_url":"https://example.com/a.png","metadata":{"theme":"dark","beta":true}}`
+	if status := c.Do("PATCH", "/auth/me", []byte(update), &me); status != 200 {
+		t.Fatalf("update: status %d", status)
+	}
+	if status := c.Do("PATCH", "/auth/me", []byte(`{"metadata":{"beta":null}}`), nil); status != 200 {
+		t.Fatalf("remove a metadata key: status %d", status)
+	}
+
+	me = nil
+	if status := c.Do("GET", "/auth/me", nil, &me); status != 200 {
+		t.Fatalf("profile: status %d", status)
+	}
+	metadata, _ := me["metadata"].(map[string]any)
+	if me["display_name"] != "Alice Liddell" || me["locale"] != "en-GB" || me["timezone"] != "Europe/London" || me["avatar_url"] != "https://example.com/a.png" || len(metadata) != 1 || metadata["theme"] != "dark" {
+		t.Fatalf("unexpected profile %v", me)
+	}
+	if _, ok := me["password_hash"]; ok {
+		t.Fatal("profile exposes the password hash")
+	}
+
+	for _, body := range []string{
+		`{"timezone":"Mars/Olympus"}`,
+		`{"locale":"not a locale"}`,
+		`{"avatar_url":"javascript:alert(1)"}`,
+		`{"metadata":{"blob":"` + strings.Repeat("x", 5000) + `"}}`,
+	} {
+		if status := c.Do("PATCH", "/auth/me", []byte(body), nil); status != 400 {
+			t.Errorf("%.40s: want 400, got %d", body, status)
+		}
+	}
+}
+
+func TestChangeUsername(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+	srv.NewClient(t).Register("bob", "bob@example.com")
+
+	if status := c.Do("PATCH", "/auth/me", ProfileUpdateRequest{Username: ptr("BOB")}, nil); status != 400 {
+		t.Fatalf("taken username: want 400, got %d", status)
+	}
+	if status := c.Do("PATCH", "/auth/me", ProfileUpdateRequest{Username: ptr("alicia")}, nil); status != 200 {
+		t.Fatalf("change username: status %d", status)
+	}
+	if status := c.Do("PATCH", "/auth/me", ProfileUpdateRequest{Username: ptr("alice")}, nil); status != 400 {
+		t.Fatalf("change again within the cooldown: want 400, got %d", status)
+	}
+	srv.NewClient(t).Login("alicia")
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in clear so users can
+// recognise it in listings.
+const apiKeyPrefixLength = 11
+
+// @Summary Create an API key
+// @Description Create a long-lived personal API key limited to the given scopes. The key is returned once and cannot be retrieved again; send it as "Authorization: Bearer gc_...". API keys cannot be used to manage API keys.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body ApiKeyCreateRequest true "Name, scopes and lifetime of the key"
+// @Success 201 {object} ApiKeyCreatedResponse "Created key, including the key itself"
+// @Failure 400 {object} api.ErrorResponse "Invalid name, scopes or lifetime, or too many keys"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/api-keys [post]
+func (ar *AuthRouter) HandleCreateApiKey(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleCreateApiKey called")
+	req, err := api.DecodeJSON[ApiKeyCreateRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode api key request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		api.WriteMessage(w, 400, "error", "invalid name")
+		return
+	}
+
+	var scopes []string
+	for _, scope := range req.Scopes {
+		if !slices.Contains(model.ApiKeyScopes, scope) {
+			api.WriteMessage(w, 400, "error", "unknown scope "+scope)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		api.WriteMessage(w, 400, "error", "at least one scope is required")
+		return
+	}
+
+	maxLifetime := config.App.ApiKeyMaxLifetime
+	if req.ExpiresIn < 0 || (maxLifetime > 0 && (req.ExpiresIn == 0 || req.ExpiresIn > maxLifetime)) {
+		api.WriteMessage(w, 400, "error", "invalid expiry, keys can last at most "+strconv.FormatInt(maxLifetime, 10)+" seconds")
+		return
+	}
+
+	count, err := ar.ApiKeyRepo.CountKeysByUser(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to count api keys:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if count >= config.App.ApiKeyLimit {
+		api.WriteMessage(w, 400, "error", "api key limit reached")
+		return
+	}
+
+	token, err := utils.GetRandomApiKey()
+	if err != nil {
+		applog.Error("Failed to generate api key:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	key := model.ApiKey{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    token.Raw[:apiKeyPrefixLength],
+		KeyHash:   token.Hash,
+		Scopes:    strings.Join(scopes, " "),
+		CreatedAt: now,
+	}
+	if req.ExpiresIn > 0 {
+		key.ExpiresAt = now + req.ExpiresIn
+	}
+
+	if err := ar.ApiKeyRepo.CreateKey(r.Context(), &key); err != nil {
+		applog.Error("Failed to store api key:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	sendSecurityAlert(user.Email, "API key created",
+		"A new API key named \""+key.Name+"\" was created for your account with access to: "+key.Scopes+".",
+		utils.GetClientIP(r))
+
+	applog.Info("API key created", "userID:", user.ID, "keyID:", key.ID)
+	api.WriteJSON(w, 201, ApiKeyCreatedResponse{ApiKey: key, Key: token.Raw})
+}
+
+// @Summary List API keys
+// @Description List the current user's API keys. Keys are identified by name and prefix; the keys themselves are never returned.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {array} model.ApiKey "API keys, oldest first"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/api-keys [get]
+func (ar *AuthRouter) HandleListApiKeys(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListApiKeys called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	keys, err := ar.ApiKeyRepo.GetKeysByUserSafe(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list api keys:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, keys)
+}
+
+// @Summary Revoke an API key
+// @Description Delete one of the current user's API keys. It stops working immediately.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 200 {object} api.SuccessResponse "API key revoked"
+// @Failure 400 {object} api.ErrorResponse "Invalid API key ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "API key not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/api-keys/{id} [delete]
+func (ar *AuthRouter) HandleRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRevokeApiKey called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "invalid api key id")
+		return
+	}
+
+	deleted, err := ar.ApiKeyRepo.DeleteKey(r.Context(), user.ID, id)
+	if err != nil {
+		applog.Error("Failed to revoke api key:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "api key not found")
+		return
+	}
+
+	applog.Info("API key revoked", "userID:", user.ID, "keyID:", id)
+	api.WriteMessage(w, 200, "message", "api key revoked")
+}
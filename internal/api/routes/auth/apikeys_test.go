@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestApiKeys(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+
+	if status := c.Do("POST", "/auth/api-keys", ApiKeyCreateRequest{Name: "deploy", Scopes: []string{"users:write"}}, nil); status != 400 {
+		t.Fatalf("unknown scope: want 400, got %d", status)
+	}
+
+	var created ApiKeyCreatedResponse
+	if status := c.Do("POST", "/auth/api-keys", ApiKeyCreateRequest{Name: "deploy", Scopes: []string{"profile:read"}}, &created); status != 201 {
+		t.Fatalf("create: status %d", status)
+	}
+	if !strings.HasPrefix(created.Key, "gc_") || !strings.HasPrefix(created.Key, created.Prefix) {
+		t.Fatalf("unexpected key %q with prefix %q", created.Key, created.Prefix)
+	}
+
+	key := srv.NewClient(t)
+	key.Header.Set("Authorization", "Bearer "+created.Key)
+	if status := key.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("key with profile:read on /auth/me: status %d", status)
+	}
+	if status := key.Do("GET", "/auth/sessions", nil, nil); status != 403 {
+		t.Fatalf("key without sessions:read: want 403, got %d", status)
+	}
+	if status := key.Do("GET", "/auth/api-keys", nil, nil); status != 401 {
+		t.Fatalf("key managing keys: want 401, got %d", status)
+	}
+
+	var keys []ApiKeyCreatedResponse
+	if status := c.Do("GET", "/auth/api-keys", nil, &keys); status != 200 || len(keys) != 1 {
+		t.Fatalf("list: status %d, %d keys", status, len(keys))
+	}
+	if keys[0].Key != "" || keys[0].LastUsedAt == 0 || keys[0].LastUsedIP == "" {
+		t.Fatalf("listed key: want no secret and the last use recorded, got %+v", keys[0])
+	}
+
+	if status := c.Do("DELETE", fmt.Sprintf("/auth/api-keys/%d", created.ID), nil, nil); status != 200 {
+		t.Fatalf("revoke: status %d", status)
+	}
+	if status := key.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("revoked key: want 401, got %d", status)
+	}
+}
@@ -5,40 +5,69 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/api"
 	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
 	"github.com/akramboussanni/gocode/internal/utils"
 )
 
 // @Summary Confirm email address
-// @Description Confirm user's email address using the confirmation token sent during registration. Token expires after 24 hours.
+// @Description Confirm user's email address using the confirmation token sent during registration, or the account email together with the 6-digit code from the same email. Tokens expire after 24 hours and codes after EMAIL_CODE_EXPIRY seconds; a code also stops working after EMAIL_CODE_MAX_ATTEMPTS wrong tries.
 // @Tags Email Verification
 // @Accept json
 // @Produce json
 // @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
-// @Param request body TokenRequest true "Email confirmation token"
+// @Param request body ConfirmEmailRequest true "Email confirmation token, or email and code"
 // @Success 200 {object} api.SuccessResponse "Email confirmed successfully - user can now login"
 // @Failure 400 {object} api.ErrorResponse "Invalid request format or missing token"
-// @Failure 401 {object} api.ErrorResponse "Invalid or expired confirmation token"
+// @Failure 401 {object} api.ErrorResponse "Invalid or expired confirmation token or code"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (5 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
 // @Router /auth/confirm-email [post]
 func (ar *AuthRouter) HandleConfirmEmail(w http.ResponseWriter, r *http.Request) {
 	applog.Info("HandleConfirmEmail called")
-	req, err := api.DecodeJSON[TokenRequest](w, r)
+	req, err := api.DecodeJSON[ConfirmEmailRequest](w, r)
 	if err != nil {
 		applog.Error("Failed to decode confirm email request:", err)
 		return
 	}
 
-	b, err := base64.URLEncoding.DecodeString(req.Token)
+	var user *model.User
+	if req.Code != "" {
+		user = ar.userByConfirmCode(w, r, req.Email, req.Code)
+	} else {
+		user = ar.userByConfirmToken(w, r, req.Token)
+	}
+	if user == nil {
+		return
+	}
+
+	if err = ar.UserRepo.MarkUserConfirmed(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to mark user confirmed:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.CodeRepo.DeleteCode(r.Context(), user.ID, model.EmailCodeConfirm); err != nil {
+		applog.Error("Failed to delete confirmation code:", err)
+	}
+
+	applog.Info("Email confirmed successfully", "userID:", user.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// userByConfirmToken returns the unconfirmed user the confirmation token was
+// sent to, writing the response itself and returning nil when it is not valid.
+func (ar *AuthRouter) userByConfirmToken(w http.ResponseWriter, r *http.Request, token string) *model.User {
+	b, err := base64.URLEncoding.DecodeString(token)
 	if err != nil {
 		applog.Error("Failed to decode confirmation token:", err)
 		api.WriteInternalError(w)
-		return
+		return nil
 	}
 
 	sha := sha256.Sum256(b)
@@ -46,30 +75,55 @@ func (ar *AuthRouter) HandleConfirmEmail(w http.ResponseWriter, r *http.Request)
 	user, err := ar.UserRepo.GetUserByConfirmationToken(r.Context(), hash)
 	if err != nil {
 		api.WriteInvalidCredentials(w)
-		return
+		return nil
 	}
 
 	if user.EmailConfirmed {
 		applog.Warn("Email already confirmed", "userID:", user.ID)
 		api.WriteInvalidCredentials(w)
-		return
+		return nil
 	}
 
 	expiry := user.EmailConfirmIssuedAt + config.App.EmailConfirmExpiry
 	if expiry < time.Now().UTC().Unix() {
 		applog.Warn("Expired confirmation token", "userID:", user.ID)
 		http.Error(w, "expired token, please request a new one", http.StatusUnauthorized)
-		return
+		return nil
 	}
 
-	if err = ar.UserRepo.MarkUserConfirmed(r.Context(), user.ID); err != nil {
-		applog.Error("Failed to mark user confirmed:", err)
+	return user
+}
+
+// userByConfirmCode returns the unconfirmed user with the given email if the
+// code is the one sent to them, writing the response itself and returning nil
+// otherwise.
+func (ar *AuthRouter) userByConfirmCode(w http.ResponseWriter, r *http.Request, email, code string) *model.User {
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), strings.TrimSpace(email))
+	if err != nil || user == nil {
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	if user.EmailConfirmed {
+		applog.Warn("Email already confirmed", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	valid, err := ar.verifyEmailCode(r.Context(), user.ID, model.EmailCodeConfirm, code)
+	if err != nil {
+		applog.Error("Failed to verify confirmation code:", err)
 		api.WriteInternalError(w)
-		return
+		return nil
 	}
 
-	applog.Info("Email confirmed successfully", "userID:", user.ID)
-	w.WriteHeader(http.StatusOK)
+	if !valid {
+		applog.Warn("Invalid confirmation code", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	return user
 }
 
 // @Summary Resend email confirmation
@@ -106,8 +160,15 @@ func (ar *AuthRouter) HandleResendConfirmation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	code, err := ar.newEmailCode(r.Context(), user.ID, model.EmailCodeConfirm)
+	if err != nil {
+		applog.Error("Failed to create confirmation code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	expiryStr := utils.ExpiryToString(24 * 3600)
-	token, err := GenerateTokenAndSendEmail(user.Email, "confirmregister", "Email confirmation", req.Url, map[string]any{"Expiry": expiryStr, "Url": req.Url})
+	token, err := GenerateTokenAndSendEmail(user.Email, "confirmregister", "Email confirmation", req.Url, confirmEmailData(req.Url, expiryStr, code))
 	if err != nil {
 		applog.Error("Failed to send confirmation email:", err)
 		api.WriteInternalError(w)
@@ -126,3 +187,14 @@ func (ar *AuthRouter) HandleResendConfirmation(w http.ResponseWriter, r *http.Re
 	applog.Info("Confirmation email resent", "userID:", user.ID, "email:", user.Email)
 	api.WriteMessage(w, 200, "message", "confirmation email resent")
 }
+
+// confirmEmailData is the template data for confirmation emails, which carry
+// both a link and a code to type in.
+func confirmEmailData(url, expiry, code string) map[string]any {
+	return map[string]any{
+		"Expiry":     expiry,
+		"Url":        url,
+		"Code":       code,
+		"CodeExpiry": utils.ExpiryToString(int(config.App.EmailCodeExpiry)),
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// @Summary Delete account
+// @Description Schedule the current account for deletion after the configured grace period. Requires password re-entry. Every session is signed out and the account cannot sign in until the deletion is cancelled through the link sent by email; once the grace period ends, the account and all its data are deleted for good.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body DeleteAccountRequest true "Current password and cancellation URL"
+// @Success 200 {object} DeletionScheduledResponse "Deletion scheduled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or incorrect password"
+// @Failure 409 {object} api.ErrorResponse "No password set - use /auth/set-password first"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
+// @Router /auth/delete-account [post]
+func (ar *AuthRouter) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleDeleteAccount called")
+	req, err := api.DecodeJSON[DeleteAccountRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode delete account request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !checkPassword(w, user, req.Password, "account deletion") {
+		return
+	}
+
+	deleteAt := time.Now().UTC().Unix() + config.App.AccountDeletionGrace
+	token, err := GenerateTokenAndSendEmail(user.Email, "deleteaccount", "Account deletion scheduled", req.Url, map[string]any{
+		"Url":      req.Url,
+		"DeleteAt": time.Unix(deleteAt, 0).UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		applog.Error("Failed to send account deletion email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.ScheduleDeletion(r.Context(), user.ID, token.Hash, deleteAt); err != nil {
+		applog.Error("Failed to schedule account deletion:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.revokeAllSessions(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to revoke all sessions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	utils.ClearAllCookies(w)
+
+	applog.Info("Account deletion scheduled", "userID:", user.ID, "deleteAt:", deleteAt)
+	api.WriteJSON(w, 200, DeletionScheduledResponse{Message: "account scheduled for deletion", DeleteAt: deleteAt})
+}
+
+// @Summary Cancel account deletion
+// @Description Cancel a scheduled account deletion using the token from the deletion email. The account can sign in again straight away.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Cancellation token from the email"
+// @Success 200 {object} api.SuccessResponse "Deletion cancelled"
+// @Failure 401 {object} api.ErrorResponse "Invalid token or no deletion pending"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/cancel-deletion [post]
+func (ar *AuthRouter) HandleCancelDeletion(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleCancelDeletion called")
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode cancel deletion request:", err)
+		return
+	}
+
+	hash, err := utils.HashToken(req.Token)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByDeletionToken(r.Context(), hash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if err := ar.UserRepo.ReactivateUser(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to cancel account deletion:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	sendSecurityAlert(user.Email, "Account deletion cancelled",
+		"The scheduled deletion of your account was cancelled. You can sign in again.",
+		utils.GetClientIP(r))
+
+	applog.Info("Account deletion cancelled", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "account deletion cancelled")
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// @Summary Change email address
+// @Description Start moving the account to a new email address. Requires password re-entry. A confirmation token is sent to the new address and a notice with a cancellation link to the current one; the email only changes once the new address is confirmed. Starting a new change replaces any pending one.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body EmailChangeRequest true "Current password, new email and link URLs"
+// @Success 200 {object} api.SuccessResponse "Confirmation sent to the new address"
+// @Failure 400 {object} api.ErrorResponse "Invalid email, same as the current one, or already in use"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or incorrect password"
+// @Failure 409 {object} api.ErrorResponse "No password set - use /auth/set-password first"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
+// @Router /auth/change-email [post]
+func (ar *AuthRouter) HandleChangeEmail(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleChangeEmail called")
+	req, err := api.DecodeJSON[EmailChangeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode change email request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !checkPassword(w, user, req.Password, "email change") {
+		return
+	}
+
+	email := strings.TrimSpace(req.NewEmail)
+	if !utils.IsValidEmail(email) {
+		api.WriteMessage(w, 400, "error", "invalid email")
+		return
+	}
+
+	if utils.NormalizeEmail(email) == utils.NormalizeEmail(user.Email) {
+		api.WriteMessage(w, 400, "error", "same email")
+		return
+	}
+
+	duplicate, err := ar.UserRepo.DuplicateEmail(r.Context(), email)
+	if err != nil {
+		applog.Error("Failed to check duplicate email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if duplicate {
+		applog.Warn("Email change to an address in use", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "email already in use")
+		return
+	}
+
+	expiryStr := utils.ExpiryToString(int(config.App.EmailChangeExpiry))
+	token, err := GenerateTokenAndSendEmail(email, "changeemail", "Confirm your new email address", req.Url, map[string]any{"Expiry": expiryStr, "Url": req.Url})
+	if err != nil {
+		applog.Error("Failed to send email change confirmation:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	cancel, err := GenerateTokenAndSendEmail(user.Email, "emailchangenotice", "Email change requested", req.CancelUrl, map[string]any{"Url": req.CancelUrl, "NewEmail": email})
+	if err != nil {
+		applog.Error("Failed to send email change notice:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.SetPendingEmail(r.Context(), user.ID, email, token.Hash, cancel.Hash, time.Now().UTC().Unix()); err != nil {
+		applog.Error("Failed to store pending email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Email change requested", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "confirmation sent to the new email")
+}
+
+// @Summary Confirm email change
+// @Description Confirm a pending email change with the token sent to the new address, while signed in to the account. The account moves to the new address, every other session is signed out and the previous address is notified.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body TokenRequest true "Confirmation token from the email"
+// @Success 200 {object} api.SuccessResponse "Email changed"
+// @Failure 400 {object} api.ErrorResponse "Email already in use"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized, or invalid or expired token"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/confirm-email-change [post]
+func (ar *AuthRouter) HandleConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleConfirmEmailChange called")
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode confirm email change request:", err)
+		return
+	}
+
+	current, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	hash, err := utils.HashToken(req.Token)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByEmailChangeToken(r.Context(), hash)
+	if err != nil || user.ID != current.ID {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	expiry := user.EmailChangeIssuedAt + config.App.EmailChangeExpiry
+	if expiry < time.Now().UTC().Unix() {
+		applog.Warn("Expired email change token", "userID:", user.ID)
+		http.Error(w, "expired token, please request a new one", http.StatusUnauthorized)
+		return
+	}
+
+	duplicate, err := ar.UserRepo.DuplicateEmail(r.Context(), user.PendingEmail)
+	if err != nil {
+		applog.Error("Failed to check duplicate email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if duplicate {
+		applog.Warn("Pending email taken before confirmation", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "email already in use")
+		return
+	}
+
+	if err := ar.UserRepo.ConfirmEmailChange(r.Context(), user.ID, user.PendingEmail); err != nil {
+		applog.Error("Failed to change email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if session, ok := utils.SessionFromContext(r.Context()); ok {
+		_, err = ar.SessionRepo.RevokeOtherSessions(r.Context(), user.ID, session)
+	} else {
+		err = ar.revokeAllSessions(r.Context(), user.ID)
+	}
+	if err != nil {
+		applog.Error("Failed to revoke sessions after email change:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	ip := utils.GetClientIP(r)
+	err = ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Type:      model.EmailChangedEvent,
+		IPAddress: ip,
+		Details:   user.Email + " to " + user.PendingEmail,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	sendSecurityAlert(user.Email, "Email address changed",
+		"The email address on your account was changed to "+user.PendingEmail+". This address will no longer receive emails about your account.",
+		ip)
+
+	applog.Info("Email changed", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "email changed")
+}
+
+// @Summary Cancel email change
+// @Description Cancel a pending email change using the token from the notice sent to the current address.
+// @Tags Account
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Cancellation token from the email"
+// @Success 200 {object} api.SuccessResponse "Email change cancelled"
+// @Failure 401 {object} api.ErrorResponse "Invalid token or no change pending"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/cancel-email-change [post]
+func (ar *AuthRouter) HandleCancelEmailChange(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleCancelEmailChange called")
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode cancel email change request:", err)
+		return
+	}
+
+	hash, err := utils.HashToken(req.Token)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByEmailChangeCancelToken(r.Context(), hash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if err := ar.UserRepo.CancelEmailChange(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to cancel email change:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Email change cancelled", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "email change cancelled")
+}
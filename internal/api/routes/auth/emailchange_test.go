@@ -0,0 +1,71 @@
+package auth
+
+import "testing"
+
+func TestEmailChange(t *testing.T) {
+	srv := newTestServer(t)
+	laptop := srv.NewClient(t)
+	laptop.Register("alice", "alice@example.com")
+	phone := srv.NewClient(t)
+	phone.Login("alice")
+	srv.NewClient(t).Register("bob", "bob@example.com")
+
+	change := func(password, email string) int {
+		return laptop.Do("POST", "/auth/change-email", EmailChangeRequest{Password: password, NewEmail: email}, nil)
+	}
+	if status := change("wrong password", "alice@new.example.com"); status != 401 {
+		t.Fatalf("wrong password: want 401, got %d", status)
+	}
+	if status := change(testPassword, "Bob@Example.com"); status != 400 {
+		t.Fatalf("address in use: want 400, got %d", status)
+	}
+	if status := change(testPassword, "alice@example.com"); status != 400 {
+		t.Fatalf("same address: want 400, got %d", status)
+	}
+
+	if status := change(testPassword, "alice@new.example.com"); status != 200 {
+		t.Fatalf("change: status %d", status)
+	}
+	token, _ := srv.Email(t, "changeemail", "alice@new.example.com")["Token"].(string)
+
+	if status := srv.NewClient(t).Do("POST", "/auth/confirm-email-change", TokenRequest{Token: token}, nil); status != 401 {
+		t.Fatalf("confirm while signed out: want 401, got %d", status)
+	}
+	if status := laptop.Do("POST", "/auth/confirm-email-change", TokenRequest{Token: "not-the-token"}, nil); status != 401 {
+		t.Fatalf("confirm with a wrong token: want 401, got %d", status)
+	}
+	if status := laptop.Do("POST", "/auth/confirm-email-change", TokenRequest{Token: token}, nil); status != 200 {
+		t.Fatalf("confirm: status %d", status)
+	}
+
+	var me map[string]any
+	if status := laptop.Do("GET", "/auth/me", nil, &me); status != 200 || me["email"] != "alice@new.example.com" {
+		t.Fatalf("profile after the change: status %d, %v", status, me)
+	}
+	if status := phone.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("other session after the change: want 401, got %d", status)
+	}
+	srv.NewClient(t).Login("alice@new.example.com")
+}
+
+func TestCancelEmailChange(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+
+	if status := c.Do("POST", "/auth/change-email", EmailChangeRequest{Password: testPassword, NewEmail: "mallory@example.com"}, nil); status != 200 {
+		t.Fatalf("change: status %d", status)
+	}
+	token, _ := srv.Email(t, "changeemail", "mallory@example.com")["Token"].(string)
+	cancel, _ := srv.Email(t, "emailchangenotice", "alice@example.com")["Token"].(string)
+
+	if status := srv.NewClient(t).Do("POST", "/auth/cancel-email-change", TokenRequest{Token: "not-the-token"}, nil); status != 401 {
+		t.Fatalf("cancel with a wrong token: want 401, got %d", status)
+	}
+	if status := srv.NewClient(t).Do("POST", "/auth/cancel-email-change", TokenRequest{Token: cancel}, nil); status != 200 {
+		t.Fatalf("cancel: status %d", status)
+	}
+	if status := c.Do("POST", "/auth/confirm-email-change", TokenRequest{Token: token}, nil); status != 401 {
+		t.Fatalf("confirm a cancelled change: want 401, got %d", status)
+	}
+}
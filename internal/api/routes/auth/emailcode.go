@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/mailer"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// @Summary Request a login code
+// @Description Email a 6-digit login code to the user, for signing in without a password on devices where links are awkward to open. The code expires after EMAIL_CODE_EXPIRY seconds, stops working after EMAIL_CODE_MAX_ATTEMPTS wrong tries, and requesting a new one replaces it. The account must have a confirmed email and not be locked out, suspended or scheduled for deletion.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body EmailRequest true "User email"
+// @Success 200 {object} api.SuccessResponse "Login code sent"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "User not found or email not confirmed"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 404 {object} api.ErrorResponse "Login codes are disabled"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
+// @Router /auth/email-code [post]
+func (ar *AuthRouter) HandleSendEmailCode(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleSendEmailCode called", "remoteAddr:", ip)
+	if !emailCodeLoginEnabled(w) {
+		return
+	}
+
+	req, err := api.DecodeJSON[EmailRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode email code request:", err)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		applog.Warn("Email code: user not found", "email:", req.Email)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	if !user.EmailConfirmed {
+		applog.Warn("Email code requested for unconfirmed email", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !checkAccountStatus(w, user) {
+		return
+	}
+
+	code, err := ar.newEmailCode(r.Context(), user.ID, model.EmailCodeLogin)
+	if err != nil {
+		applog.Error("Failed to create email code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	err = mailer.Send("emailcode", []string{user.Email}, "Your login code", map[string]any{
+		"Code":   code,
+		"Expiry": utils.ExpiryToString(int(config.App.EmailCodeExpiry)),
+	})
+	if err != nil {
+		applog.Error("Failed to send email code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Email code sent", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "login code sent")
+}
+
+// @Summary Log in with an emailed code
+// @Description Sign in with the email address and the 6-digit code sent to it, issuing session and refresh tokens as cookies or, with X-Token-Delivery: body, in the response body. Each code works once. Wrong codes count towards the account lockout. Accounts with an authenticator app still need their second factor: the response is then 202 with an mfa token for /auth/mfa/login.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
+// @Param request body EmailCodeLoginRequest true "User email and login code"
+// @Success 200 {object} LoginResponse "Authentication successful - session and refresh tokens issued"
+// @Success 202 {object} MfaRequiredResponse "Code accepted - second factor required, mfa token issued"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Invalid, used or expired code, or email not confirmed"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 404 {object} api.ErrorResponse "Login codes are disabled"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/email-code/login [post]
+func (ar *AuthRouter) HandleEmailCodeLogin(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleEmailCodeLogin called", "remoteAddr:", ip)
+	if !emailCodeLoginEnabled(w) {
+		return
+	}
+
+	req, err := api.DecodeJSON[EmailCodeLoginRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode email code login request:", err)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		applog.Warn("Email code login failed: user not found or db error", "email:", req.Email, "err:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	valid, err := ar.verifyEmailCode(r.Context(), user.ID, model.EmailCodeLogin, req.Code)
+	if err != nil {
+		applog.Error("Failed to verify email code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !valid {
+		applog.Warn("Invalid email code for user", "userID:", user.ID)
+		ar.registerFailedLogin(r.Context(), w, user.ID, ip)
+		return
+	}
+
+	if !user.EmailConfirmed {
+		applog.Warn("Email code login attempt with unconfirmed email", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !checkAccountStatus(w, user) {
+		return
+	}
+
+	if user.TotpEnabled {
+		ar.beginMfaLogin(w, r, user)
+		return
+	}
+
+	if !ar.issueLogin(w, r, user) {
+		return
+	}
+
+	applog.Info("User login successful with email code", "userID:", user.ID)
+}
+
+// newEmailCode generates a code for the purpose and stores it in place of the
+// user's previous one, returning the code to send.
+func (ar *AuthRouter) newEmailCode(ctx context.Context, userID int64, purpose model.EmailCodePurpose) (string, error) {
+	code, err := utils.GetRandomEmailCode()
+	if err != nil {
+		return "", err
+	}
+
+	err = ar.CodeRepo.ReplaceCode(ctx, &model.EmailCode{
+		UserID:    userID,
+		Purpose:   purpose,
+		CodeHash:  code.Hash,
+		ExpiresAt: time.Now().UTC().Unix() + config.App.EmailCodeExpiry,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code.Raw, nil
+}
+
+// verifyEmailCode checks a code entered by the user and consumes it when it
+// matches. Every attempt counts, and a code stops working once it expires or
+// EMAIL_CODE_MAX_ATTEMPTS attempts were made.
+func (ar *AuthRouter) verifyEmailCode(ctx context.Context, userID int64, purpose model.EmailCodePurpose, code string) (bool, error) {
+	stored, err := ar.CodeRepo.GetCode(ctx, userID, purpose)
+	if err != nil {
+		return false, nil
+	}
+
+	if stored.ExpiresAt < time.Now().UTC().Unix() {
+		return false, ar.CodeRepo.DeleteCode(ctx, userID, purpose)
+	}
+
+	allowed, err := ar.CodeRepo.RecordAttempt(ctx, userID, purpose, config.App.EmailCodeMaxAttempts)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	hash := utils.HashEmailCode(code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(stored.CodeHash)) != 1 {
+		return false, nil
+	}
+
+	return ar.CodeRepo.ConsumeCode(ctx, userID, purpose, hash)
+}
+
+// emailCodeLoginEnabled reports whether login codes are turned on, writing the
+// response itself when they are not.
+func emailCodeLoginEnabled(w http.ResponseWriter) bool {
+	if !config.App.EmailCodeLoginEnabled {
+		api.WriteMessage(w, 404, "error", "login codes are disabled")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+)
+
+// sendEmailCode has a login code emailed to address and returns it.
+func sendEmailCode(c *apitest.Client, address string) string {
+	c.T.Helper()
+	if status := c.Do("POST", "/auth/email-code", EmailRequest{Email: address}, nil); status != 200 {
+		c.T.Fatalf("send code: status %d", status)
+	}
+	code, _ := c.Server.Email(c.T, "emailcode", address)["Code"].(string)
+	return code
+}
+
+// otherCode returns a well-formed code that is not code.
+func otherCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func TestEmailCodeLogin(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("alice", "alice@example.com")
+	c := srv.NewClient(t)
+
+	login := func(code string) int {
+		return c.Do("POST", "/auth/email-code/login", EmailCodeLoginRequest{Email: "alice@example.com", Code: code}, nil)
+	}
+
+	if status := c.Do("POST", "/auth/email-code", EmailRequest{Email: "nobody@example.com"}, nil); status != 401 {
+		t.Fatalf("unknown email: want 401, got %d", status)
+	}
+
+	code := sendEmailCode(c, "alice@example.com")
+	if status := login(otherCode(code)); status != 401 {
+		t.Fatalf("wrong code: want 401, got %d", status)
+	}
+	if status := login(code); status != 200 {
+		t.Fatalf("login: status %d", status)
+	}
+	if status := c.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("session after login: status %d", status)
+	}
+	if status := login(code); status != 401 {
+		t.Fatalf("used code: want 401, got %d", status)
+	}
+
+	code = sendEmailCode(c, "alice@example.com")
+	srv.DB.MustExec("UPDATE email_codes SET expires_at = 1")
+	if status := login(code); status != 401 {
+		t.Fatalf("expired code: want 401, got %d", status)
+	}
+}
+
+func TestEmailCodeAttempts(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("alice", "alice@example.com")
+	c := srv.NewClient(t)
+
+	// stay under the account lockout, which wrong codes also count towards
+	maxAttempts := config.App.EmailCodeMaxAttempts
+	config.App.EmailCodeMaxAttempts = 2
+	t.Cleanup(func() { config.App.EmailCodeMaxAttempts = maxAttempts })
+
+	code := sendEmailCode(c, "alice@example.com")
+	for range config.App.EmailCodeMaxAttempts {
+		if status := c.Do("POST", "/auth/email-code/login", EmailCodeLoginRequest{Email: "alice@example.com", Code: otherCode(code)}, nil); status != 401 {
+			t.Fatalf("wrong code: want 401, got %d", status)
+		}
+	}
+	if status := c.Do("POST", "/auth/email-code/login", EmailCodeLoginRequest{Email: "alice@example.com", Code: code}, nil); status != 401 {
+		t.Fatalf("right code after too many attempts: want 401, got %d", status)
+	}
+}
+
+func TestEmailCodeDisabled(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("alice", "alice@example.com")
+
+	config.App.EmailCodeLoginEnabled = false
+	t.Cleanup(func() { config.App.EmailCodeLoginEnabled = true })
+
+	if status := srv.NewClient(t).Do("POST", "/auth/email-code", EmailRequest{Email: "alice@example.com"}, nil); status != 404 {
+		t.Fatalf("disabled: want 404, got %d", status)
+	}
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/mailer"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// @Summary Export personal data
+// @Description Export everything stored about the current user as JSON, optionally zipped: account details without credentials, failed logins, lockouts, sessions, security events, passkeys and API keys. Small exports are returned directly as a file download. Larger ones are generated in the background and a download link, valid for a limited time, is sent by email; the response is then 202.
+// @Tags Account
+// @Accept json
+// @Produce json,application/zip
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body DataExportRequest true "Export format and download URL"
+// @Success 200 {object} DataExport "The export, as a file download"
+// @Success 202 {object} api.SuccessResponse "Export is being generated and will be emailed"
+// @Failure 400 {object} api.ErrorResponse "Unknown format"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/data-export [post]
+func (ar *AuthRouter) HandleDataExport(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleDataExport called")
+	req, err := api.DecodeJSON[DataExportRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode data export request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = model.ExportFormatJSON
+	}
+	if format != model.ExportFormatJSON && format != model.ExportFormatZip {
+		api.WriteMessage(w, 400, "error", "unknown format "+format)
+		return
+	}
+
+	records, err := ar.UserRepo.CountUserRecords(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to count user records:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if records > config.App.DataExportInlineLimit {
+		go ar.emailDataExport(*user, format, req.Url)
+		applog.Info("Data export queued", "userID:", user.ID, "records:", records)
+		api.WriteMessage(w, 202, "message", "your export is being generated and will be emailed to you")
+		return
+	}
+
+	content, err := ar.buildDataExport(r.Context(), user)
+	if err != nil {
+		applog.Error("Failed to build data export:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	sendSecurityAlert(user.Email, "Personal data exported",
+		"A copy of your personal data was downloaded from your account.",
+		utils.GetClientIP(r))
+
+	applog.Info("Data exported", "userID:", user.ID)
+	writeDataExport(w, format, content)
+}
+
+// @Summary Download an emailed data export
+// @Description Download a personal data export generated in the background, using the token from the email. The token can be used until the export expires.
+// @Tags Account
+// @Accept json
+// @Produce json,application/zip
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body TokenRequest true "Download token from the email"
+// @Success 200 {object} DataExport "The export, as a file download"
+// @Failure 401 {object} api.ErrorResponse "Invalid or expired token"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/data-export/download [post]
+func (ar *AuthRouter) HandleDownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleDownloadDataExport called")
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode data export download request:", err)
+		return
+	}
+
+	hash, err := utils.HashToken(req.Token)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	export, err := ar.ExportRepo.GetExportByHash(r.Context(), hash, time.Now().UTC().Unix())
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	applog.Info("Data export downloaded", "userID:", export.UserID, "exportID:", export.ID)
+	writeDataExport(w, export.Format, []byte(export.Content))
+}
+
+// emailDataExport generates the export in the background, stores it and sends
+// the user a link to download it.
+func (ar *AuthRouter) emailDataExport(user model.User, format, url string) {
+	ctx := context.Background()
+	content, err := ar.buildDataExport(ctx, &user)
+	if err != nil {
+		applog.Error("Failed to build data export:", err, "userID:", user.ID)
+		return
+	}
+
+	token, err := utils.GetRandomToken(16)
+	if err != nil {
+		applog.Error("Failed to generate data export token:", err, "userID:", user.ID)
+		return
+	}
+
+	// store the export before sending the link, so it can be downloaded as
+	// soon as the email arrives
+	now := time.Now().UTC().Unix()
+	export := model.DataExport{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		TokenHash: token.Hash,
+		Format:    format,
+		Content:   string(content),
+		CreatedAt: now,
+		ExpiresAt: now + config.App.DataExportExpiry,
+	}
+	if err := ar.ExportRepo.CreateExport(ctx, &export); err != nil {
+		applog.Error("Failed to store data export:", err, "userID:", user.ID)
+		return
+	}
+
+	err = mailer.Send("dataexport", []string{user.Email}, "Your data export is ready", map[string]any{
+		"Token":  token.Raw,
+		"Url":    url,
+		"Expiry": utils.ExpiryToString(int(config.App.DataExportExpiry)),
+	})
+	if err != nil {
+		applog.Error("Failed to send data export email:", err, "userID:", user.ID)
+		return
+	}
+
+	applog.Info("Data export emailed", "userID:", user.ID, "exportID:", export.ID)
+}
+
+// buildDataExport gathers everything stored about the user into the JSON
+// document handed out by the export endpoints.
+func (ar *AuthRouter) buildDataExport(ctx context.Context, user *model.User) ([]byte, error) {
+	export := DataExport{
+		ExportedAt: time.Now().UTC().Unix(),
+		Account: DataExportAccount{
+			User:           user,
+			EmailConfirmed: user.EmailConfirmed,
+			MfaEnabled:     user.TotpEnabled,
+			Status:         string(model.StatusActive),
+		},
+	}
+
+	if user.IsPendingDeletion() {
+		export.Account.Status = string(model.StatusPendingDeletion)
+		export.Account.StatusUntil = user.StatusUntil
+	} else if user.IsSuspended(export.ExportedAt) {
+		export.Account.Status = string(model.StatusSuspended)
+		export.Account.StatusReason = user.StatusReason
+		export.Account.StatusUntil = user.StatusUntil
+	}
+
+	var err error
+	if export.FailedLogins, err = ar.LockoutRepo.GetFailedLoginsByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Lockouts, err = ar.LockoutRepo.GetLockoutsByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = ar.SessionRepo.GetSessionsByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.SecurityEvents, err = ar.SecurityRepo.GetAllEventsByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Passkeys, err = ar.PasskeyRepo.GetCredentialsByUserSafe(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.ApiKeys, err = ar.ApiKeyRepo.GetKeysByUserSafe(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if export.Identities, err = ar.IdentityRepo.GetIdentitiesByUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// writeDataExport sends the export as a file download, zipping it first when
+// asked to.
+func writeDataExport(w http.ResponseWriter, format string, content []byte) {
+	contentType := "application/json"
+	if format == model.ExportFormatZip {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		f, err := zw.Create("data-export.json")
+		if err == nil {
+			_, err = f.Write(content)
+		}
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			applog.Error("Failed to zip data export:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		content = buf.Bytes()
+		contentType = "application/zip"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="data-export.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
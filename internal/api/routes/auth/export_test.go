@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/akramboussanni/gocode/config"
+)
+
+func TestDataExport(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+
+	var passwordHash string
+	if err := srv.DB.Get(&passwordHash, "SELECT password_hash FROM users WHERE email = 'alice@example.com'"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := c.Send("POST", "/auth/data-export", "application/json", DataExportRequest{})
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("json export: status %d", resp.StatusCode)
+	}
+	var export DataExport
+	if err := json.Unmarshal(raw, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Account.User == nil || export.Account.Username != "alice" || !export.Account.EmailConfirmed || len(export.Sessions) == 0 {
+		t.Fatalf("unexpected export %s", raw)
+	}
+	if strings.Contains(string(raw), passwordHash) || strings.Contains(string(raw), "password_hash") {
+		t.Fatal("export contains the password hash")
+	}
+
+	resp = c.Send("POST", "/auth/data-export", "application/json", DataExportRequest{Format: "zip"})
+	raw, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Fatalf("zip export: status %d, type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil || len(zr.File) != 1 || zr.File[0].Name != "data-export.json" {
+		t.Fatalf("zip export: want a single data-export.json, err %v", err)
+	}
+
+	if status := c.Do("POST", "/auth/data-export", DataExportRequest{Format: "xml"}, nil); status != 400 {
+		t.Fatalf("unknown format: want 400, got %d", status)
+	}
+	if status := srv.NewClient(t).Do("POST", "/auth/data-export", DataExportRequest{}, nil); status != 401 {
+		t.Fatalf("signed out: want 401, got %d", status)
+	}
+}
+
+func TestDataExportInBackground(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+
+	limit := config.App.DataExportInlineLimit
+	config.App.DataExportInlineLimit = 0
+	t.Cleanup(func() { config.App.DataExportInlineLimit = limit })
+
+	if status := c.Do("POST", "/auth/data-export", DataExportRequest{Url: "https://example.com/export"}, nil); status != 202 {
+		t.Fatalf("large export: want 202, got %d", status)
+	}
+
+	email := srv.Email(t, "dataexport", "alice@example.com")
+	token, _ := email["Token"].(string)
+
+	download := srv.NewClient(t)
+	var export DataExport
+	if status := download.Do("POST", "/auth/data-export/download", TokenRequest{Token: token}, &export); status != 200 || export.Account.Username != "alice" {
+		t.Fatalf("download: status %d", status)
+	}
+	if status := download.Do("POST", "/auth/data-export/download", TokenRequest{Token: "not-the-token"}, nil); status != 401 {
+		t.Fatalf("download with a wrong token: want 401, got %d", status)
+	}
+
+	srv.DB.MustExec("UPDATE data_exports SET expires_at = 1")
+	if status := download.Do("POST", "/auth/data-export/download", TokenRequest{Token: token}, nil); status != 401 {
+		t.Fatalf("download after expiry: want 401, got %d", status)
+	}
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/oidc"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary Begin linking a provider
+// @Description Start linking an external OpenID Connect provider account to the current user, so it can be used to sign in. Works like /auth/oidc/{provider}/begin, and the page at the provider's redirect_uri then posts the code and state to /auth/oidc/{provider}/link/finish instead.
+// @Tags Social Login
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} OidcBeginResponse "Provider authorization URL"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Failure 502 {object} api.ErrorResponse "Provider unavailable"
+// @Router /auth/oidc/{provider}/link/begin [post]
+func (ar *AuthRouter) HandleOidcLinkBegin(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOidcLinkBegin called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	provider := oidcProvider(w, r)
+	if provider == nil {
+		return
+	}
+
+	ar.beginOidc(w, r, provider, user.ID)
+}
+
+// @Summary Finish linking a provider
+// @Description Complete linking an external provider account by posting the code and state it redirected back with. Requires the oidc cookie set by the link begin step in the same browser. The provider account's email does not need to match the user's. A notification email is sent.
+// @Tags Social Login
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Param request body OidcFinishRequest true "Code and state from the provider redirect"
+// @Success 200 {object} model.Identity "Linked identity"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized, missing or expired link, state mismatch, or code or ID token refused"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 409 {object} api.ErrorResponse "Provider account already linked to this or another account"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oidc/{provider}/link/finish [post]
+func (ar *AuthRouter) HandleOidcLinkFinish(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOidcLinkFinish called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	provider := oidcProvider(w, r)
+	if provider == nil {
+		return
+	}
+
+	claims := ar.finishOidc(w, r, provider, user.ID)
+	if claims == nil {
+		return
+	}
+
+	existing, err := ar.IdentityRepo.GetIdentity(r.Context(), provider.Name, claims.Subject)
+	if err == nil {
+		applog.Warn("Identity already linked", "userID:", user.ID, "ownerID:", existing.UserID, "provider:", provider.Name)
+		if existing.UserID == user.ID {
+			api.WriteMessage(w, 409, "error", "already linked to your account")
+		} else {
+			api.WriteMessage(w, 409, "error", "linked to another account")
+		}
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		applog.Error("Failed to get identity:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.linkIdentity(r, user.ID, provider, claims.Subject, strings.TrimSpace(claims.Email)); err != nil {
+		applog.Error("Failed to link identity:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	identity, err := ar.IdentityRepo.GetIdentity(r.Context(), provider.Name, claims.Subject)
+	if err != nil {
+		applog.Error("Failed to get identity:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	sendSecurityAlert(user.Email, "Sign-in method added",
+		"Your account can now be signed into with "+provider.DisplayName+".",
+		utils.GetClientIP(r))
+
+	api.WriteJSON(w, 200, identity)
+}
+
+// @Summary List linked providers
+// @Description List the external provider accounts linked to the current user.
+// @Tags Social Login
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {array} model.Identity "Linked identities"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/identities [get]
+func (ar *AuthRouter) HandleListIdentities(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListIdentities called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	identities, err := ar.IdentityRepo.GetIdentitiesByUser(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list identities:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, identities)
+}
+
+// @Summary Unlink a provider
+// @Description Unlink an external provider account from the current user. It can no longer be used to sign in. An account without a password must keep at least one other way to sign in, another linked provider or a passkey; set a password with /auth/set-password first otherwise.
+// @Tags Social Login
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "Identity ID"
+// @Success 200 {object} api.SuccessResponse "Identity unlinked"
+// @Failure 400 {object} api.ErrorResponse "Invalid identity ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Identity not found"
+// @Failure 409 {object} api.ErrorResponse "Last way to sign in to the account"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/identities/{id} [delete]
+func (ar *AuthRouter) HandleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleUnlinkIdentity called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "invalid identity id")
+		return
+	}
+
+	identities, err := ar.IdentityRepo.GetIdentitiesByUser(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list identities:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	var identity *model.Identity
+	for i := range identities {
+		if identities[i].ID == id {
+			identity = &identities[i]
+		}
+	}
+	if identity == nil {
+		api.WriteMessage(w, 404, "error", "identity not found")
+		return
+	}
+
+	if !user.HasPassword() && len(identities) == 1 {
+		passkeys, err := ar.PasskeyRepo.GetCredentialsByUserSafe(r.Context(), user.ID)
+		if err != nil {
+			applog.Error("Failed to list passkeys:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		if len(passkeys) == 0 {
+			applog.Warn("Refused to unlink last sign-in method", "userID:", user.ID)
+			api.WriteMessage(w, 409, "error", "this is your only way to sign in, set a password first")
+			return
+		}
+	}
+
+	deleted, err := ar.IdentityRepo.DeleteIdentity(r.Context(), user.ID, id)
+	if err != nil {
+		applog.Error("Failed to unlink identity:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "identity not found")
+		return
+	}
+
+	err = ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Type:      model.IdentityUnlinkedEvent,
+		IPAddress: utils.GetClientIP(r),
+		Details:   identity.Provider,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	name := identity.Provider
+	if provider, ok := oidc.Get(name); ok {
+		name = provider.DisplayName
+	}
+	sendSecurityAlert(user.Email, "Sign-in method removed",
+		"Your "+name+" account was unlinked and can no longer be used to sign in.",
+		utils.GetClientIP(r))
+
+	applog.Info("Identity unlinked", "userID:", user.ID, "identityID:", id)
+	api.WriteMessage(w, 200, "message", "identity unlinked")
+}
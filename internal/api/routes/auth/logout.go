@@ -4,7 +4,6 @@ import (
 	"math"
 	"net/http"
 
-	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/api"
 	"github.com/akramboussanni/gocode/internal/applog"
 	"github.com/akramboussanni/gocode/internal/middleware"
@@ -23,7 +22,7 @@ import (
 // @Failure 500 {object} api.ErrorResponse "Internal server error during token revocation"
 // @Router /api/auth/logout [post]
 func (ar *AuthRouter) HandleLogout(w http.ResponseWriter, r *http.Request) {
-	claims := middleware.GetClaimsFromCookie(w, r, config.JwtSecretBytes, ar.UserRepo, ar.TokenRepo)
+	claims := middleware.GetClaimsFromRequest(w, r, ar.TokenRepo)
 	if claims == nil || claims.Type != model.CredentialJwt {
 		return
 	}
@@ -40,6 +39,14 @@ func (ar *AuthRouter) HandleLogout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if claims.FamilyID != 0 {
+		if _, err := ar.SessionRepo.RevokeSession(r.Context(), claims.UserID, claims.FamilyID); err != nil {
+			applog.Error("Failed to revoke session during logout:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
 	utils.ClearAllCookies(w)
 
 	applog.Info("User logged out successfully", "userID:", claims.UserID, "tokenID:", claims.TokenID)
@@ -57,12 +64,12 @@ func (ar *AuthRouter) HandleLogout(w http.ResponseWriter, r *http.Request) {
 // @Failure 500 {object} api.ErrorResponse "Internal server error during session revocation"
 // @Router /api/auth/logout-all [post]
 func (ar *AuthRouter) HandleLogoutEverywhere(w http.ResponseWriter, r *http.Request) {
-	claims := middleware.GetClaimsFromCookie(w, r, config.JwtSecretBytes, ar.UserRepo, ar.TokenRepo)
+	claims := middleware.GetClaimsFromRequest(w, r, ar.TokenRepo)
 	if claims == nil || claims.Type != model.CredentialJwt {
 		return
 	}
 
-	err := ar.UserRepo.ChangeJwtSessionID(r.Context(), claims.UserID, utils.GenerateSnowflakeID())
+	err := ar.revokeAllSessions(r.Context(), claims.UserID)
 
 	if err != nil {
 		applog.Error("Failed to revoke all sessions during logout everywhere:", err)
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// @Summary Request a login link
+// @Description Email a single-use login link to the user, for signing in without a password. The link expires after MAGIC_LINK_EXPIRY seconds and requesting a new one replaces it. The account must have a confirmed email and not be locked out, suspended or scheduled for deletion.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body EmailRequest true "User email and login page URL"
+// @Success 200 {object} api.SuccessResponse "Login link sent"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "User not found or email not confirmed"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 404 {object} api.ErrorResponse "Login links are disabled"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error or email sending failure"
+// @Router /auth/magic-link [post]
+func (ar *AuthRouter) HandleSendMagicLink(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleSendMagicLink called", "remoteAddr:", ip)
+	if !magicLinkEnabled(w) {
+		return
+	}
+
+	req, err := api.DecodeJSON[EmailRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode magic link request:", err)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		applog.Warn("Magic link: user not found", "email:", req.Email)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	if !user.EmailConfirmed {
+		applog.Warn("Magic link requested for unconfirmed email", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !checkAccountStatus(w, user) {
+		return
+	}
+
+	expiryStr := utils.ExpiryToString(int(config.App.MagicLinkExpiry))
+	token, err := GenerateTokenAndSendEmail(user.Email, "magiclink", "Your login link", req.Url, map[string]any{"Expiry": expiryStr, "Url": req.Url})
+	if err != nil {
+		applog.Error("Failed to send magic link:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.AssignMagicLinkToken(r.Context(), token.Hash, time.Now().UTC().Unix(), user.ID); err != nil {
+		applog.Error("Failed to assign magic link token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Magic link sent", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "login link sent")
+}
+
+// @Summary Log in with a login link
+// @Description Sign in with the token from a login link email, issuing session and refresh tokens as cookies or, with X-Token-Delivery: body, in the response body. Each token works once. Accounts with an authenticator app still need their second factor: the response is then 202 with an mfa token for /auth/mfa/login.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
+// @Param request body TokenRequest true "Login token from the email"
+// @Success 200 {object} LoginResponse "Authentication successful - session and refresh tokens issued"
+// @Success 202 {object} MfaRequiredResponse "Link accepted - second factor required, mfa token issued"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Invalid, used or expired token, or email not confirmed"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 404 {object} api.ErrorResponse "Login links are disabled"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/magic-link/login [post]
+func (ar *AuthRouter) HandleMagicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleMagicLinkLogin called", "remoteAddr:", ip)
+	if !magicLinkEnabled(w) {
+		return
+	}
+
+	req, err := api.DecodeJSON[TokenRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode magic link login request:", err)
+		return
+	}
+
+	hash, err := utils.HashToken(req.Token)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByMagicLinkToken(r.Context(), hash)
+	if err != nil {
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	expiry := user.MagicLinkIssuedAt + config.App.MagicLinkExpiry
+	if expiry < time.Now().UTC().Unix() {
+		applog.Warn("Expired magic link token", "userID:", user.ID)
+		http.Error(w, "expired token, please request a new one", http.StatusUnauthorized)
+		return
+	}
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	if !user.EmailConfirmed {
+		applog.Warn("Magic link login attempt with unconfirmed email", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !checkAccountStatus(w, user) {
+		return
+	}
+
+	consumed, err := ar.UserRepo.ConsumeMagicLinkToken(r.Context(), user.ID, hash)
+	if err != nil {
+		applog.Error("Failed to consume magic link token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !consumed {
+		applog.Warn("Magic link token already used", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if user.TotpEnabled {
+		ar.beginMfaLogin(w, r, user)
+		return
+	}
+
+	if !ar.issueLogin(w, r, user) {
+		return
+	}
+
+	applog.Info("User login successful with magic link", "userID:", user.ID)
+}
+
+// magicLinkEnabled reports whether login links are turned on, writing the
+// response itself when they are not.
+func magicLinkEnabled(w http.ResponseWriter) bool {
+	if !config.App.MagicLinkEnabled {
+		api.WriteMessage(w, 404, "error", "login links are disabled")
+		return false
+	}
+	return true
+}
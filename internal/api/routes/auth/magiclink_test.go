@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/akramboussanni/gocode/config"
+)
+
+func TestMagicLinkLogin(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("alice", "alice@example.com")
+	c := srv.NewClient(t)
+
+	if status := c.Do("POST", "/auth/magic-link", EmailRequest{Email: "nobody@example.com"}, nil); status != 401 {
+		t.Fatalf("unknown email: want 401, got %d", status)
+	}
+
+	send := func() string {
+		t.Helper()
+		if status := c.Do("POST", "/auth/magic-link", EmailRequest{Email: "alice@example.com", Url: "https://example.com/login"}, nil); status != 200 {
+			t.Fatalf("send: status %d", status)
+		}
+		token, _ := srv.Email(t, "magiclink", "alice@example.com")["Token"].(string)
+		return token
+	}
+
+	replaced := send()
+	token := send()
+	if token == replaced {
+		t.Fatal("a new link reused the previous token")
+	}
+	if status := c.Do("POST", "/auth/magic-link/login", TokenRequest{Token: replaced}, nil); status != 401 {
+		t.Fatalf("replaced link: want 401, got %d", status)
+	}
+	if status := c.Do("POST", "/auth/magic-link/login", TokenRequest{Token: token}, nil); status != 200 {
+		t.Fatalf("login: status %d", status)
+	}
+	if status := c.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("session after login: status %d", status)
+	}
+	if status := srv.NewClient(t).Do("POST", "/auth/magic-link/login", TokenRequest{Token: token}, nil); status != 401 {
+		t.Fatalf("used link: want 401, got %d", status)
+	}
+
+	token = send()
+	srv.DB.MustExec("UPDATE users SET magic_link_issuedat = 1")
+	if status := srv.NewClient(t).Do("POST", "/auth/magic-link/login", TokenRequest{Token: token}, nil); status != 401 {
+		t.Fatalf("expired link: want 401, got %d", status)
+	}
+}
+
+func TestMagicLinkWithMfa(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+	enableTotp(c)
+
+	login := srv.NewClient(t)
+	if status := login.Do("POST", "/auth/magic-link", EmailRequest{Email: "alice@example.com"}, nil); status != 200 {
+		t.Fatalf("send: status %d", status)
+	}
+	token, _ := srv.Email(t, "magiclink", "alice@example.com")["Token"].(string)
+
+	if status := login.Do("POST", "/auth/magic-link/login", TokenRequest{Token: token}, nil); status != 202 {
+		t.Fatalf("login with mfa: want 202, got %d", status)
+	}
+	if status := login.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("session before the second factor: want 401, got %d", status)
+	}
+}
+
+func TestMagicLinkDisabled(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("alice", "alice@example.com")
+
+	config.App.MagicLinkEnabled = false
+	t.Cleanup(func() { config.App.MagicLinkEnabled = true })
+
+	if status := srv.NewClient(t).Do("POST", "/auth/magic-link", EmailRequest{Email: "alice@example.com"}, nil); status != 404 {
+		t.Fatalf("disabled: want 404, got %d", status)
+	}
+}
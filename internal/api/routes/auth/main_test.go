@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/go-chi/chi/v5"
+)
+
+const testPassword = apitest.Password
+
+func TestMain(m *testing.M) {
+	apitest.Init()
+	os.Exit(m.Run())
+}
+
+func newTestServer(t *testing.T) *apitest.Server {
+	return apitest.NewServer(t, func(r chi.Router, repos *repo.Repos) {
+		r.Mount("/auth", NewAuthRouter(repos.User, repos.Token, repos.Lockout, repos.Recovery, repos.Passkey, repos.Security, repos.Session, repos.ApiKey, repos.Role, repos.Export, repos.Code, repos.Identity, repos.OAuth))
+	})
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/middleware"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// beginMfaLogin hands out a short-lived mfa pending token instead of session
+// cookies once the password step has succeeded.
+func (ar *AuthRouter) beginMfaLogin(w http.ResponseWriter, r *http.Request, user *model.User) {
+	pending, err := jwt.CreateJwtFromUser(user).WithType(model.MfaPendingJwt).GenerateToken()
+	if err != nil {
+		applog.Error("Failed to generate mfa pending token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := MfaRequiredResponse{Message: "mfa required", MfaRequired: true}
+	delivery := requestedDelivery(r)
+
+	utils.ClearAllCookies(w)
+	if delivery.cookies() {
+		utils.SetMfaCookie(w, pending)
+	}
+	if delivery.body() {
+		w.Header().Set("Cache-Control", "no-store")
+		resp.MfaToken = pending
+	}
+
+	applog.Info("Password accepted, awaiting second factor", "userID:", user.ID)
+	api.WriteJSON(w, http.StatusAccepted, resp)
+}
+
+// @Summary Complete login with a second factor
+// @Description Complete a login that returned 202 by submitting a code from the user's authenticator app, or one of their single-use recovery codes. Requires the mfa token from /auth/login, as the mfa cookie or as a bearer token. Wrong codes count towards the account lockout, and using a recovery code sends a notification email.
+// @Tags Multi-Factor Authentication
+// @Accept json
+// @Produce json
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
+// @Param request body MfaLoginRequest true "Authenticator app code or recovery code"
+// @Success 200 {object} LoginResponse "Authentication successful - session and refresh tokens issued"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Missing or expired mfa token, or invalid code"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/login [post]
+func (ar *AuthRouter) HandleMfaLogin(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleMfaLogin called", "remoteAddr:", ip)
+
+	mfaToken := utils.TokenFromRequest(r, "mfa")
+	if mfaToken == "" {
+		applog.Warn("No mfa token found")
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	claims := middleware.GetClaims(w, r, mfaToken, ar.TokenRepo)
+	if claims == nil {
+		return
+	}
+
+	if claims.Type != model.MfaPendingJwt {
+		applog.Warn("Wrong token type for mfa login", "userID:", claims.UserID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	req, err := api.DecodeJSON[MfaLoginRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode mfa login request:", err)
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		applog.Warn("MFA login failed: user not found or db error", "userID:", claims.UserID, "err:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if claims.SessionID != user.JwtSessionID || !user.TotpEnabled {
+		applog.Warn("Stale mfa pending token", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	if req.RecoveryCode != "" {
+		ok, err := ar.consumeRecoveryCode(r.Context(), user, req.RecoveryCode, ip)
+		if err != nil {
+			applog.Error("Failed to consume recovery code:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		if !ok {
+			applog.Warn("Invalid recovery code at login", "userID:", user.ID)
+			ar.registerFailedLogin(r.Context(), w, user.ID, ip)
+			return
+		}
+	} else {
+		step, ok := utils.ValidateTotp(user.TotpSecret, req.Code, user.TotpLastStep)
+		if !ok {
+			applog.Warn("Invalid TOTP code at login", "userID:", user.ID)
+			ar.registerFailedLogin(r.Context(), w, user.ID, ip)
+			return
+		}
+
+		if !ar.consumeTotpStep(w, r, user.ID, step) {
+			return
+		}
+	}
+
+	err = ar.TokenRepo.RevokeToken(r.Context(), model.JwtBlacklist{
+		TokenID:   claims.TokenID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.Expiration,
+	})
+	if err != nil {
+		applog.Error("Failed to revoke mfa pending token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.issueLogin(w, r, user) {
+		return
+	}
+
+	applog.Info("User login successful after mfa", "userID:", user.ID)
+}
+
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret for the current user and return it with an otpauth:// URI. The secret only becomes active once a code is confirmed through /auth/mfa/totp/verify. Requires password re-entry.
+// @Tags Multi-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body PasswordRequest true "Current password"
+// @Success 200 {object} TotpEnrollResponse "Pending TOTP secret and provisioning URI"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or incorrect password"
+// @Failure 409 {object} api.ErrorResponse "TOTP already enabled - use re-enroll instead, or no password set - use /auth/set-password first"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/enroll [post]
+func (ar *AuthRouter) HandleTotpEnroll(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleTotpEnroll called")
+	req, err := api.DecodeJSON[PasswordRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode totp enroll request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !checkPassword(w, user, req.Password, "totp enroll") {
+		return
+	}
+
+	if user.TotpEnabled {
+		applog.Warn("TOTP enroll attempted while already enabled", "userID:", user.ID)
+		api.WriteMessage(w, http.StatusConflict, "error", "mfa already enabled")
+		return
+	}
+
+	ar.startTotpEnrollment(w, r, user)
+}
+
+// @Summary Re-enroll TOTP
+// @Description Replace the current user's TOTP secret, e.g. when moving to a new device. Requires password re-entry and a code from the currently enrolled authenticator. The old secret keeps working until the new one is confirmed through /auth/mfa/totp/verify.
+// @Tags Multi-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body PasswordCodeRequest true "Current password and current authenticator code"
+// @Success 200 {object} TotpEnrollResponse "Pending TOTP secret and provisioning URI"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or TOTP not enabled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized, incorrect password or invalid code"
+// @Failure 409 {object} api.ErrorResponse "No password set - use /auth/set-password first"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/reenroll [post]
+func (ar *AuthRouter) HandleTotpReenroll(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleTotpReenroll called")
+	req, err := api.DecodeJSON[PasswordCodeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode totp re-enroll request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.verifyPasswordAndTotp(w, r, user, req.Password, req.Code) {
+		return
+	}
+
+	ar.startTotpEnrollment(w, r, user)
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Activate the pending TOTP secret by submitting a code generated from it. Once active, logins require a second step through /auth/mfa/login. When MFA is first enabled the response also carries a set of single-use recovery codes, shown only once.
+// @Tags Multi-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body TotpCodeRequest true "Code generated from the pending secret"
+// @Success 200 {object} TotpActivatedResponse "TOTP enabled, with recovery codes on first enrollment"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or no pending enrollment"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or invalid code"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/verify [post]
+func (ar *AuthRouter) HandleTotpVerify(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleTotpVerify called")
+	req, err := api.DecodeJSON[TotpCodeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode totp verify request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.TotpPendingSecret == "" {
+		applog.Warn("TOTP verify without pending enrollment", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "no pending enrollment")
+		return
+	}
+
+	step, ok := utils.ValidateTotp(user.TotpPendingSecret, req.Code, 0)
+	if !ok {
+		applog.Warn("Invalid TOTP code during enrollment", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if err := ar.UserRepo.ActivateTotp(r.Context(), user.ID, step); err != nil {
+		applog.Error("Failed to activate totp:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := TotpActivatedResponse{Message: "mfa enabled"}
+	if !user.TotpEnabled {
+		resp.RecoveryCodes, err = ar.generateRecoveryCodes(r.Context(), user.ID)
+		if err != nil {
+			applog.Error("Failed to generate recovery codes:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	}
+
+	applog.Info("TOTP enabled", "userID:", user.ID)
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Disable TOTP
+// @Description Turn off TOTP for the current user and discard their recovery codes. Requires password re-entry and a current authenticator code.
+// @Tags Multi-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body PasswordCodeRequest true "Current password and current authenticator code"
+// @Success 200 {object} api.SuccessResponse "TOTP disabled"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or TOTP not enabled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized, incorrect password or invalid code"
+// @Failure 409 {object} api.ErrorResponse "No password set - use /auth/set-password first"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/totp/disable [post]
+func (ar *AuthRouter) HandleTotpDisable(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleTotpDisable called")
+	req, err := api.DecodeJSON[PasswordCodeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode totp disable request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.verifyPasswordAndTotp(w, r, user, req.Password, req.Code) {
+		return
+	}
+
+	if err := ar.UserRepo.DisableTotp(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to disable totp:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.RecoveryRepo.DeleteCodes(r.Context(), user.ID); err != nil {
+		applog.Error("Failed to delete recovery codes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("TOTP disabled", "userID:", user.ID)
+	api.WriteMessage(w, 200, "message", "mfa disabled")
+}
+
+func (ar *AuthRouter) startTotpEnrollment(w http.ResponseWriter, r *http.Request, user *model.User) {
+	secret, err := utils.GenerateTotpSecret()
+	if err != nil {
+		applog.Error("Failed to generate totp secret:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.UserRepo.SetPendingTotpSecret(r.Context(), user.ID, secret); err != nil {
+		applog.Error("Failed to store pending totp secret:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("TOTP enrollment started", "userID:", user.ID)
+	api.WriteJSON(w, 200, TotpEnrollResponse{
+		Secret: secret,
+		URI:    utils.TotpURI(config.App.TotpIssuer, user.Email, secret),
+	})
+}
+
+// verifyPasswordAndTotp guards changes to an active second factor behind both
+// the password and a current code.
+func (ar *AuthRouter) verifyPasswordAndTotp(w http.ResponseWriter, r *http.Request, user *model.User, password, code string) bool {
+	if !user.TotpEnabled {
+		applog.Warn("TOTP change attempted while not enabled", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "mfa not enabled")
+		return false
+	}
+
+	if !checkPassword(w, user, password, "totp change") {
+		return false
+	}
+
+	step, ok := utils.ValidateTotp(user.TotpSecret, code, user.TotpLastStep)
+	if !ok {
+		applog.Warn("Invalid TOTP code for totp change", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return false
+	}
+
+	return ar.consumeTotpStep(w, r, user.ID, step)
+}
+
+func (ar *AuthRouter) consumeTotpStep(w http.ResponseWriter, r *http.Request, userID int64, step int64) bool {
+	consumed, err := ar.UserRepo.ConsumeTotpStep(r.Context(), userID, step)
+	if err != nil {
+		applog.Error("Failed to record totp step:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if !consumed {
+		applog.Warn("Replayed TOTP code", "userID:", userID)
+		api.WriteInvalidCredentials(w)
+		return false
+	}
+
+	return true
+}
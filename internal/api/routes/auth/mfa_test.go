@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+)
+
+// totpCode computes the RFC 6238 code for secret, steps periods from now.
+func totpCode(secret string, steps int64) string {
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().UTC().Unix()/30+steps))
+	h := hmac.New(sha1.New, key)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// enableTotp enrolls an authenticator for the signed in client, using the code
+// of the previous time step so later calls can use the current one.
+func enableTotp(c *apitest.Client) (secret string, recoveryCodes []string) {
+	c.T.Helper()
+
+	var enroll TotpEnrollResponse
+	if status := c.Do("POST", "/auth/mfa/totp/enroll", PasswordRequest{Password: testPassword}, &enroll); status != 200 {
+		c.T.Fatalf("totp enroll: status %d", status)
+	}
+
+	var activated TotpActivatedResponse
+	if status := c.Do("POST", "/auth/mfa/totp/verify", TotpCodeRequest{Code: totpCode(enroll.Secret, -1)}, &activated); status != 200 {
+		c.T.Fatalf("totp verify: status %d", status)
+	}
+	return enroll.Secret, activated.RecoveryCodes
+}
+
+func TestTotpLogin(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+	secret, _ := enableTotp(c)
+
+	login := srv.NewClient(t)
+	var pending MfaRequiredResponse
+	if status := login.Do("POST", "/auth/login", LoginRequest{Identifier: "alice", Password: testPassword}, &pending); status != 202 || !pending.MfaRequired {
+		t.Fatalf("login with mfa: want 202, got %d", status)
+	}
+	if status := login.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("session before the second factor: want 401, got %d", status)
+	}
+
+	if status := login.Do("POST", "/auth/mfa/login", MfaLoginRequest{Code: "000000"}, nil); status != 401 {
+		t.Fatalf("wrong code: want 401, got %d", status)
+	}
+	if status := login.Do("POST", "/auth/mfa/login", MfaLoginRequest{Code: totpCode(secret, 0)}, nil); status != 200 {
+		t.Fatalf("mfa login: status %d", status)
+	}
+	if status := login.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("session after the second factor: want 200, got %d", status)
+	}
+}
+
+func TestTotpChangeWithoutPassword(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+	secret, _ := enableTotp(c)
+
+	// an account that signs in through a provider only
+	srv.DB.MustExec("UPDATE users SET password_hash = '' WHERE email = 'alice@example.com'")
+
+	for _, path := range []string{"/auth/mfa/totp/disable", "/auth/mfa/totp/reenroll"} {
+		if status := c.Do("POST", path, PasswordCodeRequest{Password: testPassword, Code: totpCode(secret, 0)}, nil); status != 409 {
+			t.Errorf("%s without a password: want 409, got %d", path, status)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package auth
 
+import "github.com/akramboussanni/gocode/internal/model"
+
 // @Description User registration request with email confirmation
 type RegisterRequest struct {
-	Username string `json:"username" example:"johndoe" binding:"required" minLength:"3" maxLength:"30" pattern:"^[a-zA-Z0-9_-]+$"`
+	Username string `json:"username" example:"johndoe" binding:"required" minLength:"3" maxLength:"30" description:"Letters and digits of a single script, dashes and underscores; compared ignoring case and Unicode form"`
 	Email    string `json:"email" example:"john@example.com" binding:"required" format:"email"`
 	Password string `json:"password" example:"SecurePass123!" binding:"required" minLength:"8"`
 	Url      string `json:"url" example:"https://example.com/confirm" binding:"required" format:"uri"`
@@ -10,8 +12,9 @@ type RegisterRequest struct {
 
 // @Description User login credentials
 type LoginRequest struct {
-	Email    string `json:"email" example:"john@example.com" binding:"required" format:"email"`
-	Password string `json:"password" example:"SecurePass123!" binding:"required"`
+	Identifier string `json:"identifier" example:"johndoe" description:"Email or username, depending on which the server accepts"`
+	Email      string `json:"email" example:"john@example.com" format:"email" description:"Deprecated, use identifier"`
+	Password   string `json:"password" example:"SecurePass123!" binding:"required"`
 }
 
 // @Description Email-based request for password reset and email confirmation resend
@@ -26,6 +29,24 @@ type TokenRequest struct {
 	Url   string `json:"url" example:"https://example.com/reset" format:"uri" description:"Optional URL for email templates"`
 }
 
+// @Description Email confirmation, with either the token from the confirmation email or the account email and the code from the same email
+type ConfirmEmailRequest struct {
+	Token string `json:"token" example:"dGhpcyBpcyBhIHRva2Vu" description:"Confirmation token, when not confirming with a code"`
+	Email string `json:"email" example:"john@example.com" format:"email" description:"Account email, required with code"`
+	Code  string `json:"code" example:"123456" minLength:"6" maxLength:"6" description:"6-digit code from the confirmation email"`
+}
+
+// @Description Login with a code sent by email
+type EmailCodeLoginRequest struct {
+	Email string `json:"email" example:"john@example.com" binding:"required" format:"email"`
+	Code  string `json:"code" example:"123456" binding:"required" minLength:"6" maxLength:"6"`
+}
+
+// @Description New password for an account that has none
+type SetPasswordRequest struct {
+	NewPassword string `json:"new_password" example:"NewSecurePass123!" binding:"required" minLength:"8" description:"New password that meets security requirements"`
+}
+
 // @Description Password reset request with token and new password
 type PasswordResetRequest struct {
 	Token       string `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..." binding:"required" description:"Password reset token from email"`
@@ -37,3 +58,258 @@ type PasswordChangeRequest struct {
 	OldPassword string `json:"old_password" example:"SecurePass123!" binding:"required" description:"Current password for verification"`
 	NewPassword string `json:"new_password" example:"NewSecurePass123!" binding:"required" minLength:"8" description:"New password that meets security requirements"`
 }
+
+// @Description Password re-entry for sensitive account operations
+type PasswordRequest struct {
+	Password string `json:"password" example:"SecurePass123!" binding:"required" description:"Current account password"`
+}
+
+// @Description Authenticator app code
+type TotpCodeRequest struct {
+	Code string `json:"code" example:"123456" binding:"required" minLength:"6" maxLength:"6" description:"6-digit code from the authenticator app"`
+}
+
+// @Description Password re-entry combined with a current authenticator app code
+type PasswordCodeRequest struct {
+	Password string `json:"password" example:"SecurePass123!" binding:"required" description:"Current account password"`
+	Code     string `json:"code" example:"123456" binding:"required" minLength:"6" maxLength:"6" description:"6-digit code from the currently enrolled authenticator app"`
+}
+
+// @Description Second login step for accounts with multi-factor authentication enabled. Provide either code or recovery_code.
+type MfaLoginRequest struct {
+	Code         string `json:"code" example:"123456" minLength:"6" maxLength:"6" description:"6-digit code from the authenticator app"`
+	RecoveryCode string `json:"recovery_code" example:"k3x7q-9mzpa" description:"Single-use recovery code, used when the authenticator app is unavailable"`
+}
+
+// @Description Login response when a second factor is required before session cookies are issued
+type MfaRequiredResponse struct {
+	Message     string `json:"message" example:"mfa required" description:"Status message"`
+	MfaRequired bool   `json:"mfa_required" example:"true" description:"Always true; complete the login by posting a code to /auth/mfa/login"`
+	MfaToken    string `json:"mfa_token,omitempty" description:"Pending mfa token to send as a bearer token to /auth/mfa/login, only present with body token delivery"`
+}
+
+// @Description Successful login or refresh. Tokens are only included with body token delivery; otherwise they are set as cookies
+type LoginResponse struct {
+	Message string `json:"message" example:"login successful" description:"Status message"`
+	*model.LoginTokens
+}
+
+// @Description TOTP enrollment details to load into an authenticator app
+type TotpEnrollResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP" description:"Base32 encoded shared secret for manual entry"`
+	URI    string `json:"uri" example:"otpauth://totp/gocode:john%40example.com?secret=JBSWY3DPEHPK3PXP&issuer=gocode" description:"otpauth:// provisioning URI, typically rendered as a QR code"`
+}
+
+// @Description Result of confirming TOTP enrollment
+type TotpActivatedResponse struct {
+	Message       string   `json:"message" example:"mfa enabled" description:"Status message"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty" example:"k3x7q-9mzpa,p2w8d-x4nfe" description:"Single-use recovery codes, only returned when MFA is first enabled. Shown once."`
+}
+
+// @Description Freshly generated recovery codes. Any previous codes are invalidated.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"k3x7q-9mzpa,p2w8d-x4nfe" description:"Single-use recovery codes. Shown once."`
+}
+
+// @Description Current user profile with account security summary
+type ProfileResponse struct {
+	*model.User
+	MfaEnabled             bool   `json:"mfa_enabled" example:"true" description:"Whether a second factor is required at login"`
+	RecoveryCodesRemaining int    `json:"recovery_codes_remaining" example:"8" description:"Number of unused recovery codes"`
+	PendingEmail           string `json:"pending_email,omitempty" example:"new@example.com" description:"Email address waiting to be confirmed, if an email change is in progress"`
+	HasPassword            bool   `json:"has_password" example:"true" description:"Whether the account has a password; accounts created through an external provider start without one"`
+}
+
+// @Description Profile changes. Omitted fields are left as they are; an empty string clears a field. Metadata is merged into the stored object, with null values removing keys
+type ProfileUpdateRequest struct {
+	Username    *string        `json:"username" example:"johndoe" minLength:"3" maxLength:"30" description:"New username, with the same rules as at registration and subject to a cooldown between changes"`
+	DisplayName *string        `json:"display_name" example:"John Doe" maxLength:"64"`
+	Locale      *string        `json:"locale" example:"en-US" description:"BCP 47 language tag"`
+	Timezone    *string        `json:"timezone" example:"Europe/Paris" description:"IANA time zone name"`
+	AvatarURL   *string        `json:"avatar_url" example:"https://example.com/avatar.png" format:"uri"`
+	Metadata    map[string]any `json:"metadata" swaggertype:"object" description:"Free-form keys to set, or to remove when null"`
+}
+
+// @Description Active session, flagged when it is the one making the request
+type SessionResponse struct {
+	model.Session
+	Current bool `json:"current" example:"true" description:"Whether this is the session the request was made with"`
+}
+
+// @Description Passkey registration request
+type PasskeyRegisterRequest struct {
+	Name string `json:"name" example:"MacBook Touch ID" binding:"required" maxLength:"64" description:"Display name to tell this passkey apart from the user's others"`
+}
+
+// @Description Personal API key creation request
+type ApiKeyCreateRequest struct {
+	Name      string   `json:"name" example:"deploy script" binding:"required" maxLength:"64" description:"Display name to tell this key apart from the user's others"`
+	Scopes    []string `json:"scopes" example:"profile:read" binding:"required" description:"Scopes granted to the key: profile:read, sessions:read"`
+	ExpiresIn int64    `json:"expires_in" example:"2592000" description:"Lifetime in seconds, 0 for a key that never expires"`
+}
+
+// @Description Newly created API key. The key is only ever shown in this response
+type ApiKeyCreatedResponse struct {
+	model.ApiKey
+	Key string `json:"key" example:"gc_Xk3vQ9aB..." description:"The API key, send as \"Authorization: Bearer <key>\""`
+}
+
+// @Description Email change request
+type EmailChangeRequest struct {
+	Password  string `json:"password" example:"SecurePass123!" binding:"required" description:"Current account password"`
+	NewEmail  string `json:"new_email" example:"new@example.com" binding:"required" format:"email" description:"Address to move the account to"`
+	Url       string `json:"url" example:"https://example.com/confirm-email-change" format:"uri" description:"Optional URL for the confirmation link sent to the new address"`
+	CancelUrl string `json:"cancel_url" example:"https://example.com/cancel-email-change" format:"uri" description:"Optional URL for the cancellation link sent to the current address"`
+}
+
+// @Description Account deletion request
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"SecurePass123!" binding:"required" description:"Current account password"`
+	Url      string `json:"url" example:"https://example.com/cancel-deletion" format:"uri" description:"Optional URL for the cancellation link in the email"`
+}
+
+// @Description Account deletion scheduled
+type DeletionScheduledResponse struct {
+	Message  string `json:"message" example:"account scheduled for deletion" description:"Success message"`
+	DeleteAt int64  `json:"delete_at" example:"1640995200" description:"When the account will be deleted unless cancelled"`
+}
+
+// @Description Personal data export request
+type DataExportRequest struct {
+	Format string `json:"format" example:"json" enums:"json,zip" description:"json (default) or zip"`
+	Url    string `json:"url" example:"https://example.com/data-export" format:"uri" description:"Optional URL for the download link in the email sent when the export is generated in the background"`
+}
+
+// @Description Account details in a personal data export
+type DataExportAccount struct {
+	*model.User
+	EmailConfirmed bool   `json:"email_confirmed" example:"true"`
+	MfaEnabled     bool   `json:"mfa_enabled" example:"false"`
+	Status         string `json:"status" example:"active"`
+	StatusReason   string `json:"status_reason,omitempty" example:"spam"`
+	StatusUntil    int64  `json:"status_until,omitempty" example:"1640995200"`
+}
+
+// @Description Everything stored about a user, minus credentials and other secrets
+type DataExport struct {
+	ExportedAt     int64                      `json:"exported_at" example:"1640995200"`
+	Account        DataExportAccount          `json:"account"`
+	FailedLogins   []model.FailedLogin        `json:"failed_logins"`
+	Lockouts       []model.Lockout            `json:"lockouts"`
+	Sessions       []model.Session            `json:"sessions"`
+	SecurityEvents []model.SecurityEvent      `json:"security_events"`
+	Passkeys       []model.WebAuthnCredential `json:"passkeys"`
+	ApiKeys        []model.ApiKey             `json:"api_keys"`
+	Identities     []model.Identity           `json:"identities"`
+}
+
+// @Description External OpenID Connect provider users can sign in with
+type OidcProviderResponse struct {
+	Name        string `json:"name" example:"google" description:"Identifier used in /auth/oidc/{provider} URLs"`
+	DisplayName string `json:"display_name" example:"Google" description:"Name to show on the sign-in button"`
+}
+
+// @Description Where to send the user to sign in at the provider
+type OidcBeginResponse struct {
+	AuthorizationURL string `json:"authorization_url" example:"https://accounts.google.com/o/oauth2/v2/auth?response_type=code&..." description:"Provider authorization URL to redirect the browser to"`
+}
+
+// @Description Authorization response the provider redirected back with
+type OidcFinishRequest struct {
+	Code  string `json:"code" example:"4/0AX4XfWh..." binding:"required" description:"code query parameter from the redirect"`
+	State string `json:"state" example:"q3ZgE1r..." binding:"required" description:"state query parameter from the redirect"`
+}
+
+// @Description Authorization request parameters, as the consent page received them in its query string
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type" example:"code" binding:"required"`
+	ClientID            string `json:"client_id" example:"Xk3vQ9aBc1dE2fG3" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" example:"https://wiki.example.com/callback" binding:"required" format:"uri"`
+	Scope               string `json:"scope" example:"openid profile email" binding:"required" description:"Space separated scopes"`
+	State               string `json:"state" example:"af0ifjsldkj"`
+	Nonce               string `json:"nonce" example:"n-0S6_WzA2Mj" maxLength:"255"`
+	CodeChallenge       string `json:"code_challenge" example:"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" binding:"required" description:"S256 PKCE code challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method" example:"S256" binding:"required"`
+}
+
+// @Description The user's answer to an authorization request
+type OAuthDecisionRequest struct {
+	OAuthAuthorizeRequest
+	Approve bool `json:"approve" example:"true" description:"Whether the user allows the client the requested scopes"`
+}
+
+// @Description What a client asks for, to show on the consent page
+type OAuthConsentResponse struct {
+	ClientID   string   `json:"client_id" example:"Xk3vQ9aBc1dE2fG3"`
+	ClientName string   `json:"client_name" example:"Wiki"`
+	Scopes     []string `json:"scopes" example:"openid,profile,email"`
+	Granted    bool     `json:"granted" example:"false" description:"The user already allowed every requested scope, so the page may approve without asking again"`
+}
+
+// @Description Where to send the browser back to the client
+type OAuthRedirectResponse struct {
+	RedirectTo string `json:"redirect_to" example:"https://wiki.example.com/callback?code=SplxlOBeZQQYbYS6WxSbIA&state=af0ifjsldkj&iss=https%3A%2F%2Fauth.example.com" description:"Client redirect URI carrying the code or the error"`
+}
+
+// @Description Tokens issued to an OAuth client (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token" example:"eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"3600"`
+	RefreshToken string `json:"refresh_token,omitempty" example:"eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9..." description:"Issued when the client may use the refresh_token grant"`
+	IDToken      string `json:"id_token,omitempty" example:"eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCJ9..." description:"Issued when the openid scope was granted"`
+	Scope        string `json:"scope" example:"openid profile email"`
+}
+
+// @Description OAuth error response (RFC 6749 section 5.2)
+type OAuthErrorResponse struct {
+	Error       string `json:"error" example:"invalid_grant"`
+	Description string `json:"error_description,omitempty" example:"invalid or expired code"`
+}
+
+// @Description OpenID Connect claims about the user, limited to the granted scopes
+type UserInfoResponse struct {
+	Subject           string `json:"sub" example:"123456789"`
+	Name              string `json:"name,omitempty" example:"John Doe" description:"With the profile scope"`
+	PreferredUsername string `json:"preferred_username,omitempty" example:"johndoe" description:"With the profile scope"`
+	Picture           string `json:"picture,omitempty" example:"https://example.com/avatar.png" description:"With the profile scope"`
+	Locale            string `json:"locale,omitempty" example:"en-US" description:"With the profile scope"`
+	Zoneinfo          string `json:"zoneinfo,omitempty" example:"Europe/Paris" description:"With the profile scope"`
+	Email             string `json:"email,omitempty" example:"john@example.com" description:"With the email scope"`
+	EmailVerified     *bool  `json:"email_verified,omitempty" example:"true" description:"With the email scope"`
+}
+
+// @Description Device authorization response (RFC 8628 section 3.2)
+type OAuthDeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code" example:"GmRhmhcxhwAzkoEqiMEg_DnyEysNkuNhszIySk9eS" description:"Secret the device polls /auth/oauth/token with"`
+	UserCode                string `json:"user_code" example:"WDJB-MJHT" description:"Code the user types at the verification URI"`
+	VerificationURI         string `json:"verification_uri" example:"https://example.com/device"`
+	VerificationURIComplete string `json:"verification_uri_complete" example:"https://example.com/device?user_code=WDJB-MJHT" description:"Verification URI with the user code filled in, for QR codes"`
+	ExpiresIn               int64  `json:"expires_in" example:"600"`
+	Interval                int64  `json:"interval" example:"5" description:"Seconds to wait between polls"`
+}
+
+// @Description User code shown by a device
+type OAuthDeviceRequest struct {
+	UserCode string `json:"user_code" example:"WDJB-MJHT" binding:"required" description:"Case, spaces and dashes are ignored"`
+}
+
+// @Description The user's answer to a device authorization request
+type OAuthDeviceDecisionRequest struct {
+	OAuthDeviceRequest
+	Approve bool `json:"approve" example:"true" description:"Whether the user signs the device in with the requested scopes"`
+}
+
+// @Description Token introspection response (RFC 7662 section 2.2). Only active is set for inactive tokens
+type OAuthIntrospectionResponse struct {
+	Active     bool   `json:"active" example:"true"`
+	Scope      string `json:"scope,omitempty" example:"openid profile" description:"Scopes granted to the client, absent on session tokens"`
+	ClientID   string `json:"client_id,omitempty" example:"Xk3vQ9aBc1dE2fG3" description:"Client the token was issued to, absent on session tokens"`
+	Username   string `json:"username,omitempty" example:"johndoe"`
+	TokenType  string `json:"token_type,omitempty" example:"Bearer" description:"Bearer for access and session tokens, absent on refresh tokens"`
+	Expiration int64  `json:"exp,omitempty" example:"1640998800"`
+	IssuedAt   int64  `json:"iat,omitempty" example:"1640995200"`
+	Subject    string `json:"sub,omitempty" example:"123456789" description:"User the token belongs to, absent on client_credentials tokens"`
+	Issuer     string `json:"iss,omitempty" example:"https://auth.example.com"`
+	TokenID    string `json:"jti,omitempty" example:"0b9f3c2e-8a1d-4c6b-9e7f-2d5a1b3c4e6f"`
+}
@@ -0,0 +1,387 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// pkceChallengeLength is the length of an S256 code challenge, the unpadded
+// base64url encoding of a SHA-256 hash.
+const pkceChallengeLength = 43
+
+// @Summary Authorize an app
+// @Description OAuth 2.0 and OpenID Connect authorization endpoint that registered apps send users to. Only the code flow with S256 PKCE is supported. An unknown client_id or unregistered redirect_uri gets a 400, other problems are reported to the app through its redirect_uri. A valid request is forwarded, query string included, to the OAUTH_CONSENT_URL page, which signs the user in if needed and completes it with /auth/oauth/authorize/consent and /auth/oauth/authorize/decision.
+// @Tags OAuth
+// @Produce json
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "One of the client's registered redirect URIs"
+// @Param scope query string true "Space separated scopes, among openid, profile and email; openid needs an RS256, ES256 or EdDSA signing key"
+// @Param state query string false "Opaque value returned to the client"
+// @Param nonce query string false "Value copied into the ID token"
+// @Param code_challenge query string true "S256 PKCE code challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 302 "Redirect to the consent page, or to the client with an error"
+// @Failure 400 {object} api.ErrorResponse "Unknown client or unregistered redirect URI"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oauth/authorize [get]
+func (ar *AuthRouter) HandleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthAuthorize called")
+	q := r.URL.Query()
+	req := OAuthAuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		Nonce:               q.Get("nonce"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+
+	if client, _ := ar.checkAuthorizeRequest(w, r, req, true); client == nil {
+		return
+	}
+
+	consentURL, err := url.Parse(config.App.OAuthConsentURL)
+	if err != nil {
+		applog.Error("Invalid OAUTH_CONSENT_URL:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	consentURL.RawQuery = r.URL.RawQuery
+	http.Redirect(w, r, consentURL.String(), http.StatusFound)
+}
+
+// @Summary Get an authorization request
+// @Description Validate an authorization request for the consent page and describe what the app asks for. Posts the parameters the page received from /auth/oauth/authorize.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body OAuthAuthorizeRequest true "Authorization request parameters"
+// @Success 200 {object} OAuthConsentResponse "Client and requested scopes"
+// @Failure 400 {object} api.ErrorResponse "Invalid authorization request"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oauth/authorize/consent [post]
+func (ar *AuthRouter) HandleOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthConsent called")
+	req, err := api.DecodeJSON[OAuthAuthorizeRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode authorization request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	client, scope := ar.checkAuthorizeRequest(w, r, req, false)
+	if client == nil {
+		return
+	}
+
+	consent, err := ar.OAuthRepo.GetConsent(r.Context(), user.ID, client.ID)
+	if err != nil {
+		applog.Error("Failed to get consent:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, OAuthConsentResponse{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     strings.Fields(scope),
+		Granted:    consent != nil && consent.Covers(scope),
+	})
+}
+
+// @Summary Answer an authorization request
+// @Description Approve or deny an authorization request on behalf of the signed-in user. The response holds the client redirect URI to send the browser to, carrying a single-use code valid for OAUTH_CODE_EXPIRY seconds, or an access_denied error. Approved scopes are remembered for the consent page.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body OAuthDecisionRequest true "Authorization request parameters and the user's answer"
+// @Success 200 {object} OAuthRedirectResponse "Client redirect"
+// @Failure 400 {object} api.ErrorResponse "Invalid authorization request"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oauth/authorize/decision [post]
+func (ar *AuthRouter) HandleOAuthDecision(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthDecision called")
+	req, err := api.DecodeJSON[OAuthDecisionRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode authorization decision:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	client, scope := ar.checkAuthorizeRequest(w, r, req.OAuthAuthorizeRequest, false)
+	if client == nil {
+		return
+	}
+
+	if !req.Approve {
+		applog.Info("Authorization denied", "userID:", user.ID, "clientID:", client.ID)
+		api.WriteJSON(w, 200, OAuthRedirectResponse{
+			RedirectTo: clientRedirect(req.RedirectURI, url.Values{
+				"error":             {"access_denied"},
+				"error_description": {"the user denied the request"},
+				"state":             {req.State},
+			}),
+		})
+		return
+	}
+
+	if err := ar.saveConsent(r, user, client, scope); err != nil {
+		applog.Error("Failed to save consent:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	code, err := utils.GetRandomToken(32)
+	if err != nil {
+		applog.Error("Failed to generate authorization code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	err = ar.OAuthRepo.CreateCode(r.Context(), &model.OAuthCode{
+		CodeHash:      code.Hash,
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().UTC().Unix() + config.App.OAuthCodeExpiry,
+	})
+	if err != nil {
+		applog.Error("Failed to store authorization code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Authorization approved", "userID:", user.ID, "clientID:", client.ID)
+	api.WriteJSON(w, 200, OAuthRedirectResponse{
+		RedirectTo: clientRedirect(req.RedirectURI, url.Values{
+			"code":  {code.Raw},
+			"state": {req.State},
+		}),
+	})
+}
+
+// @Summary Get user info
+// @Description OpenID Connect userinfo endpoint. Returns the claims about the user an OAuth access token was granted, by scope: profile adds the names, picture, locale and time zone, email adds the email and whether it is confirmed.
+// @Tags OAuth
+// @Produce json
+// @Param Authorization header string true "Bearer access token issued by /auth/oauth/token"
+// @Success 200 {object} UserInfoResponse "Claims about the user"
+// @Failure 401 {object} api.ErrorResponse "Invalid, expired or revoked access token"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oauth/userinfo [get]
+// @Router /auth/oauth/userinfo [post]
+func (ar *AuthRouter) HandleUserInfo(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleUserInfo called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.WriteJSON(w, 200, userInfo(user, utils.ScopeFromContext(r.Context())))
+}
+
+// checkAuthorizeRequest validates an authorization request, returning its client
+// and the requested scopes without duplicates. The response is written when it
+// returns a nil client. A bad client or redirect URI always gets a 400, since
+// the browser must not be sent to an unverified URI. Other problems are sent to
+// the client through its redirect URI when redirect is set, as RFC 6749
+// requires of the authorization endpoint, and get a 400 otherwise.
+func (ar *AuthRouter) checkAuthorizeRequest(w http.ResponseWriter, r *http.Request, req OAuthAuthorizeRequest, redirect bool) (*model.OAuthClient, string) {
+	client, err := ar.OAuthRepo.GetClient(r.Context(), req.ClientID)
+	if err != nil {
+		applog.Error("Failed to get oauth client:", err)
+		api.WriteInternalError(w)
+		return nil, ""
+	}
+
+	if client == nil {
+		api.WriteMessage(w, 400, "error", "unknown client")
+		return nil, ""
+	}
+
+	if !client.HasRedirectURI(req.RedirectURI) {
+		api.WriteMessage(w, 400, "error", "redirect_uri is not registered for this client")
+		return nil, ""
+	}
+
+	fail := func(code, description string) (*model.OAuthClient, string) {
+		applog.Warn("Invalid authorization request", "clientID:", client.ID, "error:", description)
+		if !redirect {
+			api.WriteMessage(w, 400, "error", description)
+			return nil, ""
+		}
+
+		http.Redirect(w, r, clientRedirect(req.RedirectURI, url.Values{
+			"error":             {code},
+			"error_description": {description},
+			"state":             {req.State},
+		}), http.StatusFound)
+		return nil, ""
+	}
+
+	if req.ResponseType != "code" {
+		return fail("unsupported_response_type", "only the code response type is supported")
+	}
+	if !client.AllowsGrant(model.GrantAuthorizationCode) {
+		return fail("unauthorized_client", "client may not use the authorization code grant")
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(req.Scope) {
+		if !client.AllowsScope(s) {
+			return fail("invalid_scope", "scope "+s+" is not allowed for this client")
+		}
+		if s == model.ScopeOpenID && !jwt.CanSignPublic() {
+			return fail("invalid_scope", "openid needs an RS256, ES256 or EdDSA signing key")
+		}
+		if !hasScope(strings.Join(scopes, " "), s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return fail("invalid_scope", "scope is required")
+	}
+
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) != pkceChallengeLength {
+		return fail("invalid_request", "an S256 code_challenge is required")
+	}
+	if len(req.Nonce) > 255 {
+		return fail("invalid_request", "nonce is too long")
+	}
+
+	return client, strings.Join(scopes, " ")
+}
+
+// saveConsent remembers the scopes the user allowed the client on top of those
+// they allowed before, and records newly allowed ones as a security event.
+func (ar *AuthRouter) saveConsent(r *http.Request, user *model.User, client *model.OAuthClient, scope string) error {
+	consent, err := ar.OAuthRepo.GetConsent(r.Context(), user.ID, client.ID)
+	if err != nil {
+		return err
+	}
+
+	if consent != nil && consent.Covers(scope) {
+		return nil
+	}
+
+	granted := scope
+	if consent != nil {
+		for _, s := range strings.Fields(consent.Scope) {
+			if !hasScope(granted, s) {
+				granted += " " + s
+			}
+		}
+	}
+
+	err = ar.OAuthRepo.SaveConsent(r.Context(), &model.OAuthConsent{
+		UserID:    user.ID,
+		ClientID:  client.ID,
+		Scope:     granted,
+		GrantedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Type:      model.OAuthConsentEvent,
+		IPAddress: utils.GetClientIP(r),
+		Details:   client.Name + " (" + client.ID + "): " + granted,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+	return nil
+}
+
+// clientRedirect adds the authorization response to the client's redirect URI,
+// including our issuer (RFC 9207) so the client can tell which server answered.
+// Empty values are left out.
+func clientRedirect(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		if len(v) > 0 && v[0] != "" {
+			q.Set(k, v[0])
+		}
+	}
+	q.Set("iss", config.App.OAuthIssuer)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// userInfo returns the claims about user that scope grants.
+func userInfo(user *model.User, scope string) UserInfoResponse {
+	info := UserInfoResponse{Subject: strconv.FormatInt(user.ID, 10)}
+	if hasScope(scope, model.ScopeProfile) {
+		info.Name = user.DisplayName
+		info.PreferredUsername = user.Username
+		info.Picture = user.AvatarURL
+		info.Locale = user.Locale
+		info.Zoneinfo = user.Timezone
+	}
+	if hasScope(scope, model.ScopeEmail) {
+		info.Email = user.Email
+		info.EmailVerified = &user.EmailConfirmed
+	}
+	return info
+}
+
+func hasScope(scope, s string) bool {
+	for _, f := range strings.Fields(scope) {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
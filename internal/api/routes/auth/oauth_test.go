@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/oidc"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-verifier"
+)
+
+// newOAuthClient registers a confidential client and returns its id and secret.
+func newOAuthClient(t *testing.T, srv *apitest.Server, grants, scopes string) (id, secret string) {
+	t.Helper()
+
+	token, err := utils.GetRandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := model.OAuthClient{
+		ID:           "client-" + strings.ReplaceAll(grants, " ", "-"),
+		Name:         "Test app",
+		SecretHash:   token.Hash,
+		RedirectURIs: testRedirectURI,
+		Scopes:       scopes,
+		GrantTypes:   grants,
+		CreatedAt:    time.Now().UTC().Unix(),
+	}
+	if err := srv.Repos.OAuth.CreateClient(context.Background(), &client); err != nil {
+		t.Fatal(err)
+	}
+	return client.ID, token.Raw
+}
+
+// authorize has the signed in user approve clientID for scope and returns the
+// authorization code, or the status of a refused request.
+func authorize(c *apitest.Client, clientID, scope string) (code string, status int) {
+	c.T.Helper()
+
+	var resp OAuthRedirectResponse
+	status = c.Do("POST", "/auth/oauth/authorize/decision", OAuthDecisionRequest{
+		OAuthAuthorizeRequest: OAuthAuthorizeRequest{
+			ResponseType:        "code",
+			ClientID:            clientID,
+			RedirectURI:         testRedirectURI,
+			Scope:               scope,
+			State:               "xyz",
+			CodeChallenge:       oidc.Challenge(testVerifier),
+			CodeChallengeMethod: "S256",
+		},
+		Approve: true,
+	}, &resp)
+	if status != 200 {
+		return "", status
+	}
+
+	u, err := url.Parse(resp.RedirectTo)
+	if err != nil {
+		c.T.Fatal(err)
+	}
+	return u.Query().Get("code"), status
+}
+
+// exchange redeems code at the token endpoint as the client.
+func exchange(app *apitest.Client, clientID, secret, code, redirectURI, verifier string, out any) int {
+	app.T.Helper()
+	return app.Form("/auth/oauth/token", url.Values{
+		"grant_type":    {model.GrantAuthorizationCode},
+		"client_id":     {clientID},
+		"client_secret": {secret},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}, out)
+}
+
+func TestOAuthCodeExchange(t *testing.T) {
+	srv := newTestServer(t)
+	user := srv.NewClient(t)
+	user.Register("alice", "alice@example.com")
+	clientID, secret := newOAuthClient(t, srv, "authorization_code refresh_token", "profile email")
+
+	code, status := authorize(user, clientID, "profile")
+	if status != 200 || code == "" {
+		t.Fatalf("authorize: status %d", status)
+	}
+
+	app := srv.NewClient(t)
+	var tokens OAuthTokenResponse
+	if status := exchange(app, clientID, secret, code, testRedirectURI, testVerifier, &tokens); status != 200 {
+		t.Fatalf("exchange: status %d", status)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.Scope != "profile" {
+		t.Fatalf("unexpected tokens %+v", tokens)
+	}
+
+	var failure OAuthErrorResponse
+	if status := exchange(app, clientID, secret, code, testRedirectURI, testVerifier, &failure); status != 400 || failure.Error != "invalid_grant" {
+		t.Fatalf("second exchange: want 400 invalid_grant, got %d %q", status, failure.Error)
+	}
+}
+
+func TestOAuthCodeChecks(t *testing.T) {
+	srv := newTestServer(t)
+	user := srv.NewClient(t)
+	user.Register("alice", "alice@example.com")
+	clientID, secret := newOAuthClient(t, srv, "authorization_code", "profile")
+	app := srv.NewClient(t)
+
+	tests := []struct {
+		name                  string
+		redirectURI, verifier string
+	}{
+		{"pkce mismatch", testRedirectURI, strings.Repeat("a", 43)},
+		{"missing verifier", testRedirectURI, ""},
+		{"redirect_uri mismatch", "https://app.example.com/other", testVerifier},
+	}
+	for _, tt := range tests {
+		code, status := authorize(user, clientID, "profile")
+		if status != 200 {
+			t.Fatalf("authorize: status %d", status)
+		}
+
+		var failure OAuthErrorResponse
+		if status := exchange(app, clientID, secret, code, tt.redirectURI, tt.verifier, &failure); status != 400 || failure.Error != "invalid_grant" {
+			t.Errorf("%s: want 400 invalid_grant, got %d %q", tt.name, status, failure.Error)
+		}
+
+		// a failed attempt burns the code
+		if status := exchange(app, clientID, secret, code, testRedirectURI, testVerifier, nil); status != 400 {
+			t.Errorf("%s: code usable after a failed exchange, status %d", tt.name, status)
+		}
+	}
+}
+
+func TestOAuthRefreshRotation(t *testing.T) {
+	srv := newTestServer(t)
+	user := srv.NewClient(t)
+	user.Register("alice", "alice@example.com")
+	clientID, secret := newOAuthClient(t, srv, "authorization_code refresh_token", "profile email")
+	app := srv.NewClient(t)
+
+	code, _ := authorize(user, clientID, "profile email")
+	var first OAuthTokenResponse
+	if status := exchange(app, clientID, secret, code, testRedirectURI, testVerifier, &first); status != 200 {
+		t.Fatalf("exchange: status %d", status)
+	}
+
+	refresh := func(token, scope string, out any) int {
+		return app.Form("/auth/oauth/token", url.Values{
+			"grant_type":    {model.GrantRefreshToken},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"refresh_token": {token},
+			"scope":         {scope},
+		}, out)
+	}
+
+	if status := refresh(first.RefreshToken, "openid", nil); status != 400 {
+		t.Fatalf("refresh widening the scope: want 400, got %d", status)
+	}
+
+	var second OAuthTokenResponse
+	if status := refresh(first.RefreshToken, "profile", &second); status != 200 {
+		t.Fatalf("refresh: status %d", status)
+	}
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken || second.Scope != "profile" {
+		t.Fatalf("refresh token was not rotated or scope not narrowed: %+v", second)
+	}
+
+	var failure OAuthErrorResponse
+	if status := refresh(first.RefreshToken, "", &failure); status != 400 || failure.Error != "invalid_grant" {
+		t.Fatalf("replayed refresh token: want 400 invalid_grant, got %d %q", status, failure.Error)
+	}
+	if status := refresh(second.RefreshToken, "", nil); status != 400 {
+		t.Fatalf("refresh after reuse revoked the grant: want 400, got %d", status)
+	}
+}
+
+func TestOAuthClientCredentialsScopes(t *testing.T) {
+	srv := newTestServer(t)
+	clientID, secret := newOAuthClient(t, srv, "authorization_code client_credentials", "openid profile")
+	otherID, otherSecret := newOAuthClient(t, srv, "authorization_code", "profile")
+	app := srv.NewClient(t)
+
+	credentials := func(id, secret, scope string, out any) int {
+		return app.Form("/auth/oauth/token", url.Values{
+			"grant_type":    {model.GrantClientCredentials},
+			"client_id":     {id},
+			"client_secret": {secret},
+			"scope":         {scope},
+		}, out)
+	}
+
+	var tokens OAuthTokenResponse
+	if status := credentials(clientID, secret, "profile", &tokens); status != 200 {
+		t.Fatalf("client_credentials: status %d", status)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken != "" || tokens.IDToken != "" || tokens.Scope != "profile" {
+		t.Fatalf("unexpected tokens %+v", tokens)
+	}
+
+	for _, scope := range []string{"email", "openid", "profile admin"} {
+		var failure OAuthErrorResponse
+		if status := credentials(clientID, secret, scope, &failure); status != 400 || failure.Error != "invalid_scope" {
+			t.Errorf("scope %q: want 400 invalid_scope, got %d %q", scope, status, failure.Error)
+		}
+	}
+
+	var failure OAuthErrorResponse
+	if status := credentials(otherID, otherSecret, "profile", &failure); status != 400 || failure.Error != "unauthorized_client" {
+		t.Fatalf("client without the grant: want 400 unauthorized_client, got %d %q", status, failure.Error)
+	}
+	if status := credentials(clientID, "wrong", "profile", nil); status != 401 {
+		t.Fatalf("wrong secret: want 401, got %d", status)
+	}
+}
+
+func TestOAuthOpenIDNeedsPublishedKey(t *testing.T) {
+	srv := newTestServer(t)
+	user := srv.NewClient(t)
+	user.Register("alice", "alice@example.com")
+	clientID, secret := newOAuthClient(t, srv, "authorization_code refresh_token", "openid profile")
+
+	if _, status := authorize(user, clientID, "openid profile"); status != 400 {
+		t.Fatalf("openid with an HS256 key: want 400, got %d", status)
+	}
+	var codes int
+	if err := srv.DB.Get(&codes, "SELECT COUNT(*) FROM oauth_codes"); err != nil {
+		t.Fatal(err)
+	}
+	if codes != 0 {
+		t.Fatalf("refused request stored %d codes", codes)
+	}
+
+	// the session token was signed with the old key
+	apitest.UseKey(t, jwt.ES256)
+	user.Login("alice")
+
+	code, status := authorize(user, clientID, "openid profile")
+	if status != 200 {
+		t.Fatalf("openid with an ES256 key: status %d", status)
+	}
+	var tokens OAuthTokenResponse
+	if status := exchange(srv.NewClient(t), clientID, secret, code, testRedirectURI, testVerifier, &tokens); status != 200 {
+		t.Fatalf("exchange: status %d", status)
+	}
+	if tokens.IDToken == "" {
+		t.Fatal("no id token for the openid scope")
+	}
+}
@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// slowDownStep is how many seconds a device's poll interval grows by each time
+// it polls too fast (RFC 8628 section 3.5).
+const slowDownStep = 5
+
+// @Summary Start a device authorization
+// @Description OAuth 2.0 device authorization endpoint (RFC 8628) for CLIs and other devices without a usable browser. Takes a form body and authenticates the client like /auth/oauth/token. The device shows the user_code and verification_uri to the user, who approves it on the OAUTH_DEVICE_URL page through /auth/oauth/device/consent and /auth/oauth/device/decision, and meanwhile polls /auth/oauth/token with the device code grant every interval seconds. The request expires after OAUTH_DEVICE_CODE_EXPIRY seconds. Errors follow RFC 6749 section 5.2.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param scope formData string true "Space separated scopes, among openid, profile and email; openid needs an RS256, ES256 or EdDSA signing key"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 {object} OAuthDeviceAuthorizationResponse "Codes to show the user and poll with"
+// @Failure 400 {object} OAuthErrorResponse "Invalid request or scope, or the client may not use the device code grant"
+// @Failure 401 {object} OAuthErrorResponse "Client authentication failed"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} OAuthErrorResponse "Internal server error"
+// @Router /auth/oauth/device [post]
+func (ar *AuthRouter) HandleOAuthDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthDeviceAuthorization called")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, 400, "invalid_request", "invalid form body")
+		return
+	}
+
+	client := ar.authenticateClient(w, r)
+	if client == nil {
+		return
+	}
+
+	if !client.AllowsGrant(model.GrantDeviceCode) {
+		writeOAuthError(w, 400, "unauthorized_client", "client may not use the device code grant")
+		return
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(r.PostForm.Get("scope")) {
+		if !client.AllowsScope(s) {
+			writeOAuthError(w, 400, "invalid_scope", "scope "+s+" is not allowed for this client")
+			return
+		}
+		if s == model.ScopeOpenID && !jwt.CanSignPublic() {
+			writeOAuthError(w, 400, "invalid_scope", "openid needs an RS256, ES256 or EdDSA signing key")
+			return
+		}
+		if !hasScope(strings.Join(scopes, " "), s) {
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		writeOAuthError(w, 400, "invalid_scope", "scope is required")
+		return
+	}
+
+	verificationURL, err := url.Parse(config.App.OAuthDeviceURL)
+	if err != nil {
+		applog.Error("Invalid OAUTH_DEVICE_URL:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	deviceCode, err := utils.GetRandomToken(32)
+	if err != nil {
+		applog.Error("Failed to generate device code:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	userCode, err := utils.GetRandomUserCode()
+	if err != nil {
+		applog.Error("Failed to generate user code:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	err = ar.OAuthRepo.CreateDeviceCode(r.Context(), &model.OAuthDeviceCode{
+		DeviceCodeHash: deviceCode.Hash,
+		UserCodeHash:   userCode.Hash,
+		ClientID:       client.ID,
+		Scope:          strings.Join(scopes, " "),
+		Status:         model.DeviceCodePending,
+		PollInterval:   config.App.OAuthDevicePollInterval,
+		ExpiresAt:      time.Now().UTC().Unix() + config.App.OAuthDeviceCodeExpiry,
+	})
+	if err != nil {
+		applog.Error("Failed to store device code:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	complete := *verificationURL
+	q := complete.Query()
+	q.Set("user_code", userCode.Raw)
+	complete.RawQuery = q.Encode()
+
+	applog.Info("Device authorization started", "clientID:", client.ID)
+	api.WriteJSON(w, 200, OAuthDeviceAuthorizationResponse{
+		DeviceCode:              deviceCode.Raw,
+		UserCode:                userCode.Raw,
+		VerificationURI:         verificationURL.String(),
+		VerificationURIComplete: complete.String(),
+		ExpiresIn:               config.App.OAuthDeviceCodeExpiry,
+		Interval:                config.App.OAuthDevicePollInterval,
+	})
+}
+
+// @Summary Get a device authorization request
+// @Description Look up the device authorization request a user code belongs to, for the device page to show which app asks for what before the user approves it. Since anyone can show a user a code, the page should ask the user to confirm even when granted is set.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body OAuthDeviceRequest true "User code"
+// @Success 200 {object} OAuthConsentResponse "Client and requested scopes"
+// @Failure 400 {object} api.ErrorResponse "Invalid or expired user code"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oauth/device/consent [post]
+func (ar *AuthRouter) HandleOAuthDeviceConsent(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthDeviceConsent called")
+	req, err := api.DecodeJSON[OAuthDeviceRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode device request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	code, client := ar.pendingDeviceCode(w, r, req.UserCode)
+	if code == nil {
+		return
+	}
+
+	consent, err := ar.OAuthRepo.GetConsent(r.Context(), user.ID, client.ID)
+	if err != nil {
+		applog.Error("Failed to get consent:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, OAuthConsentResponse{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     strings.Fields(code.Scope),
+		Granted:    consent != nil && consent.Covers(code.Scope),
+	})
+}
+
+// @Summary Answer a device authorization request
+// @Description Approve or deny a device authorization request on behalf of the signed-in user. The device gets its tokens, or access_denied, on its next poll. Approvals are recorded in the user's security events.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body OAuthDeviceDecisionRequest true "User code and the user's answer"
+// @Success 200 {object} api.SuccessResponse "Answer recorded"
+// @Failure 400 {object} api.ErrorResponse "Invalid, expired or already answered user code"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oauth/device/decision [post]
+func (ar *AuthRouter) HandleOAuthDeviceDecision(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthDeviceDecision called")
+	req, err := api.DecodeJSON[OAuthDeviceDecisionRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode device decision:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	code, client := ar.pendingDeviceCode(w, r, req.UserCode)
+	if code == nil {
+		return
+	}
+
+	status := model.DeviceCodeDenied
+	if req.Approve {
+		status = model.DeviceCodeApproved
+	}
+
+	answered, err := ar.OAuthRepo.AnswerDeviceCode(r.Context(), code.UserCodeHash, user.ID, status)
+	if err != nil {
+		applog.Error("Failed to answer device code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !answered {
+		api.WriteMessage(w, 400, "error", "invalid or expired code")
+		return
+	}
+
+	if !req.Approve {
+		applog.Info("Device authorization denied", "userID:", user.ID, "clientID:", client.ID)
+		api.WriteMessage(w, 200, "message", "device denied")
+		return
+	}
+
+	if err := ar.saveConsent(r, user, client, code.Scope); err != nil {
+		applog.Error("Failed to save consent:", err)
+	}
+
+	err = ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Type:      model.OAuthDeviceApprovedEvent,
+		IPAddress: utils.GetClientIP(r),
+		Details:   client.Name + " (" + client.ID + "): " + code.Scope,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	applog.Info("Device authorization approved", "userID:", user.ID, "clientID:", client.ID)
+	api.WriteMessage(w, 200, "message", "device approved")
+}
+
+// pendingDeviceCode looks up the unanswered request for a user code and its
+// client, writing the response itself when there is none.
+func (ar *AuthRouter) pendingDeviceCode(w http.ResponseWriter, r *http.Request, userCode string) (*model.OAuthDeviceCode, *model.OAuthClient) {
+	code, err := ar.OAuthRepo.GetPendingDeviceCode(r.Context(), utils.HashUserCode(userCode))
+	if err != nil {
+		applog.Error("Failed to get device code:", err)
+		api.WriteInternalError(w)
+		return nil, nil
+	}
+
+	if code == nil {
+		applog.Warn("Invalid or expired user code", "ip:", utils.GetClientIP(r))
+		api.WriteMessage(w, 400, "error", "invalid or expired code")
+		return nil, nil
+	}
+
+	client, err := ar.OAuthRepo.GetClient(r.Context(), code.ClientID)
+	if err != nil {
+		applog.Error("Failed to get oauth client:", err)
+		api.WriteInternalError(w)
+		return nil, nil
+	}
+
+	if client == nil {
+		api.WriteMessage(w, 400, "error", "invalid or expired code")
+		return nil, nil
+	}
+
+	return code, client
+}
+
+// pollDeviceCode answers a device polling for the outcome of its device
+// authorization, issuing its tokens once the user approved it.
+func (ar *AuthRouter) pollDeviceCode(w http.ResponseWriter, r *http.Request, client *model.OAuthClient) {
+	hash, err := utils.HashToken(r.PostForm.Get("device_code"))
+	if err != nil {
+		writeOAuthError(w, 400, "invalid_grant", "invalid device code")
+		return
+	}
+
+	code, err := ar.OAuthRepo.GetDeviceCode(r.Context(), hash)
+	if err != nil {
+		applog.Error("Failed to get device code:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	if code == nil || code.ClientID != client.ID {
+		applog.Warn("Invalid device code", "clientID:", client.ID)
+		writeOAuthError(w, 400, "invalid_grant", "invalid device code")
+		return
+	}
+
+	now := time.Now().UTC().Unix()
+	if code.ExpiresAt <= now {
+		if _, err := ar.OAuthRepo.DeleteDeviceCode(r.Context(), hash); err != nil {
+			applog.Error("Failed to delete device code:", err)
+		}
+		writeOAuthError(w, 400, "expired_token", "the device code has expired")
+		return
+	}
+
+	switch code.Status {
+	case model.DeviceCodePending:
+		interval := code.PollInterval
+		tooFast := code.LastPolledAt != 0 && now < code.LastPolledAt+code.PollInterval
+		if tooFast {
+			interval += slowDownStep
+		}
+
+		if err := ar.OAuthRepo.RecordDevicePoll(r.Context(), hash, now, interval); err != nil {
+			applog.Error("Failed to record device poll:", err)
+			writeOAuthError(w, 500, "server_error", "")
+			return
+		}
+
+		if tooFast {
+			writeOAuthError(w, 400, "slow_down", "poll less often")
+			return
+		}
+		writeOAuthError(w, 400, "authorization_pending", "the user has not answered yet")
+		return
+
+	case model.DeviceCodeDenied:
+		if _, err := ar.OAuthRepo.DeleteDeviceCode(r.Context(), hash); err != nil {
+			applog.Error("Failed to delete device code:", err)
+		}
+		writeOAuthError(w, 400, "access_denied", "the user denied the request")
+		return
+	}
+
+	deleted, err := ar.OAuthRepo.DeleteDeviceCode(r.Context(), hash)
+	if err != nil {
+		applog.Error("Failed to delete device code:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	if !deleted {
+		writeOAuthError(w, 400, "invalid_grant", "invalid device code")
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), code.UserID)
+	if err != nil {
+		applog.Warn("Device code approved by missing user", "userID:", code.UserID, "err:", err)
+		writeOAuthError(w, 400, "invalid_grant", "invalid device code")
+		return
+	}
+
+	if ar.startOAuthSession(w, r, user, client, code.Scope, "") {
+		applog.Info("Device code exchanged", "userID:", user.ID, "clientID:", client.ID)
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/model"
+)
+
+// startDevice has a device client start an authorization and returns a poll
+// function that reports the OAuth error, or "" once tokens were issued.
+func startDevice(t *testing.T, srv *apitest.Server) (userCode string, poll func() string) {
+	t.Helper()
+
+	clientID, secret := newOAuthClient(t, srv, model.GrantDeviceCode, "profile")
+	device := srv.NewClient(t)
+
+	var start OAuthDeviceAuthorizationResponse
+	if status := device.Form("/auth/oauth/device", url.Values{"client_id": {clientID}, "client_secret": {secret}, "scope": {"profile"}}, &start); status != 200 {
+		t.Fatalf("device authorization: status %d", status)
+	}
+
+	return start.UserCode, func() string {
+		var resp struct {
+			OAuthTokenResponse
+			OAuthErrorResponse
+		}
+		status := device.Form("/auth/oauth/token", url.Values{
+			"grant_type":    {model.GrantDeviceCode},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+			"device_code":   {start.DeviceCode},
+		}, &resp)
+		if status == 200 && resp.AccessToken == "" {
+			t.Fatal("poll: 200 without an access token")
+		}
+		return resp.Error
+	}
+}
+
+// waitInterval moves the last poll back by the current interval, as if the
+// device had waited for it.
+func waitInterval(srv *apitest.Server) {
+	srv.DB.MustExec("UPDATE oauth_device_codes SET last_polled_at = last_polled_at - poll_interval")
+}
+
+func TestDevicePolling(t *testing.T) {
+	srv := newTestServer(t)
+	_, poll := startDevice(t, srv)
+
+	if got := poll(); got != "authorization_pending" {
+		t.Fatalf("first poll: want authorization_pending, got %q", got)
+	}
+	if got := poll(); got != "slow_down" {
+		t.Fatalf("immediate second poll: want slow_down, got %q", got)
+	}
+
+	var interval int64
+	if err := srv.DB.Get(&interval, "SELECT poll_interval FROM oauth_device_codes"); err != nil {
+		t.Fatal(err)
+	}
+	if want := config.App.OAuthDevicePollInterval + slowDownStep; interval != want {
+		t.Fatalf("interval after slow_down: want %d, got %d", want, interval)
+	}
+
+	waitInterval(srv)
+	if got := poll(); got != "authorization_pending" {
+		t.Fatalf("poll after waiting the longer interval: want authorization_pending, got %q", got)
+	}
+
+	srv.DB.MustExec("UPDATE oauth_device_codes SET expires_at = 0")
+	if got := poll(); got != "expired_token" {
+		t.Fatalf("poll after expiry: want expired_token, got %q", got)
+	}
+	if got := poll(); got != "invalid_grant" {
+		t.Fatalf("poll after expired_token: want invalid_grant, got %q", got)
+	}
+}
+
+func TestDeviceDecision(t *testing.T) {
+	for _, approve := range []bool{true, false} {
+		srv := newTestServer(t)
+		user := srv.NewClient(t)
+		user.Register("alice", "alice@example.com")
+		userCode, poll := startDevice(t, srv)
+
+		if got := poll(); got != "authorization_pending" {
+			t.Fatalf("poll before the answer: want authorization_pending, got %q", got)
+		}
+		if status := user.Do("POST", "/auth/oauth/device/decision", OAuthDeviceDecisionRequest{OAuthDeviceRequest: OAuthDeviceRequest{UserCode: userCode}, Approve: approve}, nil); status != 200 {
+			t.Fatalf("decision: status %d", status)
+		}
+		if status := user.Do("POST", "/auth/oauth/device/decision", OAuthDeviceDecisionRequest{OAuthDeviceRequest: OAuthDeviceRequest{UserCode: userCode}, Approve: !approve}, nil); status != 400 {
+			t.Fatalf("second decision: want 400, got %d", status)
+		}
+
+		want := "access_denied"
+		if approve {
+			want = ""
+		}
+		if got := poll(); got != want {
+			t.Fatalf("approve %v: want %q, got %q", approve, want, got)
+		}
+		if got := poll(); got != "invalid_grant" {
+			t.Fatalf("approve %v, poll after the outcome: want invalid_grant, got %q", approve, got)
+		}
+	}
+}
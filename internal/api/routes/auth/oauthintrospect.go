@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+)
+
+// @Summary Introspect a token
+// @Description OAuth 2.0 token introspection (RFC 7662) for services that cannot verify tokens themselves, such as with HS256 signing. Takes a form body and only answers confidential clients, authenticated like /auth/oauth/token. Session tokens, access tokens and client_credentials tokens are active when their signature and expiry are valid, they were not revoked, and, for tokens belonging to a user, the user was not signed out everywhere since, the session is still open and the account can sign in. A client can also introspect refresh tokens issued to itself. Any other token is reported inactive, with nothing else.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token, ignored since tokens say what they are"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 {object} OAuthIntrospectionResponse "Whether the token is active, and about whom"
+// @Failure 400 {object} OAuthErrorResponse "Invalid request"
+// @Failure 401 {object} OAuthErrorResponse "Client authentication failed, or the client is public"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} OAuthErrorResponse "Internal server error"
+// @Router /auth/oauth/introspect [post]
+func (ar *AuthRouter) HandleOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthIntrospect called")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, 400, "invalid_request", "invalid form body")
+		return
+	}
+
+	client := ar.authenticateClient(w, r)
+	if client == nil {
+		return
+	}
+
+	if client.IsPublic() {
+		writeInvalidClient(w, "public clients cannot introspect tokens")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, 400, "invalid_request", "token is required")
+		return
+	}
+
+	claims, user, err := ar.activeToken(r.Context(), token, client)
+	if err != nil {
+		applog.Error("Failed to introspect token:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	if claims == nil {
+		api.WriteJSON(w, 200, OAuthIntrospectionResponse{Active: false})
+		return
+	}
+
+	resp := OAuthIntrospectionResponse{
+		Active:     true,
+		Scope:      claims.Scope,
+		ClientID:   claims.ClientID,
+		Expiration: claims.Expiration,
+		IssuedAt:   claims.IssuedAt,
+		Issuer:     config.App.OAuthIssuer,
+		TokenID:    claims.TokenID,
+	}
+	if claims.Type != model.OAuthRefreshJwt {
+		resp.TokenType = "Bearer"
+	}
+	if user != nil {
+		resp.Subject = strconv.FormatInt(user.ID, 10)
+		resp.Username = user.Username
+	}
+
+	applog.Info("Token introspected", "clientID:", client.ID, "type:", claims.Type)
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Revoke a token
+// @Description OAuth 2.0 token revocation (RFC 7009). Takes a form body and authenticates the client like /auth/oauth/token; a client can only revoke tokens issued to itself, and tokens of other clients or of this server's own sessions are ignored like invalid ones, so a client cannot probe them. Users end their own sessions with /auth/logout or DELETE /auth/sessions/{id}. Revoking an access or refresh token issued for a user ends the whole authorization: its refresh tokens and the access tokens issued with them are blacklisted and its session is revoked. Revoking a client_credentials token blacklists it. Tokens that are invalid, expired or already revoked are ignored, as the RFC requires.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "Token to revoke"
+// @Param token_type_hint formData string false "access_token or refresh_token, ignored since tokens say what they are"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 "Token revoked, or was not valid or not issued to this client"
+// @Failure 400 {object} OAuthErrorResponse "Invalid request"
+// @Failure 401 {object} OAuthErrorResponse "Client authentication failed"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} OAuthErrorResponse "Internal server error"
+// @Router /auth/oauth/revoke [post]
+func (ar *AuthRouter) HandleOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthRevoke called")
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, 400, "invalid_request", "invalid form body")
+		return
+	}
+
+	client := ar.authenticateClient(w, r)
+	if client == nil {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, 400, "invalid_request", "token is required")
+		return
+	}
+
+	claims, err := jwt.ValidateToken(token, ar.TokenRepo)
+	if err != nil {
+		w.WriteHeader(200)
+		return
+	}
+
+	// first-party session tokens have no client, so they always end up here
+	if claims.ClientID != client.ID {
+		applog.Warn("Client tried to revoke a token it was not issued", "clientID:", client.ID, "type:", claims.Type)
+		w.WriteHeader(200)
+		return
+	}
+
+	switch claims.Type {
+	case model.OAuthAccessJwt, model.OAuthRefreshJwt:
+		err = ar.revokeOAuthGrant(r.Context(), claims)
+	case model.OAuthClientJwt:
+		err = ar.TokenRepo.RevokeToken(r.Context(), model.JwtBlacklist{
+			TokenID:   claims.TokenID,
+			ExpiresAt: claims.Expiration,
+		})
+	}
+	if err != nil {
+		applog.Error("Failed to revoke token:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	applog.Info("Token revoked by client", "clientID:", client.ID, "userID:", claims.UserID, "type:", claims.Type)
+	w.WriteHeader(200)
+}
+
+// revokeOAuthGrant ends a user's authorization of a client. The token itself is
+// blacklisted as well as its family, since a client that cannot refresh has no
+// refresh tokens for the family to cover.
+func (ar *AuthRouter) revokeOAuthGrant(ctx context.Context, claims *jwt.Claims) error {
+	err := ar.TokenRepo.RevokeToken(ctx, model.JwtBlacklist{
+		TokenID:   claims.TokenID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.Expiration,
+	})
+	if err != nil {
+		return err
+	}
+
+	if claims.FamilyID == 0 {
+		return nil
+	}
+
+	if err := ar.TokenRepo.RevokeTokenFamily(ctx, claims.FamilyID); err != nil {
+		return err
+	}
+
+	_, err = ar.SessionRepo.RevokeSession(ctx, claims.UserID, claims.FamilyID)
+	return err
+}
+
+// activeToken returns the claims of token if it is active for caller to
+// introspect, along with the user it belongs to, if any. It returns nil claims
+// for inactive tokens and only errors when the checks themselves fail.
+func (ar *AuthRouter) activeToken(ctx context.Context, token string, caller *model.OAuthClient) (*jwt.Claims, *model.User, error) {
+	claims, err := jwt.ValidateToken(token, ar.TokenRepo)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	switch claims.Type {
+	case model.CredentialJwt, model.OAuthAccessJwt:
+	case model.OAuthClientJwt:
+		client, err := ar.OAuthRepo.GetClient(ctx, claims.ClientID)
+		if err != nil || client == nil {
+			return nil, nil, err
+		}
+		return claims, nil, nil
+	case model.OAuthRefreshJwt:
+		if claims.ClientID != caller.ID {
+			return nil, nil, nil
+		}
+		stored, err := ar.TokenRepo.GetRefreshToken(ctx, claims.TokenID)
+		if err != nil || stored == nil || stored.Revoked || stored.RotatedAt != 0 {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, nil
+	}
+
+	user, err := ar.UserRepo.GetUserByID(ctx, claims.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if claims.SessionID != user.JwtSessionID || !user.CanSignIn(time.Now().UTC().Unix()) {
+		return nil, nil, nil
+	}
+
+	if claims.FamilyID != 0 {
+		revoked, err := ar.SessionRepo.IsSessionRevoked(ctx, claims.FamilyID)
+		if err != nil || revoked {
+			return nil, nil, err
+		}
+	}
+
+	return claims, user, nil
+}
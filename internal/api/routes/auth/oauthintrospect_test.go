@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/google/uuid"
+)
+
+// grantTokens has the signed in user approve clientID and returns the tokens
+// the client gets for the code.
+func grantTokens(user *apitest.Client, clientID, secret, scope string) OAuthTokenResponse {
+	user.T.Helper()
+
+	code, status := authorize(user, clientID, scope)
+	if status != 200 {
+		user.T.Fatalf("authorize: status %d", status)
+	}
+	var tokens OAuthTokenResponse
+	if status := exchange(user.Server.NewClient(user.T), clientID, secret, code, testRedirectURI, testVerifier, &tokens); status != 200 {
+		user.T.Fatalf("exchange: status %d", status)
+	}
+	return tokens
+}
+
+// introspect asks whether token is active as the client.
+func introspect(app *apitest.Client, clientID, secret, token string) OAuthIntrospectionResponse {
+	app.T.Helper()
+
+	var resp OAuthIntrospectionResponse
+	status := app.Form("/auth/oauth/introspect", url.Values{"client_id": {clientID}, "client_secret": {secret}, "token": {token}}, &resp)
+	if status != 200 {
+		app.T.Fatalf("introspect: status %d", status)
+	}
+	return resp
+}
+
+func TestOAuthIntrospect(t *testing.T) {
+	srv := newTestServer(t)
+	user := srv.NewClient(t)
+	user.Register("alice", "alice@example.com")
+	clientID, secret := newOAuthClient(t, srv, "authorization_code refresh_token", "profile")
+	app := srv.NewClient(t)
+
+	tokens := grantTokens(user, clientID, secret, "profile")
+	resp := introspect(app, clientID, secret, tokens.AccessToken)
+	want := fmt.Sprint(srv.UserID(t, "alice@example.com"))
+	if !resp.Active || resp.ClientID != clientID || resp.Subject != want || resp.Username != "alice" || resp.Scope != "profile" || resp.TokenType != "Bearer" {
+		t.Fatalf("active access token: unexpected response %+v", resp)
+	}
+
+	now := time.Now().UTC().Unix()
+	expired, err := jwt.CreateJwt(jwt.Claims{
+		TokenID:    uuid.New().String(),
+		IssuedAt:   now - 120,
+		Expiration: now - 60,
+		Type:       model.OAuthClientJwt,
+		ClientID:   clientID,
+	}).GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := introspect(app, clientID, secret, expired); resp != (OAuthIntrospectionResponse{}) {
+		t.Fatalf("expired token: want only active false, got %+v", resp)
+	}
+
+	// the user signs the app out from their session list
+	var sessions []SessionResponse
+	if status := user.Do("GET", "/auth/sessions", nil, &sessions); status != 200 {
+		t.Fatalf("list sessions: status %d", status)
+	}
+	var grant int64
+	for _, s := range sessions {
+		if s.ClientID == clientID {
+			grant = s.ID
+		}
+	}
+	if status := user.Do("DELETE", fmt.Sprintf("/auth/sessions/%d", grant), nil, nil); status != 200 {
+		t.Fatalf("revoke session %d: status %d", grant, status)
+	}
+
+	for name, token := range map[string]string{"access": tokens.AccessToken, "refresh": tokens.RefreshToken} {
+		if resp := introspect(app, clientID, secret, token); resp.Active {
+			t.Errorf("%s token of a revoked session: still active", name)
+		}
+	}
+}
+
+func TestOAuthRevoke(t *testing.T) {
+	srv := newTestServer(t)
+	user := srv.NewClient(t)
+	user.Register("alice", "alice@example.com")
+	clientID, secret := newOAuthClient(t, srv, "authorization_code refresh_token", "profile")
+	otherID, otherSecret := newOAuthClient(t, srv, "authorization_code", "profile")
+	app := srv.NewClient(t)
+
+	revoke := func(id, secret, token string) int {
+		return app.Form("/auth/oauth/revoke", url.Values{"client_id": {id}, "client_secret": {secret}, "token": {token}}, nil)
+	}
+
+	tokens := grantTokens(user, clientID, secret, "profile")
+
+	if status := revoke(otherID, otherSecret, tokens.AccessToken); status != 200 {
+		t.Fatalf("another client's token: want 200, got %d", status)
+	}
+	if !introspect(app, clientID, secret, tokens.AccessToken).Active {
+		t.Fatal("another client revoked the token")
+	}
+
+	if status := revoke(clientID, secret, user.Cookie("/", "session")); status != 200 {
+		t.Fatalf("session token: want 200, got %d", status)
+	}
+	if status := user.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("a client signed the user out of this server: status %d", status)
+	}
+
+	if status := revoke(clientID, secret, "not a token"); status != 200 {
+		t.Fatalf("invalid token: want 200, got %d", status)
+	}
+
+	if status := revoke(clientID, secret, tokens.AccessToken); status != 200 {
+		t.Fatalf("revoke: status %d", status)
+	}
+	for name, token := range map[string]string{"access": tokens.AccessToken, "refresh": tokens.RefreshToken} {
+		if introspect(app, clientID, secret, token).Active {
+			t.Errorf("%s token still active after revocation", name)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/oidc"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/google/uuid"
+)
+
+// @Summary Issue tokens to an app
+// @Description OAuth 2.0 token endpoint. Takes a form body and supports the authorization_code grant with the PKCE code_verifier, the refresh_token grant, which rotates the refresh token, the client_credentials grant for confidential clients acting on their own behalf, and the device code grant (RFC 8628), which devices poll with until the user answers, getting authorization_pending, slow_down when polling faster than the interval (which then grows by 5 seconds), access_denied or expired_token. Confidential clients authenticate with HTTP Basic or client_id and client_secret in the body, public clients send only client_id. Access tokens are JWTs signed like session tokens; they are accepted by /auth/oauth/userinfo but not as session tokens. An ID token is included when the openid scope was granted. Errors follow RFC 6749 section 5.2.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, refresh_token, client_credentials or urn:ietf:params:oauth:grant-type:device_code"
+// @Param code formData string false "Authorization code, for authorization_code"
+// @Param redirect_uri formData string false "Redirect URI the code was sent to, for authorization_code"
+// @Param code_verifier formData string false "PKCE code verifier, for authorization_code"
+// @Param refresh_token formData string false "Refresh token, for refresh_token"
+// @Param device_code formData string false "Device code, for the device code grant"
+// @Param scope formData string false "Narrower scope for refresh_token, or the scope for client_credentials"
+// @Param client_id formData string false "Client ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Success 200 {object} OAuthTokenResponse "Issued tokens"
+// @Failure 400 {object} OAuthErrorResponse "Invalid request, grant or scope, or a device authorization still waiting for the user"
+// @Failure 401 {object} OAuthErrorResponse "Client authentication failed"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Failure 500 {object} OAuthErrorResponse "Internal server error"
+// @Router /auth/oauth/token [post]
+func (ar *AuthRouter) HandleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOAuthToken called")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, 400, "invalid_request", "invalid form body")
+		return
+	}
+
+	client := ar.authenticateClient(w, r)
+	if client == nil {
+		return
+	}
+
+	grant := r.PostForm.Get("grant_type")
+	switch grant {
+	case model.GrantAuthorizationCode, model.GrantRefreshToken, model.GrantClientCredentials, model.GrantDeviceCode:
+	default:
+		writeOAuthError(w, 400, "unsupported_grant_type", "unsupported grant_type")
+		return
+	}
+
+	if !client.AllowsGrant(grant) {
+		writeOAuthError(w, 400, "unauthorized_client", "client may not use the "+grant+" grant")
+		return
+	}
+
+	switch grant {
+	case model.GrantAuthorizationCode:
+		ar.exchangeOAuthCode(w, r, client)
+	case model.GrantRefreshToken:
+		ar.refreshOAuthTokens(w, r, client)
+	case model.GrantClientCredentials:
+		ar.issueClientToken(w, r, client)
+	case model.GrantDeviceCode:
+		ar.pollDeviceCode(w, r, client)
+	}
+}
+
+// authenticateClient identifies the client calling the token endpoint from
+// HTTP Basic credentials or the form body. Public clients only name
+// themselves. It writes the response itself when that fails.
+func (ar *AuthRouter) authenticateClient(w http.ResponseWriter, r *http.Request) *model.OAuthClient {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes both before Basic encoding
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	if id == "" {
+		writeInvalidClient(w, "client authentication is required")
+		return nil
+	}
+
+	client, err := ar.OAuthRepo.GetClient(r.Context(), id)
+	if err != nil {
+		applog.Error("Failed to get oauth client:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return nil
+	}
+
+	if client == nil {
+		applog.Warn("Token request for unknown client", "clientID:", id)
+		writeInvalidClient(w, "client authentication failed")
+		return nil
+	}
+
+	if client.IsPublic() {
+		if secret != "" {
+			writeInvalidClient(w, "public clients have no secret")
+			return nil
+		}
+		return client
+	}
+
+	hash, err := utils.HashToken(secret)
+	if secret == "" || err != nil || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+		applog.Warn("Client authentication failed", "clientID:", id)
+		writeInvalidClient(w, "client authentication failed")
+		return nil
+	}
+
+	return client
+}
+
+// exchangeOAuthCode redeems an authorization code.
+func (ar *AuthRouter) exchangeOAuthCode(w http.ResponseWriter, r *http.Request, client *model.OAuthClient) {
+	hash, err := utils.HashToken(r.PostForm.Get("code"))
+	if err != nil {
+		writeOAuthError(w, 400, "invalid_grant", "invalid or expired code")
+		return
+	}
+
+	code, err := ar.OAuthRepo.ConsumeCode(r.Context(), hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		applog.Warn("Invalid or expired authorization code", "clientID:", client.ID)
+		writeOAuthError(w, 400, "invalid_grant", "invalid or expired code")
+		return
+	}
+	if err != nil {
+		applog.Error("Failed to consume authorization code:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != r.PostForm.Get("redirect_uri") {
+		applog.Warn("Authorization code redeemed by another client or for another redirect_uri", "clientID:", client.ID)
+		writeOAuthError(w, 400, "invalid_grant", "invalid or expired code")
+		return
+	}
+
+	verifier := r.PostForm.Get("code_verifier")
+	if len(verifier) < 43 || len(verifier) > 128 || subtle.ConstantTimeCompare([]byte(oidc.Challenge(verifier)), []byte(code.CodeChallenge)) != 1 {
+		applog.Warn("PKCE verification failed", "clientID:", client.ID)
+		writeOAuthError(w, 400, "invalid_grant", "code_verifier does not match the code_challenge")
+		return
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), code.UserID)
+	if err != nil {
+		applog.Warn("Authorization code for missing user", "userID:", code.UserID, "err:", err)
+		writeOAuthError(w, 400, "invalid_grant", "invalid or expired code")
+		return
+	}
+
+	if ar.startOAuthSession(w, r, user, client, code.Scope, code.Nonce) {
+		applog.Info("Authorization code exchanged", "userID:", user.ID, "clientID:", client.ID)
+	}
+}
+
+// startOAuthSession starts a session for the user's authorization of the
+// client, which shows up in the user's session list, and writes its tokens.
+// It reports whether tokens were issued.
+func (ar *AuthRouter) startOAuthSession(w http.ResponseWriter, r *http.Request, user *model.User, client *model.OAuthClient, scope, nonce string) bool {
+	if !user.CanSignIn(time.Now().UTC().Unix()) {
+		writeOAuthError(w, 400, "invalid_grant", "the account is unavailable")
+		return false
+	}
+
+	familyID := utils.GenerateSnowflakeID()
+	tokens, refresh, err := issueOAuthTokens(user, client, scope, familyID, "", nonce)
+	if err != nil {
+		applog.Error("Failed to issue oauth tokens:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return false
+	}
+
+	session := newSession(r, user.ID, familyID, config.App.OAuthRefreshTokenExpiry)
+	session.ClientID = client.ID
+	if err := ar.SessionRepo.CreateSession(r.Context(), session); err != nil {
+		applog.Error("Failed to start session:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return false
+	}
+
+	if refresh != nil {
+		if err := ar.TokenRepo.CreateRefreshToken(r.Context(), *refresh); err != nil {
+			applog.Error("Failed to store refresh token:", err)
+			writeOAuthError(w, 500, "server_error", "")
+			return false
+		}
+	}
+
+	api.WriteJSON(w, 200, tokens)
+	return true
+}
+
+// refreshOAuthTokens rotates a client's refresh token the way /auth/refresh
+// rotates a session's, including revoking the family when one is replayed.
+func (ar *AuthRouter) refreshOAuthTokens(w http.ResponseWriter, r *http.Request, client *model.OAuthClient) {
+	claims, err := jwt.ValidateToken(r.PostForm.Get("refresh_token"), ar.TokenRepo)
+	if err != nil || claims.Type != model.OAuthRefreshJwt || claims.ClientID != client.ID {
+		applog.Warn("Invalid oauth refresh token", "clientID:", client.ID)
+		writeOAuthError(w, 400, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	scope := claims.Scope
+	if requested := r.PostForm.Get("scope"); requested != "" {
+		var scopes []string
+		for _, s := range strings.Fields(requested) {
+			if !hasScope(claims.Scope, s) {
+				writeOAuthError(w, 400, "invalid_scope", "scope "+s+" was not granted")
+				return
+			}
+			if !hasScope(strings.Join(scopes, " "), s) {
+				scopes = append(scopes, s)
+			}
+		}
+		scope = strings.Join(scopes, " ")
+	}
+
+	user, err := ar.UserRepo.GetUserByID(r.Context(), claims.UserID)
+	if err != nil || claims.SessionID != user.JwtSessionID || !user.CanSignIn(time.Now().UTC().Unix()) {
+		applog.Warn("OAuth refresh failed: user missing, signed out or unavailable", "userID:", claims.UserID)
+		writeOAuthError(w, 400, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	revoked, err := ar.SessionRepo.IsSessionRevoked(r.Context(), claims.FamilyID)
+	if err != nil {
+		applog.Error("Failed to check session:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	stored, err := ar.TokenRepo.GetRefreshToken(r.Context(), claims.TokenID)
+	if err != nil {
+		applog.Error("Failed to get refresh token:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	if revoked || stored == nil || stored.FamilyID != claims.FamilyID || stored.Revoked {
+		applog.Warn("OAuth refresh failed: session or refresh token revoked", "userID:", user.ID, "sessionID:", claims.FamilyID)
+		writeOAuthError(w, 400, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	tokens, refresh, err := issueOAuthTokens(user, client, scope, claims.FamilyID, claims.TokenID, "")
+	if err != nil {
+		applog.Error("Failed to issue oauth tokens:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	rotated, err := ar.TokenRepo.RotateRefreshToken(r.Context(), claims.TokenID)
+	if err != nil {
+		applog.Error("Failed to rotate refresh token:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	if !rotated {
+		ar.handleRefreshReuse(r.Context(), user, stored, utils.GetClientIP(r))
+		writeOAuthError(w, 400, "invalid_grant", "invalid refresh token")
+		return
+	}
+
+	// set, since only clients allowed the refresh grant get this far
+	if err := ar.TokenRepo.CreateRefreshToken(r.Context(), *refresh); err != nil {
+		applog.Error("Failed to store refresh token:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	expiresAt := time.Now().UTC().Unix() + config.App.OAuthRefreshTokenExpiry
+	if err := ar.SessionRepo.TouchSession(r.Context(), claims.FamilyID, utils.GetClientIP(r), expiresAt); err != nil {
+		applog.Error("Failed to update session activity:", err)
+	}
+
+	applog.Info("OAuth tokens refreshed", "userID:", user.ID, "clientID:", client.ID)
+	api.WriteJSON(w, 200, tokens)
+}
+
+// issueClientToken issues an access token to a confidential client for itself.
+// It belongs to no user and cannot be refreshed.
+func (ar *AuthRouter) issueClientToken(w http.ResponseWriter, r *http.Request, client *model.OAuthClient) {
+	if client.IsPublic() {
+		writeOAuthError(w, 400, "unauthorized_client", "public clients cannot use the client_credentials grant")
+		return
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(r.PostForm.Get("scope")) {
+		if s == model.ScopeOpenID || !client.AllowsScope(s) {
+			writeOAuthError(w, 400, "invalid_scope", "scope "+s+" is not allowed for this grant")
+			return
+		}
+		if !hasScope(strings.Join(scopes, " "), s) {
+			scopes = append(scopes, s)
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	now := time.Now().UTC().Unix()
+	token, err := jwt.CreateJwt(jwt.Claims{
+		TokenID:    uuid.New().String(),
+		IssuedAt:   now,
+		Expiration: now + config.App.OAuthAccessTokenExpiry,
+		Type:       model.OAuthClientJwt,
+		ClientID:   client.ID,
+		Scope:      scope,
+	}).GenerateToken()
+	if err != nil {
+		applog.Error("Failed to issue client token:", err)
+		writeOAuthError(w, 500, "server_error", "")
+		return
+	}
+
+	applog.Info("Client token issued", "clientID:", client.ID)
+	api.WriteJSON(w, 200, OAuthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   config.App.OAuthAccessTokenExpiry,
+		Scope:       scope,
+	})
+}
+
+// idTokenClaims are the claims of an OpenID Connect ID token.
+type idTokenClaims struct {
+	Issuer     string `json:"iss"`
+	Audience   string `json:"aud"`
+	Expiration int64  `json:"exp"`
+	IssuedAt   int64  `json:"iat"`
+	Nonce      string `json:"nonce,omitempty"`
+	UserInfoResponse
+}
+
+// issueOAuthTokens mints the tokens for a user's authorization of a client in
+// the session's refresh token family: an access token, a refresh token when
+// the client may refresh, and an ID token when openid was granted. It writes
+// nothing, so a signing failure leaves no session or refresh token behind; the
+// caller stores the returned refresh token, if any.
+func issueOAuthTokens(user *model.User, client *model.OAuthClient, scope string, familyID int64, parentID, nonce string) (OAuthTokenResponse, *model.RefreshToken, error) {
+	token := jwt.CreateJwtFromUser(user)
+	token.Payload.FamilyID = familyID
+	token.Payload.ClientID = client.ID
+	token.Payload.Scope = scope
+	if !hasScope(scope, model.ScopeEmail) {
+		token.Payload.Email = ""
+	}
+	now := token.Payload.IssuedAt
+
+	access := token
+	access.Payload.Type = model.OAuthAccessJwt
+	access.Payload.Expiration = now + config.App.OAuthAccessTokenExpiry
+	accessToken, err := access.GenerateToken()
+	if err != nil {
+		return OAuthTokenResponse{}, nil, err
+	}
+
+	resp := OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   config.App.OAuthAccessTokenExpiry,
+		Scope:       scope,
+	}
+
+	var stored *model.RefreshToken
+	if client.AllowsGrant(model.GrantRefreshToken) {
+		refresh := token
+		refresh.Payload.Type = model.OAuthRefreshJwt
+		refresh.Payload.Expiration = now + config.App.OAuthRefreshTokenExpiry
+		if resp.RefreshToken, err = refresh.GenerateToken(); err != nil {
+			return resp, nil, err
+		}
+
+		stored = &model.RefreshToken{
+			TokenID:   token.Payload.TokenID,
+			FamilyID:  familyID,
+			ParentID:  parentID,
+			UserID:    user.ID,
+			IssuedAt:  now,
+			ExpiresAt: refresh.Payload.Expiration,
+		}
+	}
+
+	if hasScope(scope, model.ScopeOpenID) {
+		if resp.IDToken, err = jwt.SignPublic(idTokenClaims{
+			Issuer:           config.App.OAuthIssuer,
+			Audience:         client.ID,
+			Expiration:       now + config.App.OAuthAccessTokenExpiry,
+			IssuedAt:         now,
+			Nonce:            nonce,
+			UserInfoResponse: userInfo(user, scope),
+		}); err != nil {
+			return resp, nil, err
+		}
+	}
+
+	return resp, stored, nil
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	api.WriteJSON(w, status, OAuthErrorResponse{Error: code, Description: description})
+}
+
+func writeInvalidClient(w http.ResponseWriter, description string) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+config.App.OAuthIssuer+`", charset="UTF-8"`)
+	writeOAuthError(w, 401, "invalid_client", description)
+}
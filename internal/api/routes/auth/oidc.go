@@ -0,0 +1,413 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/oidc"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// @Summary List sign-in providers
+// @Description List the external OpenID Connect providers users can sign in with, configured through OIDC_PROVIDERS.
+// @Tags Social Login
+// @Produce json
+// @Success 200 {array} OidcProviderResponse "Configured providers"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Router /auth/oidc/providers [get]
+func (ar *AuthRouter) HandleListOidcProviders(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListOidcProviders called")
+	providers := []OidcProviderResponse{}
+	for _, p := range oidc.List() {
+		providers = append(providers, OidcProviderResponse{Name: p.Name, DisplayName: p.DisplayName})
+	}
+
+	api.WriteJSON(w, 200, providers)
+}
+
+// @Summary Begin provider sign-in
+// @Description Start signing in with an external OpenID Connect provider. Returns the provider URL to send the browser to and sets a short-lived oidc cookie tying the login to this browser. The provider redirects back to its configured redirect_uri with code and state query parameters, which the page there posts to the finish endpoint within OIDC_STATE_TIMEOUT seconds.
+// @Tags Social Login
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} OidcBeginResponse "Provider authorization URL"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Failure 502 {object} api.ErrorResponse "Provider unavailable"
+// @Router /auth/oidc/{provider}/begin [post]
+func (ar *AuthRouter) HandleOidcBegin(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleOidcBegin called", "remoteAddr:", utils.GetClientIP(r))
+	provider := oidcProvider(w, r)
+	if provider == nil {
+		return
+	}
+
+	ar.beginOidc(w, r, provider, 0)
+}
+
+// @Summary Finish provider sign-in
+// @Description Complete signing in with an external provider by posting the code and state it redirected back with. Requires the oidc cookie set by the begin step. The provider's ID token is verified against its published keys. A user who signed in with this provider before gets their account back. Otherwise, when the provider confirms the email address, the account with that email is linked, or a new account is created with a confirmed email and no password. Accounts whose own email is still unconfirmed are never linked, so nobody can claim an address by registering it first. Session and refresh tokens are issued as with /auth/login, including the 202 second factor step for accounts with an authenticator app.
+// @Tags Social Login
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
+// @Param request body OidcFinishRequest true "Code and state from the provider redirect"
+// @Success 200 {object} LoginResponse "Authentication successful - session and refresh tokens issued"
+// @Success 202 {object} MfaRequiredResponse "Provider sign-in accepted - second factor required, mfa token issued"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format, or the provider shared no verified email"
+// @Failure 401 {object} api.ErrorResponse "Missing or expired login, state mismatch, or code or ID token refused"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 404 {object} api.ErrorResponse "Unknown provider"
+// @Failure 409 {object} api.ErrorResponse "An account with this email exists but its email is not confirmed"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/oidc/{provider}/finish [post]
+func (ar *AuthRouter) HandleOidcFinish(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandleOidcFinish called", "remoteAddr:", ip)
+	provider := oidcProvider(w, r)
+	if provider == nil {
+		return
+	}
+
+	claims := ar.finishOidc(w, r, provider, 0)
+	if claims == nil {
+		return
+	}
+
+	user := ar.oidcUser(w, r, provider, claims)
+	if user == nil {
+		return
+	}
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	if !checkAccountStatus(w, user) {
+		return
+	}
+
+	if user.TotpEnabled {
+		ar.beginMfaLogin(w, r, user)
+		return
+	}
+
+	if !ar.issueLogin(w, r, user) {
+		return
+	}
+
+	applog.Info("User login successful with oidc", "userID:", user.ID, "provider:", provider.Name)
+}
+
+// oidcProvider returns the provider named in the URL, writing the response
+// itself when there is none.
+func oidcProvider(w http.ResponseWriter, r *http.Request) *oidc.Provider {
+	provider, ok := oidc.Get(chi.URLParam(r, "provider"))
+	if !ok {
+		api.WriteMessage(w, 404, "error", "unknown provider")
+		return nil
+	}
+	return provider
+}
+
+// beginOidc starts a login, or a link when userID is set, at the provider and
+// writes the authorization URL to send the browser to.
+func (ar *AuthRouter) beginOidc(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID int64) {
+	var values [3]string
+	for i := range values {
+		v, err := oidc.NewVerifier()
+		if err != nil {
+			applog.Error("Failed to generate oidc login values:", err)
+			api.WriteInternalError(w)
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		applog.Error("Failed to reach oidc provider", "provider:", provider.Name, "err:", err)
+		api.WriteMessage(w, 502, "error", "provider unavailable")
+		return
+	}
+
+	token, err := utils.GetRandomToken(32)
+	if err != nil {
+		applog.Error("Failed to generate oidc state token:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	err = ar.IdentityRepo.CreateState(r.Context(), &model.OidcState{
+		ID:           token.Hash,
+		UserID:       userID,
+		Provider:     provider.Name,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Unix() + config.App.OidcStateTimeout,
+	})
+	if err != nil {
+		applog.Error("Failed to store oidc state:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	utils.SetOidcCookie(w, token.Raw)
+	api.WriteJSON(w, 200, OidcBeginResponse{AuthorizationURL: authURL})
+}
+
+// finishOidc checks the authorization response against the pending login or
+// link started by userID in this browser, then redeems the code for verified
+// claims. It writes the response itself when that fails.
+func (ar *AuthRouter) finishOidc(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID int64) *oidc.Claims {
+	req, err := api.DecodeJSON[OidcFinishRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode oidc finish request:", err)
+		return nil
+	}
+
+	pending := ar.consumeOidcState(w, r)
+	if pending == nil {
+		return nil
+	}
+
+	if pending.Provider != provider.Name || pending.UserID != userID || subtle.ConstantTimeCompare([]byte(req.State), []byte(pending.State)) != 1 {
+		applog.Warn("Oidc state mismatch", "provider:", provider.Name, "userID:", userID)
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	claims, err := provider.Exchange(r.Context(), req.Code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		applog.Warn("Oidc code exchange failed", "provider:", provider.Name, "err:", err)
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	return claims
+}
+
+func (ar *AuthRouter) consumeOidcState(w http.ResponseWriter, r *http.Request) *model.OidcState {
+	cookie, err := r.Cookie("oidc")
+	if err != nil {
+		applog.Warn("No oidc cookie found")
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	utils.ClearOidcCookie(w)
+
+	hash, err := utils.HashToken(cookie.Value)
+	if err != nil {
+		applog.Warn("Malformed oidc cookie")
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	pending, err := ar.IdentityRepo.ConsumeState(r.Context(), hash)
+	if err != nil {
+		applog.Warn("Oidc login not found or expired", "err:", err)
+		api.WriteInvalidCredentials(w)
+		return nil
+	}
+
+	return pending
+}
+
+// oidcUser resolves the account for a verified provider login: the one already
+// linked to the provider account, else the one with the verified email, else a
+// new one. It writes the response itself when there is none.
+func (ar *AuthRouter) oidcUser(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, claims *oidc.Claims) *model.User {
+	identity, err := ar.IdentityRepo.GetIdentity(r.Context(), provider.Name, claims.Subject)
+	if err == nil {
+		user, err := ar.UserRepo.GetUserByID(r.Context(), identity.UserID)
+		if err != nil {
+			applog.Error("Failed to get user for identity:", err)
+			api.WriteInternalError(w)
+			return nil
+		}
+		return user
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		applog.Error("Failed to get identity:", err)
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if !claims.EmailVerified || !utils.IsValidEmail(email) {
+		applog.Warn("Oidc login without a verified email", "provider:", provider.Name)
+		api.WriteMessage(w, 400, "error", "provider did not share a verified email")
+		return nil
+	}
+
+	created := false
+	user, err := ar.UserRepo.GetUserByEmail(r.Context(), email)
+	switch {
+	case err == nil:
+		// the account's owner never proved the address, so it may have been
+		// registered by someone waiting for its real owner to sign in
+		if !user.EmailConfirmed {
+			applog.Warn("Oidc login for account with unconfirmed email", "userID:", user.ID, "provider:", provider.Name)
+			api.WriteMessage(w, 409, "error", "an account with this email exists but its email is not confirmed")
+			return nil
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user = ar.createOidcUser(w, r, claims, email)
+		if user == nil {
+			return nil
+		}
+		created = true
+	default:
+		applog.Error("Failed to get user by email:", err)
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	if err := ar.linkIdentity(r, user.ID, provider, claims.Subject, email); err != nil {
+		applog.Error("Failed to link identity:", err)
+		if created {
+			ar.UserRepo.DeleteUser(r.Context(), user.ID)
+		}
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	if !created {
+		sendSecurityAlert(user.Email, "Sign-in method added",
+			"Your account can now be signed into with "+provider.DisplayName+", which confirmed it controls your email address.",
+			utils.GetClientIP(r))
+	}
+
+	return user
+}
+
+// linkIdentity records the provider account as a way to sign in to the user's
+// account.
+func (ar *AuthRouter) linkIdentity(r *http.Request, userID int64, provider *oidc.Provider, subject, email string) error {
+	now := time.Now().UTC().Unix()
+	err := ar.IdentityRepo.CreateIdentity(r.Context(), &model.Identity{
+		ID:       utils.GenerateSnowflakeID(),
+		UserID:   userID,
+		Provider: provider.Name,
+		Subject:  subject,
+		Email:    email,
+		LinkedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    userID,
+		Type:      model.IdentityLinkedEvent,
+		IPAddress: utils.GetClientIP(r),
+		Details:   provider.Name,
+		CreatedAt: now,
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	applog.Info("Identity linked", "userID:", userID, "provider:", provider.Name)
+	return nil
+}
+
+// createOidcUser creates an account for a provider login with no matching
+// account. The email counts as confirmed since the provider verified it, and
+// there is no password until the user sets one.
+func (ar *AuthRouter) createOidcUser(w http.ResponseWriter, r *http.Request, claims *oidc.Claims, email string) *model.User {
+	id := utils.GenerateSnowflakeID()
+	username, err := ar.oidcUsername(r.Context(), claims, email, id)
+	if err != nil {
+		applog.Error("Failed to pick username:", err)
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	user := &model.User{ID: id, Username: username, Email: email, CreatedAt: time.Now().UTC().Unix(), Role: model.RoleUser, EmailConfirmed: true}
+	if err := ar.UserRepo.CreateUser(r.Context(), user); err != nil {
+		applog.Error("Failed to create user:", err)
+		api.WriteInternalError(w)
+		return nil
+	}
+
+	applog.Info("User registered with oidc", "userID:", user.ID, "email:", user.Email)
+	return user
+}
+
+// oidcUsername derives an available username from the provider's preferred
+// username, the email or the display name, adding a number when it is taken.
+func (ar *AuthRouter) oidcUsername(ctx context.Context, claims *oidc.Claims, email string, userID int64) (string, error) {
+	local, _, _ := strings.Cut(email, "@")
+
+	base := ""
+	for _, candidate := range []string{claims.PreferredUsername, local, claims.Name} {
+		if name := usernameFrom(candidate); utils.IsValidUsername(name) {
+			base = name
+			break
+		}
+	}
+
+	var candidates []string
+	if base != "" {
+		candidates = append(candidates, base)
+		for range 5 {
+			candidates = append(candidates, base+"-"+strconv.Itoa(1000+rand.IntN(9000)))
+		}
+	}
+	candidates = append(candidates, "user-"+strconv.FormatInt(userID, 10))
+
+	for _, name := range candidates {
+		duplicate, err := ar.UserRepo.DuplicateName(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if !duplicate {
+			return name, nil
+		}
+	}
+	return "", errors.New("no available username")
+}
+
+// usernameFrom keeps the characters of s allowed in usernames, turning spaces
+// and dots into underscores, and leaves room for a numeric suffix.
+func usernameFrom(s string) string {
+	var b strings.Builder
+	for _, r := range utils.CleanUsername(s) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), unicode.IsMark(r), r == '_', r == '-':
+			b.WriteRune(r)
+		case r == ' ', r == '.':
+			b.WriteRune('_')
+		}
+	}
+
+	name := b.String()
+	for utf8.RuneCountInString(name) > 25 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/oidc"
+	"github.com/akramboussanni/gocode/internal/oidc/oidctest"
+)
+
+func newOidcIssuer(t *testing.T) *oidctest.Issuer {
+	issuer := oidctest.NewIssuer(t)
+	issuer.Register("test")
+	t.Cleanup(func() { oidc.Init("") })
+	return issuer
+}
+
+// oidcLogin signs c in through the provider with the issuer sending claims.
+func oidcLogin(t *testing.T, c *apitest.Client, issuer *oidctest.Issuer, claims map[string]any) int {
+	t.Helper()
+
+	var begin OidcBeginResponse
+	if status := c.Do("POST", "/auth/oidc/test/begin", nil, &begin); status != 200 {
+		t.Fatalf("oidc begin: status %d", status)
+	}
+
+	code, state := issuer.Authorize(begin.AuthorizationURL, claims)
+	return c.Do("POST", "/auth/oidc/test/finish", OidcFinishRequest{Code: code, State: state}, nil)
+}
+
+func identityOwner(t *testing.T, s *apitest.Server, subject string) int64 {
+	t.Helper()
+	var userID int64
+	s.DB.Get(&userID, "SELECT user_id FROM identities WHERE provider = 'test' AND subject = $1", subject)
+	return userID
+}
+
+func TestOidcLoginCreatesAccount(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	claims := issuer.Claims("carol@example.com")
+	claims["preferred_username"] = "carol"
+
+	c := srv.NewClient(t)
+	if status := oidcLogin(t, c, issuer, claims); status != 200 {
+		t.Fatalf("oidc login: status %d", status)
+	}
+
+	var profile map[string]any
+	if status := c.Do("GET", "/auth/me", nil, &profile); status != 200 || profile["username"] != "carol" || profile["email"] != "carol@example.com" {
+		t.Fatalf("profile after oidc login: status %d, %v", status, profile)
+	}
+
+	userID := srv.UserID(t, "carol@example.com")
+	subject := claims["sub"].(string)
+	if owner := identityOwner(t, srv, subject); owner != userID {
+		t.Fatalf("identity linked to %d, want %d", owner, userID)
+	}
+
+	// the same provider account signs back into the same user, even with
+	// another email
+	claims = issuer.Claims("carol@elsewhere.example.com")
+	claims["sub"] = subject
+	if status := oidcLogin(t, srv.NewClient(t), issuer, claims); status != 200 {
+		t.Fatalf("second oidc login: status %d", status)
+	}
+
+	var users int
+	srv.DB.Get(&users, "SELECT COUNT(*) FROM users")
+	if users != 1 {
+		t.Fatalf("second login created an account: %d users", users)
+	}
+}
+
+func TestOidcLoginRejectsBadToken(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	claims := issuer.Claims("carol@example.com")
+	claims["nonce"] = "another-login"
+	if status := oidcLogin(t, srv.NewClient(t), issuer, claims); status != 401 {
+		t.Fatalf("nonce mismatch: want 401, got %d", status)
+	}
+
+	claims = issuer.Claims("carol@example.com")
+	claims["aud"] = "another-client"
+	if status := oidcLogin(t, srv.NewClient(t), issuer, claims); status != 401 {
+		t.Fatalf("wrong audience: want 401, got %d", status)
+	}
+
+	var users int
+	srv.DB.Get(&users, "SELECT COUNT(*) FROM users")
+	if users != 0 {
+		t.Fatalf("refused logins created %d users", users)
+	}
+}
+
+func TestOidcLoginStateMismatch(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	c := srv.NewClient(t)
+	var begin OidcBeginResponse
+	if status := c.Do("POST", "/auth/oidc/test/begin", nil, &begin); status != 200 {
+		t.Fatalf("oidc begin: status %d", status)
+	}
+
+	code, _ := issuer.Authorize(begin.AuthorizationURL, issuer.Claims("carol@example.com"))
+	if status := c.Do("POST", "/auth/oidc/test/finish", OidcFinishRequest{Code: code, State: "forged"}, nil); status != 401 {
+		t.Fatalf("state mismatch: want 401, got %d", status)
+	}
+}
+
+func TestOidcLoginUnverifiedEmail(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	claims := issuer.Claims("carol@example.com")
+	claims["email_verified"] = false
+	if status := oidcLogin(t, srv.NewClient(t), issuer, claims); status != 400 {
+		t.Fatalf("unverified email: want 400, got %d", status)
+	}
+
+	claims = issuer.Claims("carol@example.com")
+	claims["email_verified"] = "true"
+	if status := oidcLogin(t, srv.NewClient(t), issuer, claims); status != 200 {
+		t.Fatalf("email_verified sent as a string: want 200, got %d", status)
+	}
+}
+
+func TestOidcLoginLinksConfirmedAccount(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	srv.NewClient(t).Register("alice", "alice@example.com")
+	userID := srv.UserID(t, "alice@example.com")
+
+	claims := issuer.Claims("alice@example.com")
+	c := srv.NewClient(t)
+	if status := oidcLogin(t, c, issuer, claims); status != 200 {
+		t.Fatalf("oidc login: status %d", status)
+	}
+
+	if owner := identityOwner(t, srv, claims["sub"].(string)); owner != userID {
+		t.Fatalf("identity linked to %d, want %d", owner, userID)
+	}
+
+	var profile map[string]any
+	if status := c.Do("GET", "/auth/me", nil, &profile); status != 200 || profile["username"] != "alice" {
+		t.Fatalf("profile after oidc login: status %d, %v", status, profile)
+	}
+}
+
+func TestOidcLoginRefusesUnconfirmedAccount(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	srv.NewClient(t).Register("mallory", "alice@example.com")
+	srv.DB.Exec("UPDATE users SET email_confirmed = false WHERE email = 'alice@example.com'")
+
+	claims := issuer.Claims("alice@example.com")
+	if status := oidcLogin(t, srv.NewClient(t), issuer, claims); status != 409 {
+		t.Fatalf("unconfirmed account: want 409, got %d", status)
+	}
+
+	if owner := identityOwner(t, srv, claims["sub"].(string)); owner != 0 {
+		t.Fatalf("identity linked to unconfirmed account %d", owner)
+	}
+}
+
+func TestPasswordlessAccountMustSetPassword(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	c := srv.NewClient(t)
+	if status := oidcLogin(t, c, issuer, issuer.Claims("carol@example.com")); status != 200 {
+		t.Fatalf("oidc login: status %d", status)
+	}
+
+	actions := []struct {
+		path string
+		body any
+	}{
+		{"/auth/delete-account", DeleteAccountRequest{Password: testPassword}},
+		{"/auth/change-email", EmailChangeRequest{Password: testPassword, NewEmail: "carol2@example.com"}},
+		{"/auth/mfa/totp/enroll", PasswordRequest{Password: testPassword}},
+	}
+	for _, a := range actions {
+		if status := c.Do("POST", a.path, a.body, nil); status != 409 {
+			t.Errorf("%s without a password: want 409, got %d", a.path, status)
+		}
+	}
+
+	if status := c.Do("POST", "/auth/set-password", SetPasswordRequest{NewPassword: testPassword}, nil); status != 200 {
+		t.Fatalf("set password: status %d", status)
+	}
+
+	// setting the password signs every session out
+	c = srv.NewClient(t)
+	if status := c.Do("POST", "/auth/login", LoginRequest{Identifier: "carol@example.com", Password: testPassword}, nil); status != 200 {
+		t.Fatalf("login with the new password: status %d", status)
+	}
+	if status := c.Do("POST", "/auth/mfa/totp/enroll", PasswordRequest{Password: testPassword}, nil); status != 200 {
+		t.Fatalf("totp enroll after setting a password: status %d", status)
+	}
+	if status := c.Do("POST", "/auth/delete-account", DeleteAccountRequest{Password: "WrongPass123"}, nil); status != 401 {
+		t.Fatalf("wrong password: want 401, got %d", status)
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	srv := newTestServer(t)
+	issuer := newOidcIssuer(t)
+
+	c := srv.NewClient(t)
+	if status := oidcLogin(t, c, issuer, issuer.Claims("carol@example.com")); status != 200 {
+		t.Fatalf("oidc login: status %d", status)
+	}
+
+	var identities []model.Identity
+	if status := c.Do("GET", "/auth/identities", nil, &identities); status != 200 || len(identities) != 1 || identities[0].Provider != "test" {
+		t.Fatalf("list identities: status %d, %+v", status, identities)
+	}
+	path := fmt.Sprintf("/auth/identities/%d", identities[0].ID)
+
+	other := srv.NewClient(t)
+	other.Register("bob", "bob@example.com")
+	if status := other.Do("DELETE", path, nil, nil); status != 404 {
+		t.Fatalf("another user's identity: want 404, got %d", status)
+	}
+
+	if status := c.Do("DELETE", path, nil, nil); status != 409 {
+		t.Fatalf("last way to sign in: want 409, got %d", status)
+	}
+
+	if status := c.Do("POST", "/auth/set-password", SetPasswordRequest{NewPassword: testPassword}, nil); status != 200 {
+		t.Fatalf("set password: status %d", status)
+	}
+	c.Login("carol@example.com")
+
+	if status := c.Do("DELETE", path, nil, nil); status != 200 {
+		t.Fatalf("unlink: status %d", status)
+	}
+	if status := c.Do("GET", "/auth/identities", nil, &identities); status != 200 || len(identities) != 0 {
+		t.Fatalf("identities after unlinking: status %d, %+v", status, identities)
+	}
+}
@@ -0,0 +1,482 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// passkeyUser adapts a user and their stored credentials to webauthn.User.
+type passkeyUser struct {
+	user        *model.User
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return []byte(strconv.FormatInt(u.user.ID, 10))
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func newWebAuthn() (*webauthn.WebAuthn, error) {
+	timeout := time.Duration(config.App.WebAuthnTimeout) * time.Second
+	return webauthn.New(&webauthn.Config{
+		RPID:          config.App.WebAuthnRPID,
+		RPDisplayName: config.App.WebAuthnRPName,
+		RPOrigins:     strings.Split(config.App.WebAuthnOrigins, ","),
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: timeout, TimeoutUVD: timeout},
+		},
+	})
+}
+
+// @Summary Begin passkey registration
+// @Description Start registering a new passkey for the current user. Returns the PublicKeyCredentialCreationOptions to pass to navigator.credentials.create() and sets a short-lived passkey cookie tying the ceremony to this browser.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body PasskeyRegisterRequest true "Name for the new passkey"
+// @Success 200 {object} object "WebAuthn credential creation options ({publicKey: {...}})"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or name"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/passkeys/register/begin [post]
+func (ar *AuthRouter) HandlePasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandlePasskeyRegisterBegin called")
+	req, err := api.DecodeJSON[PasskeyRegisterRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode passkey register request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 {
+		applog.Warn("Invalid passkey name", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "invalid name")
+		return
+	}
+
+	pu, err := ar.loadPasskeyUser(r.Context(), user)
+	if err != nil {
+		applog.Error("Failed to load passkeys:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	creation, session, err := ar.WebAuthn.BeginRegistration(pu,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(pu.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		applog.Error("Failed to begin passkey registration:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.storePasskeySession(w, r, user.ID, model.RegistrationCeremony, name, session) {
+		return
+	}
+
+	applog.Info("Passkey registration started", "userID:", user.ID)
+	api.WriteJSON(w, 200, creation)
+}
+
+// @Summary Finish passkey registration
+// @Description Complete passkey registration by posting the PublicKeyCredential returned by navigator.credentials.create(). Requires the passkey cookie set by the begin step.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body object true "PublicKeyCredential attestation response"
+// @Success 200 {object} model.WebAuthnCredential "Registered passkey"
+// @Failure 400 {object} api.ErrorResponse "Invalid attestation response"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized, or missing or expired registration ceremony"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/passkeys/register/finish [post]
+func (ar *AuthRouter) HandlePasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandlePasskeyRegisterFinish called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	pending, session := ar.consumePasskeySession(w, r, model.RegistrationCeremony)
+	if pending == nil {
+		return
+	}
+
+	if pending.UserID != user.ID {
+		applog.Warn("Passkey registration ceremony belongs to another user", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	pu, err := ar.loadPasskeyUser(r.Context(), user)
+	if err != nil {
+		applog.Error("Failed to load passkeys:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	credential, err := ar.WebAuthn.FinishRegistration(pu, *session, r)
+	if err != nil {
+		applog.Warn("Invalid passkey attestation", "userID:", user.ID, "err:", err)
+		api.WriteMessage(w, 400, "error", "invalid passkey response")
+		return
+	}
+
+	raw, err := json.Marshal(credential)
+	if err != nil {
+		applog.Error("Failed to encode passkey:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	stored := &model.WebAuthnCredential{
+		ID:           utils.GenerateSnowflakeID(),
+		UserID:       user.ID,
+		Name:         pending.Name,
+		CredentialID: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(raw),
+		SignCount:    int64(credential.Authenticator.SignCount),
+		CreatedAt:    time.Now().UTC().Unix(),
+	}
+
+	if err := ar.PasskeyRepo.CreateCredential(r.Context(), stored); err != nil {
+		applog.Error("Failed to store passkey:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	sendSecurityAlert(user.Email, "Passkey added",
+		"A new passkey named \""+stored.Name+"\" was added to your account and can now be used to sign in.",
+		utils.GetClientIP(r))
+
+	utils.StripUnsafeFields(stored)
+	applog.Info("Passkey registered", "userID:", user.ID, "passkeyID:", stored.ID)
+	api.WriteJSON(w, 200, stored)
+}
+
+// @Summary Begin passkey login
+// @Description Start a passwordless login. Returns the PublicKeyCredentialRequestOptions to pass to navigator.credentials.get() and sets a short-lived passkey cookie tying the ceremony to this browser. The user is identified by the passkey they pick.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Success 200 {object} object "WebAuthn credential request options ({publicKey: {...}})"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/passkeys/login/begin [post]
+func (ar *AuthRouter) HandlePasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandlePasskeyLoginBegin called", "remoteAddr:", utils.GetClientIP(r))
+
+	assertion, session, err := ar.WebAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		applog.Error("Failed to begin passkey login:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.storePasskeySession(w, r, 0, model.LoginCeremony, "", session) {
+		return
+	}
+
+	api.WriteJSON(w, 200, assertion)
+}
+
+// @Summary Finish passkey login
+// @Description Complete a passwordless login by posting the PublicKeyCredential returned by navigator.credentials.get(). On success the same session and refresh cookies as /auth/login are set. Assertions whose signature counter went backwards are rejected as a possible cloned authenticator.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
+// @Param request body object true "PublicKeyCredential assertion response"
+// @Success 200 {object} LoginResponse "Authentication successful - session and refresh tokens issued"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format"
+// @Failure 401 {object} api.ErrorResponse "Invalid assertion, unknown passkey, unconfirmed email or expired ceremony"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/passkeys/login/finish [post]
+func (ar *AuthRouter) HandlePasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	ip := utils.GetClientIP(r)
+	applog.Info("HandlePasskeyLoginFinish called", "remoteAddr:", ip)
+
+	pending, session := ar.consumePasskeySession(w, r, model.LoginCeremony)
+	if pending == nil {
+		return
+	}
+
+	var stored *model.WebAuthnCredential
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var err error
+		stored, err = ar.PasskeyRepo.GetCredentialByCredentialID(r.Context(), base64.RawURLEncoding.EncodeToString(rawID))
+		if err != nil {
+			return nil, err
+		}
+
+		if string(userHandle) != strconv.FormatInt(stored.UserID, 10) {
+			return nil, errors.New("user handle does not match credential owner")
+		}
+
+		user, err := ar.UserRepo.GetUserByID(r.Context(), stored.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		return ar.loadPasskeyUser(r.Context(), user)
+	}
+
+	found, credential, err := ar.WebAuthn.FinishPasskeyLogin(handler, *session, r)
+	if err != nil {
+		applog.Warn("Passkey login failed", "err:", err)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	user := found.(*passkeyUser).user
+
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
+		return
+	}
+
+	if credential.Authenticator.CloneWarning {
+		applog.Warn("Passkey sign count regression, possible cloned authenticator", "userID:", user.ID, "passkeyID:", stored.ID, "ip:", ip)
+		sendSecurityAlert(user.Email, "Passkey sign-in blocked",
+			"A sign-in with your passkey \""+stored.Name+"\" was blocked because its signature counter went backwards, which can mean the passkey was copied. If this keeps happening, remove the passkey and register it again.",
+			ip)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	if !user.EmailConfirmed {
+		applog.Warn("Passkey login attempt with unconfirmed email", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
+	}
+
+	raw, err := json.Marshal(credential)
+	if err != nil {
+		applog.Error("Failed to encode passkey:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if err := ar.PasskeyRepo.UpdateCredentialUsage(r.Context(), stored.ID, string(raw), int64(credential.Authenticator.SignCount)); err != nil {
+		applog.Error("Failed to update passkey usage:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !ar.issueLogin(w, r, user) {
+		return
+	}
+
+	applog.Info("User login successful with passkey", "userID:", user.ID, "passkeyID:", stored.ID)
+}
+
+// @Summary List passkeys
+// @Description List the passkeys registered to the current user.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {array} model.WebAuthnCredential "Registered passkeys"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/passkeys [get]
+func (ar *AuthRouter) HandleListPasskeys(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListPasskeys called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	credentials, err := ar.PasskeyRepo.GetCredentialsByUserSafe(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list passkeys:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	api.WriteJSON(w, 200, credentials)
+}
+
+// @Summary Remove a passkey
+// @Description Remove one of the current user's passkeys. It can no longer be used to sign in.
+// @Tags Passkeys
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "Passkey ID"
+// @Success 200 {object} api.SuccessResponse "Passkey removed"
+// @Failure 400 {object} api.ErrorResponse "Invalid passkey ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Passkey not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/passkeys/{id} [delete]
+func (ar *AuthRouter) HandleDeletePasskey(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleDeletePasskey called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "invalid passkey id")
+		return
+	}
+
+	deleted, err := ar.PasskeyRepo.DeleteCredential(r.Context(), user.ID, id)
+	if err != nil {
+		applog.Error("Failed to delete passkey:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !deleted {
+		api.WriteMessage(w, 404, "error", "passkey not found")
+		return
+	}
+
+	sendSecurityAlert(user.Email, "Passkey removed", "A passkey was removed from your account.", utils.GetClientIP(r))
+
+	applog.Info("Passkey removed", "userID:", user.ID, "passkeyID:", id)
+	api.WriteMessage(w, 200, "message", "passkey removed")
+}
+
+func (ar *AuthRouter) loadPasskeyUser(ctx context.Context, user *model.User) (*passkeyUser, error) {
+	stored, err := ar.PasskeyRepo.GetCredentialsByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, s := range stored {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(s.Credential), &credential); err != nil {
+			return nil, err
+		}
+		credential.Authenticator.SignCount = uint32(s.SignCount)
+		credentials = append(credentials, credential)
+	}
+
+	return &passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// storePasskeySession persists the ceremony state server side and hands the
+// browser an opaque cookie pointing at it.
+func (ar *AuthRouter) storePasskeySession(w http.ResponseWriter, r *http.Request, userID int64, ceremony model.WebAuthnCeremony, name string, session *webauthn.SessionData) bool {
+	data, err := json.Marshal(session)
+	if err != nil {
+		applog.Error("Failed to encode passkey session:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	token, err := utils.GetRandomToken(32)
+	if err != nil {
+		applog.Error("Failed to generate passkey session token:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	err = ar.PasskeyRepo.CreateSession(r.Context(), &model.WebAuthnSession{
+		ID:          token.Hash,
+		UserID:      userID,
+		Ceremony:    ceremony,
+		Name:        name,
+		SessionData: string(data),
+		ExpiresAt:   time.Now().UTC().Unix() + config.App.WebAuthnTimeout,
+	})
+	if err != nil {
+		applog.Error("Failed to store passkey session:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	utils.SetPasskeyCookie(w, token.Raw)
+	return true
+}
+
+func (ar *AuthRouter) consumePasskeySession(w http.ResponseWriter, r *http.Request, ceremony model.WebAuthnCeremony) (*model.WebAuthnSession, *webauthn.SessionData) {
+	cookie, err := r.Cookie("passkey")
+	if err != nil {
+		applog.Warn("No passkey cookie found")
+		api.WriteInvalidCredentials(w)
+		return nil, nil
+	}
+
+	utils.ClearPasskeyCookie(w)
+
+	hash, err := utils.HashToken(cookie.Value)
+	if err != nil {
+		applog.Warn("Malformed passkey cookie")
+		api.WriteInvalidCredentials(w)
+		return nil, nil
+	}
+
+	pending, err := ar.PasskeyRepo.ConsumeSession(r.Context(), hash, ceremony)
+	if err != nil {
+		applog.Warn("Passkey ceremony not found or expired", "err:", err)
+		api.WriteInvalidCredentials(w)
+		return nil, nil
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(pending.SessionData), &session); err != nil {
+		applog.Error("Failed to decode passkey session:", err)
+		api.WriteInternalError(w)
+		return nil, nil
+	}
+
+	return pending, &session
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+)
+
+// softAuthenticator is a passkey held in memory, enough to answer the
+// ceremonies of the WebAuthn config the tests run with.
+type softAuthenticator struct {
+	t      *testing.T
+	key    *ecdsa.PrivateKey
+	id     []byte
+	origin string
+	rpID   string
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{t: t, key: key, id: id, origin: "http://localhost:9520", rpID: "localhost"}
+}
+
+type ceremonyOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+	} `json:"publicKey"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// cborBytes encodes b as a CBOR byte string.
+func cborBytes(b []byte) []byte {
+	switch n := len(b); {
+	case n < 24:
+		return append([]byte{0x40 | byte(n)}, b...)
+	case n < 256:
+		return append([]byte{0x58, byte(n)}, b...)
+	default:
+		return append([]byte{0x59, byte(n >> 8), byte(n)}, b...)
+	}
+}
+
+// cborText encodes s as a short CBOR text string.
+func cborText(s string) []byte {
+	return append([]byte{0x60 | byte(len(s))}, s...)
+}
+
+func (a *softAuthenticator) authData(flags byte, signCount uint32, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) clientData(typ, challenge string) []byte {
+	raw, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": a.origin})
+	return raw
+}
+
+// create answers navigator.credentials.create() with a "none" attestation.
+func (a *softAuthenticator) create(options ceremonyOptions) map[string]any {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	// COSE EC2 key: kty 2, alg ES256, crv P-256, x, y
+	coseKey := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21}
+	coseKey = append(coseKey, cborBytes(x)...)
+	coseKey = append(coseKey, 0x22)
+	coseKey = append(coseKey, cborBytes(y)...)
+
+	attested := make([]byte, 16) // zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, coseKey...)
+
+	// user present, user verified, attested credential data included
+	authData := a.authData(0x01|0x04|0x40, 0, attested)
+
+	attestation := []byte{0xa3}
+	attestation = append(attestation, cborText("fmt")...)
+	attestation = append(attestation, cborText("none")...)
+	attestation = append(attestation, cborText("attStmt")...)
+	attestation = append(attestation, 0xa0)
+	attestation = append(attestation, cborText("authData")...)
+	attestation = append(attestation, cborBytes(authData)...)
+
+	return map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(a.clientData("webauthn.create", options.PublicKey.Challenge)),
+			"attestationObject": b64.EncodeToString(attestation),
+		},
+	}
+}
+
+// get answers navigator.credentials.get() for userID, reporting signCount.
+func (a *softAuthenticator) get(options ceremonyOptions, userID int64, signCount uint32) map[string]any {
+	authData := a.authData(0x01|0x04, signCount, nil)
+	clientData := a.clientData("webauthn.get", options.PublicKey.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(clientData),
+			"authenticatorData": b64.EncodeToString(authData),
+			"signature":         b64.EncodeToString(sig),
+			"userHandle":        b64.EncodeToString([]byte(strconv.FormatInt(userID, 10))),
+		},
+	}
+}
+
+func registerPasskey(t *testing.T, c *apitest.Client, a *softAuthenticator) {
+	t.Helper()
+
+	var options ceremonyOptions
+	if status := c.Do("POST", "/auth/passkeys/register/begin", PasskeyRegisterRequest{Name: "laptop"}, &options); status != 200 {
+		t.Fatalf("register begin: status %d", status)
+	}
+
+	if status := c.Do("POST", "/auth/passkeys/register/finish", a.create(options), nil); status != 200 {
+		t.Fatalf("register finish: status %d", status)
+	}
+}
+
+func passkeyLogin(t *testing.T, c *apitest.Client, a *softAuthenticator, userID int64, signCount uint32) int {
+	t.Helper()
+
+	var options ceremonyOptions
+	if status := c.Do("POST", "/auth/passkeys/login/begin", nil, &options); status != 200 {
+		t.Fatalf("login begin: status %d", status)
+	}
+
+	return c.Do("POST", "/auth/passkeys/login/finish", a.get(options, userID, signCount), nil)
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	srv := newTestServer(t)
+	owner := srv.NewClient(t)
+	owner.Register("alice", "alice@example.com")
+	userID := srv.UserID(t, "alice@example.com")
+
+	a := newSoftAuthenticator(t)
+	registerPasskey(t, owner, a)
+
+	var listed []map[string]any
+	if status := owner.Do("GET", "/auth/passkeys", nil, &listed); status != 200 || len(listed) != 1 || listed[0]["name"] != "laptop" {
+		t.Fatalf("list passkeys: status %d, %v", status, listed)
+	}
+
+	browser := srv.NewClient(t)
+	if status := passkeyLogin(t, browser, a, userID, 1); status != 200 {
+		t.Fatalf("passkey login: status %d", status)
+	}
+
+	if status := browser.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("session after passkey login: status %d", status)
+	}
+
+	var signCount int64
+	srv.DB.Get(&signCount, "SELECT sign_count FROM webauthn_credentials WHERE user_id = $1", userID)
+	if signCount != 1 {
+		t.Fatalf("sign count not recorded: got %d", signCount)
+	}
+}
+
+func TestPasskeyLoginWrongUserHandle(t *testing.T) {
+	srv := newTestServer(t)
+	owner := srv.NewClient(t)
+	owner.Register("alice", "alice@example.com")
+	other := srv.NewClient(t)
+	other.Register("bob", "bob@example.com")
+
+	a := newSoftAuthenticator(t)
+	registerPasskey(t, owner, a)
+
+	if status := passkeyLogin(t, srv.NewClient(t), a, srv.UserID(t, "bob@example.com"), 1); status != 401 {
+		t.Fatalf("login claiming another user: want 401, got %d", status)
+	}
+}
+
+func TestPasskeyCloneWarning(t *testing.T) {
+	srv := newTestServer(t)
+	owner := srv.NewClient(t)
+	owner.Register("alice", "alice@example.com")
+	userID := srv.UserID(t, "alice@example.com")
+
+	a := newSoftAuthenticator(t)
+	registerPasskey(t, owner, a)
+
+	if status := passkeyLogin(t, srv.NewClient(t), a, userID, 5); status != 200 {
+		t.Fatalf("first login: status %d", status)
+	}
+
+	clone := srv.NewClient(t)
+	if status := passkeyLogin(t, clone, a, userID, 3); status != 401 {
+		t.Fatalf("login with a lower counter: want 401, got %d", status)
+	}
+
+	if status := clone.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("blocked login left a session: status %d", status)
+	}
+
+	var signCount int64
+	srv.DB.Get(&signCount, "SELECT sign_count FROM webauthn_credentials WHERE user_id = $1", userID)
+	if signCount != 5 {
+		t.Fatalf("blocked login changed the counter: got %d", signCount)
+	}
+}
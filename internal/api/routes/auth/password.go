@@ -38,7 +38,7 @@ func (ar *AuthRouter) changeUserPassword(ctx context.Context, w http.ResponseWri
 		api.WriteInternalError(w)
 		return false
 	}
-	if err := ar.UserRepo.ChangeJwtSessionID(ctx, user.ID, utils.GenerateSnowflakeID()); err != nil {
+	if err := ar.revokeAllSessions(ctx, user.ID); err != nil {
 		applog.Error("Failed to revoke all sessions:", err)
 		api.WriteInternalError(w)
 		return false
@@ -48,7 +48,30 @@ func (ar *AuthRouter) changeUserPassword(ctx context.Context, w http.ResponseWri
 		api.WriteInternalError(w)
 		return false
 	}
-	applog.Info("Password changed successfully", "userID:", user.ID)
+	if user.HasPassword() {
+		applog.Info("Password changed successfully", "userID:", user.ID)
+	} else {
+		applog.Info("Password set", "userID:", user.ID)
+	}
+	return true
+}
+
+// checkPassword guards an action behind re-entering the password. Accounts
+// without one, such as those created through an external provider, get a 409
+// pointing them at /auth/set-password instead of failing every attempt.
+func checkPassword(w http.ResponseWriter, user *model.User, password, action string) bool {
+	if !user.HasPassword() {
+		applog.Warn("Password required for "+action+" but none set", "userID:", user.ID)
+		api.WriteMessage(w, http.StatusConflict, "error", "no password set, use /auth/set-password first")
+		return false
+	}
+
+	if !utils.ComparePassword(user.PasswordHash, password) {
+		applog.Warn("Incorrect password for "+action, "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return false
+	}
+
 	return true
 }
 
@@ -154,9 +177,10 @@ func (ar *AuthRouter) HandleSendForgotPassword(w http.ResponseWriter, r *http.Re
 // @Accept json
 // @Produce json
 // @Security CookieAuth
+// @Security BearerAuth
 // @Param request body PasswordChangeRequest true "Current password and new password"
 // @Success 200 {string} string "Password changed successfully"
-// @Failure 400 {object} api.ErrorResponse "Invalid password format or requirements not met"
+// @Failure 400 {object} api.ErrorResponse "Invalid password format or requirements not met, or the account has no password"
 // @Failure 401 {object} api.ErrorResponse "Unauthorized or incorrect current password"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (5 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
@@ -175,6 +199,12 @@ func (ar *AuthRouter) HandleChangePassword(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !user.HasPassword() {
+		applog.Warn("Password change for account without a password", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "no password set, use /auth/set-password")
+		return
+	}
+
 	if !utils.ComparePassword(user.PasswordHash, req.OldPassword) {
 		applog.Warn("Incorrect current password", "userID:", user.ID)
 		api.WriteInvalidCredentials(w)
@@ -187,3 +217,59 @@ func (ar *AuthRouter) HandleChangePassword(w http.ResponseWriter, r *http.Reques
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// @Summary Set a password (authenticated)
+// @Description Set a password on an account that has none, such as one created by signing in with an external provider, so it can also sign in with a password. Like a password change, every session is signed out. Accounts that already have a password use /auth/change-password.
+// @Tags Password Management
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
+// @Param request body SetPasswordRequest true "New password"
+// @Success 200 {object} api.SuccessResponse "Password set"
+// @Failure 400 {object} api.ErrorResponse "Invalid password format or requirements not met, or the account already has a password"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/set-password [post]
+func (ar *AuthRouter) HandleSetPassword(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleSetPassword called")
+	req, err := api.DecodeJSON[SetPasswordRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode set password request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if user.HasPassword() {
+		applog.Warn("Password already set", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "password already set, use /auth/change-password")
+		return
+	}
+
+	ip := utils.GetClientIP(r)
+	if !ar.changeUserPassword(r.Context(), w, user, req.NewPassword, ip) {
+		return
+	}
+
+	err = ar.SecurityRepo.LogEvent(r.Context(), model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Type:      model.PasswordSetEvent,
+		IPAddress: ip,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	sendSecurityAlert(user.Email, "Password set", "A password was set on your account, which can now be used to sign in.", ip)
+	api.WriteMessage(w, 200, "message", "password set")
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/mailer"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// @Summary Regenerate recovery codes
+// @Description Replace all of the current user's recovery codes with a fresh set. Previous codes stop working immediately. Requires TOTP to be enabled and password re-entry.
+// @Tags Multi-Factor Authentication
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param request body PasswordRequest true "Current password"
+// @Success 200 {object} RecoveryCodesResponse "New recovery codes - shown once"
+// @Failure 400 {object} api.ErrorResponse "Invalid request format or MFA not enabled"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized or incorrect password"
+// @Failure 409 {object} api.ErrorResponse "No password set - use /auth/set-password first"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (15 requests per hour)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/mfa/recovery-codes [post]
+func (ar *AuthRouter) HandleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRegenerateRecoveryCodes called")
+	req, err := api.DecodeJSON[PasswordRequest](w, r)
+	if err != nil {
+		applog.Error("Failed to decode regenerate recovery codes request:", err)
+		return
+	}
+
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !user.TotpEnabled {
+		applog.Warn("Recovery code regeneration without mfa", "userID:", user.ID)
+		api.WriteMessage(w, 400, "error", "mfa not enabled")
+		return
+	}
+
+	if !checkPassword(w, user, req.Password, "recovery code regeneration") {
+		return
+	}
+
+	codes, err := ar.generateRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to regenerate recovery codes:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	sendSecurityAlert(user.Email, "Recovery codes regenerated",
+		"A new set of recovery codes was generated for your account. Your previous recovery codes no longer work.",
+		utils.GetClientIP(r))
+
+	applog.Info("Recovery codes regenerated", "userID:", user.ID)
+	api.WriteJSON(w, 200, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the raw
+// values, which are never stored.
+func (ar *AuthRouter) generateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	now := time.Now().UTC().Unix()
+	raw := make([]string, 0, config.App.RecoveryCodeCount)
+	codes := make([]model.RecoveryCode, 0, config.App.RecoveryCodeCount)
+
+	for i := 0; i < config.App.RecoveryCodeCount; i++ {
+		token, err := utils.GetRandomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		raw = append(raw, token.Raw)
+		codes = append(codes, model.RecoveryCode{
+			ID:        utils.GenerateSnowflakeID(),
+			UserID:    userID,
+			CodeHash:  token.Hash,
+			CreatedAt: now,
+		})
+	}
+
+	if err := ar.RecoveryRepo.ReplaceCodes(ctx, userID, codes); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// consumeRecoveryCode checks a recovery code at the mfa login step and notifies
+// the user by email when one is used.
+func (ar *AuthRouter) consumeRecoveryCode(ctx context.Context, user *model.User, code, ip string) (bool, error) {
+	ok, err := ar.RecoveryRepo.ConsumeCode(ctx, user.ID, utils.HashRecoveryCode(code), ip)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	remaining, err := ar.RecoveryRepo.CountUnused(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	sendSecurityAlert(user.Email, "Recovery code used",
+		"A recovery code was used to sign in to your account. Unused recovery codes remaining: "+strconv.Itoa(remaining)+".",
+		ip)
+
+	applog.Info("Recovery code consumed", "userID:", user.ID, "remaining:", remaining)
+	return true, nil
+}
+
+func sendSecurityAlert(email, title, message, ip string) {
+	mailer.SendAsync("securityalert", []string{email}, title, map[string]any{
+		"Title":   title,
+		"Message": message,
+		"IP":      ip,
+		"Time":    time.Now().UTC().Format(time.RFC1123),
+	})
+}
@@ -0,0 +1,64 @@
+package auth
+
+import "testing"
+
+func TestRecoveryCodeLogin(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+	_, codes := enableTotp(c)
+	if len(codes) == 0 {
+		t.Fatal("no recovery codes after enabling totp")
+	}
+
+	mfaLogin := func(code string) int {
+		login := srv.NewClient(t)
+		if status := login.Do("POST", "/auth/login", LoginRequest{Identifier: "alice", Password: testPassword}, nil); status != 202 {
+			t.Fatalf("login with mfa: want 202, got %d", status)
+		}
+		return login.Do("POST", "/auth/mfa/login", MfaLoginRequest{RecoveryCode: code}, nil)
+	}
+
+	if status := mfaLogin(codes[0]); status != 200 {
+		t.Fatalf("recovery code login: status %d", status)
+	}
+	if status := mfaLogin(codes[0]); status != 401 {
+		t.Fatalf("used recovery code: want 401, got %d", status)
+	}
+	if status := mfaLogin("not-a-code"); status != 401 {
+		t.Fatalf("unknown recovery code: want 401, got %d", status)
+	}
+}
+
+func TestRegenerateRecoveryCodes(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+	_, old := enableTotp(c)
+
+	if status := c.Do("POST", "/auth/mfa/recovery-codes", PasswordRequest{Password: "wrong password"}, nil); status != 401 {
+		t.Fatalf("wrong password: want 401, got %d", status)
+	}
+
+	var resp RecoveryCodesResponse
+	if status := c.Do("POST", "/auth/mfa/recovery-codes", PasswordRequest{Password: testPassword}, &resp); status != 200 {
+		t.Fatalf("regenerate: status %d", status)
+	}
+	if len(resp.RecoveryCodes) != len(old) || resp.RecoveryCodes[0] == old[0] {
+		t.Fatalf("unexpected new codes %v", resp.RecoveryCodes)
+	}
+
+	login := srv.NewClient(t)
+	login.Do("POST", "/auth/login", LoginRequest{Identifier: "alice", Password: testPassword}, nil)
+	if status := login.Do("POST", "/auth/mfa/login", MfaLoginRequest{RecoveryCode: old[0]}, nil); status != 401 {
+		t.Fatalf("replaced recovery code: want 401, got %d", status)
+	}
+	if status := login.Do("POST", "/auth/mfa/login", MfaLoginRequest{RecoveryCode: resp.RecoveryCodes[0]}, nil); status != 200 {
+		t.Fatalf("new recovery code: status %d", status)
+	}
+
+	srv.DB.MustExec("UPDATE users SET password_hash = '' WHERE email = 'alice@example.com'")
+	if status := c.Do("POST", "/auth/mfa/recovery-codes", PasswordRequest{Password: testPassword}, nil); status != 409 {
+		t.Fatalf("without a password: want 409, got %d", status)
+	}
+}
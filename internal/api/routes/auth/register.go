@@ -12,7 +12,7 @@ import (
 )
 
 // @Summary Register new user account
-// @Description Register a new user account with email confirmation. The system will validate credentials, check for duplicates, hash the password, and send a confirmation email. Username cannot contain '@' symbol.
+// @Description Register a new user account with email confirmation. The system will validate credentials, check for duplicates, hash the password, and send a confirmation email. Usernames are 3 to 30 letters, digits, dashes and underscores, with letters from a single script; names made only of letters that imitate Latin ones are refused. Usernames and emails are unique ignoring case, and usernames also ignoring Unicode compatibility forms.
 // @Tags Authentication
 // @Accept json
 // @Produce json
@@ -31,13 +31,16 @@ func (ar *AuthRouter) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Username = utils.CleanUsername(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+
 	if req.Username == "" || req.Email == "" || req.Password == "" {
 		applog.Warn("Missing registration fields", "username:", req.Username, "email:", req.Email)
 		http.Error(w, "invalid credentials", http.StatusBadRequest)
 		return
 	}
 
-	if strings.Contains(req.Username, "@") || !utils.IsValidEmail(req.Email) || !utils.IsValidPassword(req.Password) {
+	if !utils.IsValidUsername(req.Username) || !utils.IsValidEmail(req.Email) || !utils.IsValidPassword(req.Password) {
 		applog.Warn("Invalid registration credentials", "username:", req.Username, "email:", req.Email)
 		http.Error(w, "invalid credentials", http.StatusBadRequest)
 		return
@@ -56,6 +59,19 @@ func (ar *AuthRouter) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	duplicate, err = ar.UserRepo.DuplicateEmail(r.Context(), req.Email)
+	if err != nil {
+		applog.Error("Failed to check duplicate email:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if duplicate {
+		applog.Warn("Duplicate email registration attempt", "email:", req.Email)
+		http.Error(w, "invalid credentials", http.StatusBadRequest)
+		return
+	}
+
 	hash, err := utils.HashPassword(req.Password)
 	if err != nil {
 		applog.Error("Failed to hash password:", err)
@@ -63,7 +79,7 @@ func (ar *AuthRouter) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user := &model.User{ID: utils.GenerateSnowflakeID(), Username: req.Username, PasswordHash: hash, Email: req.Email, CreatedAt: time.Now().UTC().Unix(), Role: "user", EmailConfirmed: false}
+	user := &model.User{ID: utils.GenerateSnowflakeID(), Username: req.Username, PasswordHash: hash, Email: req.Email, CreatedAt: time.Now().UTC().Unix(), Role: model.RoleUser, EmailConfirmed: false}
 
 	if err := ar.UserRepo.CreateUser(r.Context(), user); err != nil {
 		applog.Error("Failed to create user:", err)
@@ -71,8 +87,15 @@ func (ar *AuthRouter) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	code, err := ar.newEmailCode(r.Context(), user.ID, model.EmailCodeConfirm)
+	if err != nil {
+		applog.Error("Failed to create confirmation code:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
 	expiryStr := utils.ExpiryToString(24 * 3600)
-	token, err := GenerateTokenAndSendEmail(user.Email, "confirmregister", "Email confirmation", req.Url, map[string]any{"Expiry": expiryStr, "Url": req.Url})
+	token, err := GenerateTokenAndSendEmail(user.Email, "confirmregister", "Email confirmation", req.Url, confirmEmailData(req.Url, expiryStr, code))
 	if err != nil {
 		applog.Error("Failed to send confirmation email:", err)
 		api.WriteInternalError(w)
@@ -4,19 +4,40 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/akramboussanni/gocode/internal/applog"
 	"github.com/akramboussanni/gocode/internal/middleware"
+	"github.com/akramboussanni/gocode/internal/model"
 	"github.com/akramboussanni/gocode/internal/repo"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 type AuthRouter struct {
-	UserRepo    *repo.UserRepo
-	TokenRepo   *repo.TokenRepo
-	LockoutRepo *repo.LockoutRepo
+	UserRepo     *repo.UserRepo
+	TokenRepo    *repo.TokenRepo
+	LockoutRepo  *repo.LockoutRepo
+	RecoveryRepo *repo.RecoveryRepo
+	PasskeyRepo  *repo.PasskeyRepo
+	SecurityRepo *repo.SecurityRepo
+	SessionRepo  *repo.SessionRepo
+	ApiKeyRepo   *repo.ApiKeyRepo
+	RoleRepo     *repo.RoleRepo
+	ExportRepo   *repo.DataExportRepo
+	CodeRepo     *repo.EmailCodeRepo
+	IdentityRepo *repo.IdentityRepo
+	OAuthRepo    *repo.OAuthRepo
+	WebAuthn     *webauthn.WebAuthn
 }
 
-func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo) http.Handler {
-	ar := &AuthRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo}
+func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRepo *repo.LockoutRepo, recoveryRepo *repo.RecoveryRepo, passkeyRepo *repo.PasskeyRepo, securityRepo *repo.SecurityRepo, sessionRepo *repo.SessionRepo, apiKeyRepo *repo.ApiKeyRepo, roleRepo *repo.RoleRepo, exportRepo *repo.DataExportRepo, codeRepo *repo.EmailCodeRepo, identityRepo *repo.IdentityRepo, oauthRepo *repo.OAuthRepo) http.Handler {
+	ar := &AuthRouter{UserRepo: userRepo, TokenRepo: tokenRepo, LockoutRepo: lockoutRepo, RecoveryRepo: recoveryRepo, PasskeyRepo: passkeyRepo, SecurityRepo: securityRepo, SessionRepo: sessionRepo, ApiKeyRepo: apiKeyRepo, RoleRepo: roleRepo, ExportRepo: exportRepo, CodeRepo: codeRepo, IdentityRepo: identityRepo, OAuthRepo: oauthRepo}
+
+	var err error
+	ar.WebAuthn, err = newWebAuthn()
+	if err != nil {
+		applog.Fatal("invalid webauthn configuration:", err)
+	}
+
 	r := chi.NewRouter()
 
 	r.Use(middleware.MaxBytesMiddleware(1 << 20))
@@ -39,21 +60,84 @@ func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 		r.Post("/confirm-email", ar.HandleConfirmEmail)
 		r.Post("/resend-confirmation", ar.HandleResendConfirmation)
 		r.Post("/register", ar.HandleRegister)
+		r.Post("/cancel-deletion", ar.HandleCancelDeletion)
+		r.Post("/data-export/download", ar.HandleDownloadDataExport)
+		r.Post("/cancel-email-change", ar.HandleCancelEmailChange)
+		r.Post("/magic-link", ar.HandleSendMagicLink)
+		r.Post("/email-code", ar.HandleSendEmailCode)
 	})
 
 	//8/hour+auth+recaptcha
 	r.Group(func(r chi.Router) {
 		middleware.AddRatelimit(r, 8, 1*time.Hour)
-		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
 		middleware.AddRecaptcha(r)
 		r.Post("/change-password", ar.HandleChangePassword)
+		r.Post("/delete-account", ar.HandleDeleteAccount)
+		r.Post("/change-email", ar.HandleChangeEmail)
+		r.Post("/set-password", ar.HandleSetPassword)
+	})
+
+	//8/min
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 8, 1*time.Minute)
+		r.Post("/mfa/login", ar.HandleMfaLogin)
+		r.Post("/passkeys/login/begin", ar.HandlePasskeyLoginBegin)
+		r.Post("/passkeys/login/finish", ar.HandlePasskeyLoginFinish)
+		r.Post("/magic-link/login", ar.HandleMagicLinkLogin)
+		r.Post("/email-code/login", ar.HandleEmailCodeLogin)
+		r.Post("/oidc/{provider}/begin", ar.HandleOidcBegin)
+		r.Post("/oidc/{provider}/finish", ar.HandleOidcFinish)
+	})
+
+	//15/hour+auth
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 15, 1*time.Hour)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
+		r.Post("/mfa/totp/enroll", ar.HandleTotpEnroll)
+		r.Post("/mfa/totp/reenroll", ar.HandleTotpReenroll)
+		r.Post("/mfa/totp/verify", ar.HandleTotpVerify)
+		r.Post("/mfa/totp/disable", ar.HandleTotpDisable)
+		r.Post("/mfa/recovery-codes", ar.HandleRegenerateRecoveryCodes)
+		r.Post("/passkeys/register/begin", ar.HandlePasskeyRegisterBegin)
+		r.Post("/passkeys/register/finish", ar.HandlePasskeyRegisterFinish)
+		r.Post("/api-keys", ar.HandleCreateApiKey)
+		r.Post("/data-export", ar.HandleDataExport)
+		r.Post("/confirm-email-change", ar.HandleConfirmEmailChange)
+		r.Post("/oidc/{provider}/link/begin", ar.HandleOidcLinkBegin)
+		r.Post("/oidc/{provider}/link/finish", ar.HandleOidcLinkFinish)
 	})
 
 	//30/min+auth
 	r.Group(func(r chi.Router) {
 		middleware.AddRatelimit(r, 30, 1*time.Minute)
-		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo)
-		r.Get("/me", ar.HandleProfile)
+		middleware.AddAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
+		r.Get("/passkeys", ar.HandleListPasskeys)
+		r.Delete("/passkeys/{id}", ar.HandleDeletePasskey)
+		r.Delete("/sessions/{id}", ar.HandleRevokeSession)
+		r.Post("/sessions/revoke-others", ar.HandleRevokeOtherSessions)
+		r.Get("/api-keys", ar.HandleListApiKeys)
+		r.Delete("/api-keys/{id}", ar.HandleRevokeApiKey)
+		r.Patch("/me", ar.HandleUpdateProfile)
+		r.Get("/identities", ar.HandleListIdentities)
+		r.Delete("/identities/{id}", ar.HandleUnlinkIdentity)
+		r.Post("/oauth/authorize/consent", ar.HandleOAuthConsent)
+		r.Post("/oauth/authorize/decision", ar.HandleOAuthDecision)
+		r.Post("/oauth/device/consent", ar.HandleOAuthDeviceConsent)
+		r.Post("/oauth/device/decision", ar.HandleOAuthDeviceDecision)
+	})
+
+	//30/min+auth or api key
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 30, 1*time.Minute)
+		r.Group(func(r chi.Router) {
+			middleware.AddAuthOrApiKey(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo, ar.ApiKeyRepo, model.ScopeProfileRead)
+			r.Get("/me", ar.HandleProfile)
+		})
+		r.Group(func(r chi.Router) {
+			middleware.AddAuthOrApiKey(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo, ar.ApiKeyRepo, model.ScopeSessionsRead)
+			r.Get("/sessions", ar.HandleListSessions)
+		})
 	})
 
 	//15/min
@@ -62,5 +146,29 @@ func NewAuthRouter(userRepo *repo.UserRepo, tokenRepo *repo.TokenRepo, lockoutRe
 		r.Post("/refresh", ar.HandleRefresh)
 	})
 
+	//30/min
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 30, 1*time.Minute)
+		r.Get("/oidc/providers", ar.HandleListOidcProviders)
+		r.Get("/oauth/authorize", ar.HandleOAuthAuthorize)
+	})
+
+	//60/min
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 60, 1*time.Minute)
+		r.Post("/oauth/token", ar.HandleOAuthToken)
+		r.Post("/oauth/device", ar.HandleOAuthDeviceAuthorization)
+		r.Post("/oauth/introspect", ar.HandleOAuthIntrospect)
+		r.Post("/oauth/revoke", ar.HandleOAuthRevoke)
+	})
+
+	//60/min+access token
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 60, 1*time.Minute)
+		middleware.AddAccessTokenAuth(r, ar.UserRepo, ar.TokenRepo, ar.SessionRepo)
+		r.Get("/oauth/userinfo", ar.HandleUserInfo)
+		r.Post("/oauth/userinfo", ar.HandleUserInfo)
+	})
+
 	return r
 }
@@ -1,8 +1,13 @@
 package auth
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/akramboussanni/gocode/config"
@@ -15,15 +20,19 @@ import (
 )
 
 // @Summary Authenticate user and set session cookies
-// @Description Authenticate user with email and password, setting session and refresh cookies. User must have confirmed their email address.
+// @Description Authenticate user with email or username (as allowed by LOGIN_IDENTIFIER, matched ignoring case) and password, issuing session and refresh tokens as cookies or, with X-Token-Delivery: body, in the response body. User must have confirmed their email address. Accounts created through an external provider have no password until one is set with /auth/set-password.
 // @Tags Authentication
 // @Accept json
 // @Produce json
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
 // @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
 // @Param request body LoginRequest true "User login credentials"
-// @Success 200 {object} api.SuccessResponse "Authentication successful - session and refresh cookies set"
+// @Success 200 {object} LoginResponse "Authentication successful - session and refresh tokens issued"
+// @Success 202 {object} MfaRequiredResponse "Password accepted - second factor required, mfa token issued"
 // @Failure 400 {object} api.ErrorResponse "Invalid request format or missing required fields"
 // @Failure 401 {object} api.ErrorResponse "Invalid credentials or email not confirmed"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
+// @Failure 423 {object} api.ErrorResponse "Account locked due to repeated failed logins"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
 // @Router /auth/login [post]
@@ -36,109 +45,76 @@ func (ar *AuthRouter) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := ar.UserRepo.GetUserByEmail(r.Context(), cred.Email)
+	identifier := cred.Identifier
+	if identifier == "" {
+		identifier = cred.Email
+	}
+
+	user, err := ar.lookupLoginUser(r.Context(), strings.TrimSpace(identifier))
 	if err != nil || user == nil {
-		applog.Warn("Login failed: user not found or db error", "email:", cred.Email, "err:", err)
+		applog.Warn("Login failed: user not found or db error", "identifier:", identifier, "err:", err)
 		api.WriteInvalidCredentials(w)
 		return
 	}
 
-	lockedOut, err := ar.LockoutRepo.IsLockedOut(r.Context(), user.ID, ip)
-	if err != nil {
-		applog.Error("Error checking lockout:", err)
-		api.WriteInternalError(w)
+	if !ar.checkLockout(r.Context(), w, user.ID, ip) {
 		return
 	}
 
-	if lockedOut {
-		applog.Warn("Account locked out", "userID:", user.ID, "ip:", ip)
-		api.WriteMessage(w, 423, "error", "account locked")
+	// accounts created through an external provider have no password, and
+	// attempts on them count as failures like any other wrong password
+	if !user.HasPassword() || !utils.ComparePassword(user.PasswordHash, cred.Password) {
+		applog.Warn("Invalid password for user", "userID:", user.ID, "hasPassword:", user.HasPassword())
+		ar.registerFailedLogin(r.Context(), w, user.ID, ip)
 		return
 	}
 
-	if !utils.ComparePassword(user.PasswordHash, cred.Password) {
-		now := time.Now().UTC().Unix()
-		nowMicro := time.Now().UTC().UnixMicro()
-		err := ar.LockoutRepo.AddFailedLogin(r.Context(), model.FailedLogin{ID: nowMicro, UserID: user.ID, IPAddress: ip, AttemptedAt: now, Active: true})
-
-		if err != nil {
-			applog.Error("Failed to add failed login:", err)
-			api.WriteInternalError(w)
-			return
-		}
-
-		count, err := ar.LockoutRepo.CountRecentFailures(r.Context(), user.ID, ip)
-		if err != nil {
-			applog.Error("Failed to count recent failures:", err)
-			api.WriteInternalError(w)
-			return
-		}
-
-		if count > config.App.LockoutCount {
-			err := ar.LockoutRepo.AddLockout(r.Context(), model.Lockout{
-				ID:          nowMicro,
-				UserID:      user.ID,
-				IPAddress:   ip,
-				LockedUntil: now + config.App.LockoutDuration,
-				Reason:      "failed logins",
-				Active:      true,
-			})
-
-			if err != nil {
-				applog.Error("Failed to add lockout:", err)
-				api.WriteInternalError(w)
-				return
-			}
-
-			applog.Warn("User locked out due to failed logins", "userID:", user.ID, "ip:", ip)
-			api.WriteMessage(w, 423, "error", "account locked")
-			return
-		}
-
-		applog.Warn("Invalid password for user", "userID:", user.ID)
+	if !user.EmailConfirmed {
+		applog.Warn("Login attempt with unconfirmed email", "userID:", user.ID)
 		api.WriteInvalidCredentials(w)
 		return
 	}
 
-	if !user.EmailConfirmed {
-		applog.Warn("Login attempt with unconfirmed email", "userID:", user.ID)
-		api.WriteInvalidCredentials(w)
+	if !checkAccountStatus(w, user) {
 		return
 	}
 
-	loginTokens := GenerateLogin(jwt.CreateJwtFromUser(user))
+	if user.TotpEnabled {
+		ar.beginMfaLogin(w, r, user)
+		return
+	}
 
-	utils.ClearAllCookies(w)
-	utils.SetSessionCookie(w, loginTokens.Session)
-	utils.SetRefreshCookie(w, loginTokens.Refresh)
+	if !ar.issueLogin(w, r, user) {
+		return
+	}
 
 	applog.Info("User login successful", "userID:", user.ID)
-	api.WriteJSON(w, 200, map[string]string{"message": "login successful"})
 }
 
 // @Summary Refresh session cookies
-// @Description Refresh user's session cookies using a valid refresh cookie. The old refresh token will be revoked and new session/refresh cookies will be set.
+// @Description Refresh user's session tokens using a valid refresh token, sent as the refresh cookie or as a bearer token. The refresh token is rotated: the presented token is marked as used and a new session/refresh pair is issued. Presenting a refresh token that was already rotated revokes its whole token family and notifies the user by email.
 // @Tags Authentication
 // @Accept json
 // @Produce json
+// @Param X-Token-Delivery header string false "How to return tokens: cookie (default), body or both"
 // @Param X-Recaptcha-Token header string false "reCAPTCHA verification token (optional if reCAPTCHA is not configured)"
-// @Success 200 {object} api.SuccessResponse "Token refresh successful - new session and refresh cookies set"
-// @Failure 401 {object} api.ErrorResponse "Invalid, expired, or revoked refresh token"
+// @Success 200 {object} LoginResponse "Token refresh successful - new session and refresh tokens issued"
+// @Failure 401 {object} api.ErrorResponse "Invalid, expired, revoked or reused refresh token"
+// @Failure 403 {object} api.AccountSuspendedResponse "Account suspended, or scheduled for deletion (api.AccountPendingDeletionResponse)"
 // @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (8 requests per minute)"
 // @Failure 500 {object} api.ErrorResponse "Internal server error"
 // @Router /auth/refresh [post]
 func (ar *AuthRouter) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	applog.Info("HandleRefresh called")
 
-	// Get refresh token from cookie
-	refreshCookie, err := r.Cookie("refresh")
-	if err != nil {
-		applog.Warn("No refresh cookie found")
+	refreshToken := utils.TokenFromRequest(r, "refresh")
+	if refreshToken == "" {
+		applog.Warn("No refresh token found")
 		api.WriteInvalidCredentials(w)
 		return
 	}
 
-	claims := middleware.GetClaims(w, r, refreshCookie.Value, config.JwtSecretBytes, ar.TokenRepo)
+	claims := middleware.GetClaims(w, r, refreshToken, ar.TokenRepo)
 	if claims == nil || claims.Type != model.RefreshJwt {
 		applog.Warn("Invalid or missing refresh token")
 		api.WriteInvalidCredentials(w)
@@ -152,22 +128,299 @@ func (ar *AuthRouter) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	blacklist := model.JwtBlacklist{
-		TokenID:   claims.TokenID,
-		UserID:    claims.UserID,
-		ExpiresAt: math.MaxInt64,
+	if claims.SessionID != user.JwtSessionID {
+		applog.Warn("Refresh failed: session revoked", "userID:", user.ID)
+		api.WriteInvalidCredentials(w)
+		return
 	}
 
-	err = ar.TokenRepo.RevokeToken(r.Context(), blacklist)
-	if err != nil {
-		applog.Error("Failed to revoke old refresh token:", err)
+	if !checkAccountStatus(w, user) {
+		return
 	}
 
-	loginTokens := GenerateLogin(jwt.CreateJwtFromUser(user))
+	familyID := claims.FamilyID
+	if familyID == 0 {
+		// issued before refresh tokens were tracked: fall back to blacklisting
+		// it and start a new family from here on
+		familyID = utils.GenerateSnowflakeID()
+		err = ar.TokenRepo.RevokeToken(r.Context(), model.JwtBlacklist{
+			TokenID:   claims.TokenID,
+			UserID:    claims.UserID,
+			ExpiresAt: math.MaxInt64,
+		})
+		if err != nil {
+			applog.Error("Failed to revoke old refresh token:", err)
+		}
+
+		if err := ar.startSession(r, user.ID, familyID); err != nil {
+			applog.Error("Failed to start session:", err)
+			api.WriteInternalError(w)
+			return
+		}
+	} else {
+		revoked, err := ar.SessionRepo.IsSessionRevoked(r.Context(), familyID)
+		if err != nil {
+			applog.Error("Failed to check session:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		if revoked {
+			applog.Warn("Refresh failed: session revoked", "userID:", user.ID, "sessionID:", familyID)
+			utils.ClearAllCookies(w)
+			api.WriteInvalidCredentials(w)
+			return
+		}
 
-	utils.SetSessionCookie(w, loginTokens.Session)
-	utils.SetRefreshCookie(w, loginTokens.Refresh)
+		if !ar.rotateRefreshToken(w, r, user, claims) {
+			return
+		}
+
+		expiresAt := time.Now().UTC().Unix() + config.App.JwtExpirations[string(model.RefreshJwt)]
+		if err := ar.SessionRepo.TouchSession(r.Context(), familyID, utils.GetClientIP(r), expiresAt); err != nil {
+			applog.Error("Failed to update session activity:", err)
+		}
+	}
+
+	loginTokens, err := ar.issueTokens(r.Context(), user, familyID, claims.TokenID)
+	if err != nil {
+		applog.Error("Failed to issue refreshed tokens:", err)
+		api.WriteInternalError(w)
+		return
+	}
 
 	applog.Info("Refresh token successful", "userID:", user.ID)
-	api.WriteJSON(w, 200, map[string]string{"message": "tokens refreshed"})
+	writeLoginTokens(w, r, loginTokens, "tokens refreshed")
+}
+
+// rotateRefreshToken marks the presented refresh token as used. A token that
+// was already rotated is being replayed, so its whole family is revoked. It
+// writes the response itself when the refresh must not go ahead.
+func (ar *AuthRouter) rotateRefreshToken(w http.ResponseWriter, r *http.Request, user *model.User, claims *jwt.Claims) bool {
+	stored, err := ar.TokenRepo.GetRefreshToken(r.Context(), claims.TokenID)
+	if err != nil {
+		applog.Error("Failed to get refresh token:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if stored == nil || stored.FamilyID != claims.FamilyID || stored.Revoked {
+		applog.Warn("Refresh failed: unknown or revoked refresh token", "userID:", user.ID)
+		utils.ClearAllCookies(w)
+		api.WriteInvalidCredentials(w)
+		return false
+	}
+
+	rotated, err := ar.TokenRepo.RotateRefreshToken(r.Context(), claims.TokenID)
+	if err != nil {
+		applog.Error("Failed to rotate refresh token:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if !rotated {
+		ar.handleRefreshReuse(r.Context(), user, stored, utils.GetClientIP(r))
+		utils.ClearAllCookies(w)
+		api.WriteInvalidCredentials(w)
+		return false
+	}
+
+	return true
+}
+
+// handleRefreshReuse revokes a refresh token family after one of its rotated
+// tokens was presented again, which means either the legitimate client or an
+// attacker holds a stolen copy. Failures are logged so the caller can still
+// reject the request.
+func (ar *AuthRouter) handleRefreshReuse(ctx context.Context, user *model.User, token *model.RefreshToken, ip string) {
+	applog.Warn("Refresh token reuse detected", "userID:", user.ID, "familyID:", token.FamilyID, "ip:", ip)
+
+	if err := ar.TokenRepo.RevokeTokenFamily(ctx, token.FamilyID); err != nil {
+		applog.Error("Failed to revoke refresh token family:", err)
+	}
+
+	if _, err := ar.SessionRepo.RevokeSession(ctx, user.ID, token.FamilyID); err != nil {
+		applog.Error("Failed to revoke session:", err)
+	}
+
+	message := "A sign-in token for your account was used after it had already been replaced, which can mean it was stolen. The affected session has been signed out."
+	if config.App.RefreshReuseRevokeAll {
+		if err := ar.revokeAllSessions(ctx, user.ID); err != nil {
+			applog.Error("Failed to revoke all sessions after refresh token reuse:", err)
+		}
+		message = "A sign-in token for your account was used after it had already been replaced, which can mean it was stolen. You have been signed out on all devices."
+	}
+
+	err := ar.SecurityRepo.LogEvent(ctx, model.SecurityEvent{
+		ID:        utils.GenerateSnowflakeID(),
+		UserID:    user.ID,
+		Type:      model.RefreshTokenReuseEvent,
+		IPAddress: ip,
+		Details:   "family " + strconv.FormatInt(token.FamilyID, 10) + ", token " + token.TokenID,
+		CreatedAt: time.Now().UTC().Unix(),
+	})
+	if err != nil {
+		applog.Error("Failed to log security event:", err)
+	}
+
+	sendSecurityAlert(user.Email, "Suspicious sign-in activity", message, ip)
+}
+
+// checkAccountStatus reports whether the user's account may be used, writing
+// the response itself when it is suspended or scheduled for deletion.
+func checkAccountStatus(w http.ResponseWriter, user *model.User) bool {
+	if !user.CanSignIn(time.Now().UTC().Unix()) {
+		applog.Warn("Unavailable account refused", "userID:", user.ID, "status:", user.Status)
+		api.WriteAccountUnavailable(w, user)
+		return false
+	}
+	return true
+}
+
+// LOGIN_IDENTIFIER values besides email, with both being the default
+const (
+	loginByUsername = "username"
+	loginByBoth     = "both"
+)
+
+var errIdentifierNotAllowed = errors.New("login identifier type not allowed")
+
+// lookupLoginUser finds the account for the email or username given at login.
+// Usernames cannot contain '@', which tells the two apart, except for ones
+// registered before that rule, so an identifier matching no email is tried as
+// a username too when usernames are accepted. Failed attempts and lockouts are
+// then tracked against the account, whichever identifier was used.
+func (ar *AuthRouter) lookupLoginUser(ctx context.Context, identifier string) (*model.User, error) {
+	mode := config.App.LoginIdentifier
+	emails := mode != loginByUsername
+	usernames := mode == loginByUsername || mode == loginByBoth
+
+	if emails && strings.Contains(identifier, "@") {
+		user, err := ar.UserRepo.GetUserByEmail(ctx, identifier)
+		if !usernames || !errors.Is(err, sql.ErrNoRows) {
+			return user, err
+		}
+	} else if !usernames {
+		return nil, errIdentifierNotAllowed
+	}
+
+	return ar.UserRepo.GetUserByUsername(ctx, identifier)
+}
+
+// checkLockout reports whether the user may attempt to log in from ip, writing
+// the response itself when they may not.
+func (ar *AuthRouter) checkLockout(ctx context.Context, w http.ResponseWriter, userID int64, ip string) bool {
+	lockedOut, err := ar.LockoutRepo.IsLockedOut(ctx, userID, ip)
+	if err != nil {
+		applog.Error("Error checking lockout:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	if lockedOut {
+		applog.Warn("Account locked out", "userID:", userID, "ip:", ip)
+		api.WriteMessage(w, 423, "error", "account locked")
+		return false
+	}
+
+	return true
+}
+
+// registerFailedLogin records a failed attempt for the user and locks them out
+// once the threshold is crossed. It always writes the response.
+func (ar *AuthRouter) registerFailedLogin(ctx context.Context, w http.ResponseWriter, userID int64, ip string) {
+	now := time.Now().UTC().Unix()
+	nowMicro := time.Now().UTC().UnixMicro()
+	err := ar.LockoutRepo.AddFailedLogin(ctx, model.FailedLogin{ID: nowMicro, UserID: userID, IPAddress: ip, AttemptedAt: now, Active: true})
+
+	if err != nil {
+		applog.Error("Failed to add failed login:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	count, err := ar.LockoutRepo.CountRecentFailures(ctx, userID, ip)
+	if err != nil {
+		applog.Error("Failed to count recent failures:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if count > config.App.LockoutCount {
+		err := ar.LockoutRepo.AddLockout(ctx, model.Lockout{
+			ID:          nowMicro,
+			UserID:      userID,
+			IPAddress:   ip,
+			LockedUntil: now + config.App.LockoutDuration,
+			Reason:      "failed logins",
+			Active:      true,
+		})
+
+		if err != nil {
+			applog.Error("Failed to add lockout:", err)
+			api.WriteInternalError(w)
+			return
+		}
+
+		applog.Warn("User locked out due to failed logins", "userID:", userID, "ip:", ip)
+		api.WriteMessage(w, 423, "error", "account locked")
+		return
+	}
+
+	api.WriteInvalidCredentials(w)
+}
+
+// issueLogin hands fresh session and refresh tokens to a fully authenticated
+// user and records the login as a new device session. It writes the response
+// itself, and reports whether the login went through.
+func (ar *AuthRouter) issueLogin(w http.ResponseWriter, r *http.Request, user *model.User) bool {
+	if !checkAccountStatus(w, user) {
+		return false
+	}
+
+	familyID := utils.GenerateSnowflakeID()
+	if err := ar.startSession(r, user.ID, familyID); err != nil {
+		applog.Error("Failed to start session:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	loginTokens, err := ar.issueTokens(r.Context(), user, familyID, "")
+	if err != nil {
+		applog.Error("Failed to issue login tokens:", err)
+		api.WriteInternalError(w)
+		return false
+	}
+
+	utils.ClearAllCookies(w)
+	writeLoginTokens(w, r, loginTokens, "login successful")
+	return true
+}
+
+// issueTokens mints a session/refresh pair in the given family and records the
+// refresh token so it can be rotated later.
+func (ar *AuthRouter) issueTokens(ctx context.Context, user *model.User, familyID int64, parentID string) (model.LoginTokens, error) {
+	perms, err := ar.RoleRepo.GetPermissions(ctx, user.Role)
+	if err != nil {
+		return model.LoginTokens{}, err
+	}
+
+	token := jwt.CreateJwtFromUser(user)
+	token.Payload.FamilyID = familyID
+	token.Payload.Permissions = perms
+	loginTokens, err := GenerateLogin(token)
+	if err != nil {
+		return loginTokens, err
+	}
+
+	err = ar.TokenRepo.CreateRefreshToken(ctx, model.RefreshToken{
+		TokenID:   token.Payload.TokenID,
+		FamilyID:  familyID,
+		ParentID:  parentID,
+		UserID:    user.ID,
+		IssuedAt:  token.Payload.IssuedAt,
+		ExpiresAt: token.Payload.IssuedAt + config.App.JwtExpirations[string(model.RefreshJwt)],
+	})
+
+	return loginTokens, err
 }
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api/apitest"
+)
+
+// replayRotatedRefresh signs alice in on a laptop and a phone, refreshes the
+// laptop session, then has an attacker replay the laptop's first refresh
+// token.
+func replayRotatedRefresh(t *testing.T) (laptop, phone *apitest.Client) {
+	t.Helper()
+
+	srv := newTestServer(t)
+	laptop = srv.NewClient(t)
+	laptop.Register("alice", "alice@example.com")
+
+	phone = srv.NewClient(t)
+	if status := phone.Do("POST", "/auth/login", LoginRequest{Identifier: "alice", Password: testPassword}, nil); status != 200 {
+		t.Fatalf("phone login: status %d", status)
+	}
+
+	stolen := laptop.Cookie("/auth/refresh", "refresh")
+	if stolen == "" {
+		t.Fatal("no refresh cookie after login")
+	}
+
+	if status := laptop.Do("POST", "/auth/refresh", nil, nil); status != 200 {
+		t.Fatalf("refresh: status %d", status)
+	}
+	if laptop.Cookie("/auth/refresh", "refresh") == stolen {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	attacker := srv.NewClient(t)
+	attacker.Header.Set("Authorization", "Bearer "+stolen)
+	if status := attacker.Do("POST", "/auth/refresh", nil, nil); status != 401 {
+		t.Fatalf("replayed refresh token: want 401, got %d", status)
+	}
+
+	var events int
+	if err := srv.DB.Get(&events, "SELECT COUNT(*) FROM security_events WHERE event_type = 'refresh_token_reuse'"); err != nil {
+		t.Fatal(err)
+	}
+	if events != 1 {
+		t.Fatalf("want 1 reuse event, got %d", events)
+	}
+	return laptop, phone
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	laptop, phone := replayRotatedRefresh(t)
+
+	if status := laptop.Do("POST", "/auth/refresh", nil, nil); status != 401 {
+		t.Fatalf("refresh in the revoked family: want 401, got %d", status)
+	}
+	if status := laptop.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("session in the revoked family: want 401, got %d", status)
+	}
+
+	if status := phone.Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("other session: want 200, got %d", status)
+	}
+	if status := phone.Do("POST", "/auth/refresh", nil, nil); status != 200 {
+		t.Fatalf("other session refresh: want 200, got %d", status)
+	}
+}
+
+func TestRefreshReuseRevokesAll(t *testing.T) {
+	config.App.RefreshReuseRevokeAll = true
+	t.Cleanup(func() { config.App.RefreshReuseRevokeAll = false })
+
+	laptop, phone := replayRotatedRefresh(t)
+
+	for name, c := range map[string]*apitest.Client{"laptop": laptop, "phone": phone} {
+		if status := c.Do("GET", "/auth/me", nil, nil); status != 401 {
+			t.Fatalf("%s session: want 401, got %d", name, status)
+		}
+		if status := c.Do("POST", "/auth/refresh", nil, nil); status != 401 {
+			t.Fatalf("%s refresh: want 401, got %d", name, status)
+		}
+	}
+}
+
+// TestLoginAfterFillingNormalizedIdentities upgrades two accounts from before
+// the normalized columns whose emails and usernames collide once normalized.
+func TestLoginAfterFillingNormalizedIdentities(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("legacy", "legacy@example.com")
+	srv.NewClient(t).Register("newer", "newer@example.com")
+
+	srv.DB.MustExec("UPDATE users SET username = 'ＪＯＨＮ', email = 'John@Example.com', email_normalized = '', username_normalized = '' WHERE email = 'legacy@example.com'")
+	srv.DB.MustExec("UPDATE users SET username = 'john', email = 'john@example.com', email_normalized = '', username_normalized = '' WHERE email = 'newer@example.com'")
+
+	n, err := srv.Repos.User.FillNormalizedIdentities(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want the oldest account normalized, got %d", n)
+	}
+
+	var normalized []string
+	srv.DB.Select(&normalized, "SELECT email_normalized || ' ' || username_normalized FROM users ORDER BY id")
+	if len(normalized) != 2 || normalized[0] != "john@example.com john" || normalized[1] != " " {
+		t.Fatalf("unexpected normalized values %q", normalized)
+	}
+
+	for identifier, want := range map[string]string{"JOHN@example.com": "ＪＯＨＮ", "John": "ＪＯＨＮ", "john@example.com": "john"} {
+		c := srv.NewClient(t)
+		if status := c.Do("POST", "/auth/login", LoginRequest{Identifier: identifier, Password: testPassword}, nil); status != 200 {
+			t.Fatalf("login as %s: status %d", identifier, status)
+		}
+
+		var profile map[string]any
+		c.Do("GET", "/auth/me", nil, &profile)
+		if profile["username"] != want {
+			t.Fatalf("login as %s signed into %v, want %s", identifier, profile["username"], want)
+		}
+	}
+
+	if n, err := srv.Repos.User.FillNormalizedIdentities(context.Background()); err != nil || n != 0 {
+		t.Fatalf("second fill updated %d accounts, err %v", n, err)
+	}
+}
+
+func TestLoginLegacyUsernameWithAt(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("legacy", "legacy@example.com")
+	srv.DB.MustExec("UPDATE users SET username = 'bob@home', username_normalized = 'bob@home' WHERE email = 'legacy@example.com'")
+
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{"both", 200},
+		{"username", 200},
+		{"email", 401},
+	}
+
+	t.Cleanup(func() { config.App.LoginIdentifier = "both" })
+	for _, tt := range tests {
+		config.App.LoginIdentifier = tt.mode
+		if status := srv.NewClient(t).Do("POST", "/auth/login", LoginRequest{Identifier: "bob@home", Password: testPassword}, nil); status != tt.want {
+			t.Errorf("LOGIN_IDENTIFIER=%s: want %d, got %d", tt.mode, tt.want, status)
+		}
+	}
+
+	// the email still wins when it matches
+	config.App.LoginIdentifier = "both"
+	if status := srv.NewClient(t).Do("POST", "/auth/login", LoginRequest{Identifier: "legacy@example.com", Password: testPassword}, nil); status != 200 {
+		t.Fatalf("login by email: status %d", status)
+	}
+}
+
+func TestSessionOfDeletedAccount(t *testing.T) {
+	srv := newTestServer(t)
+	c := srv.NewClient(t)
+	c.Register("alice", "alice@example.com")
+
+	srv.DB.MustExec("DELETE FROM users WHERE id = $1", srv.UserID(t, "alice@example.com"))
+	if status := c.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("session of a deleted account: want 401, got %d", status)
+	}
+}
+
+func TestBodyTokenDelivery(t *testing.T) {
+	srv := newTestServer(t)
+	srv.NewClient(t).Register("alice", "alice@example.com")
+
+	cli := srv.NewClient(t)
+	cli.Header.Set("X-Token-Delivery", "body")
+	var login LoginResponse
+	if status := cli.Do("POST", "/auth/login", LoginRequest{Identifier: "alice", Password: testPassword}, &login); status != 200 {
+		t.Fatalf("login: status %d", status)
+	}
+	if login.LoginTokens == nil || login.Session == "" || login.Refresh == "" {
+		t.Fatal("no tokens in the body with X-Token-Delivery: body")
+	}
+	if cli.Cookie("/", "session") != "" || cli.Cookie("/auth/refresh", "refresh") != "" {
+		t.Fatal("cookies set with X-Token-Delivery: body")
+	}
+	if status := cli.Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("no credentials: want 401, got %d", status)
+	}
+
+	bearer := func(token string) *apitest.Client {
+		c := srv.NewClient(t)
+		c.Header.Set("Authorization", "Bearer "+token)
+		c.Header.Set("X-Token-Delivery", "body")
+		return c
+	}
+
+	if status := bearer(login.Session).Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("bearer session token: status %d", status)
+	}
+	if status := bearer(login.Refresh).Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("bearer refresh token on the api: want 401, got %d", status)
+	}
+	if status := bearer("not-a-token").Do("GET", "/auth/me", nil, nil); status != 401 {
+		t.Fatalf("invalid bearer token: want 401, got %d", status)
+	}
+
+	var refreshed LoginResponse
+	if status := bearer(login.Refresh).Do("POST", "/auth/refresh", nil, &refreshed); status != 200 {
+		t.Fatalf("bearer refresh: status %d", status)
+	}
+	if refreshed.LoginTokens == nil || refreshed.Refresh == login.Refresh {
+		t.Fatal("refresh did not return a rotated pair in the body")
+	}
+	if status := bearer(refreshed.Session).Do("GET", "/auth/me", nil, nil); status != 200 {
+		t.Fatalf("refreshed session token: status %d", status)
+	}
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const maxUserAgentLength = 255
+
+// @Summary List active sessions
+// @Description List the devices the current user is signed in on. The session making the request is flagged as current. Also accepts an API key with the sessions:read scope.
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {array} SessionResponse "Active sessions, most recently used first"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 403 {object} api.ErrorResponse "API key is missing the sessions:read scope"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/sessions [get]
+func (ar *AuthRouter) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleListSessions called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	current, _ := utils.SessionFromContext(r.Context())
+
+	sessions, err := ar.SessionRepo.GetActiveSessionsSafe(r.Context(), user.ID)
+	if err != nil {
+		applog.Error("Failed to list sessions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, SessionResponse{Session: s, Current: s.ID == current})
+	}
+
+	api.WriteJSON(w, 200, resp)
+}
+
+// @Summary Revoke a session
+// @Description Sign the current user out on one device. Its session and refresh tokens stop working immediately.
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Success 200 {object} api.SuccessResponse "Session revoked"
+// @Failure 400 {object} api.ErrorResponse "Invalid session ID"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 404 {object} api.ErrorResponse "Session not found"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/sessions/{id} [delete]
+func (ar *AuthRouter) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRevokeSession called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		api.WriteMessage(w, 400, "error", "invalid session id")
+		return
+	}
+
+	revoked, err := ar.SessionRepo.RevokeSession(r.Context(), user.ID, id)
+	if err != nil {
+		applog.Error("Failed to revoke session:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	if !revoked {
+		api.WriteMessage(w, 404, "error", "session not found")
+		return
+	}
+
+	if current, ok := utils.SessionFromContext(r.Context()); ok && current == id {
+		utils.ClearAllCookies(w)
+	}
+
+	applog.Info("Session revoked", "userID:", user.ID, "sessionID:", id)
+	api.WriteMessage(w, 200, "message", "session revoked")
+}
+
+// @Summary Revoke all other sessions
+// @Description Sign the current user out on every device except the one making the request.
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Security CookieAuth
+// @Security BearerAuth
+// @Success 200 {object} api.SuccessResponse "Other sessions revoked"
+// @Failure 400 {object} api.ErrorResponse "Current session is not tracked - sign in again first"
+// @Failure 401 {object} api.ErrorResponse "Unauthorized - invalid or missing session cookie"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (30 requests per minute)"
+// @Failure 500 {object} api.ErrorResponse "Internal server error"
+// @Router /auth/sessions/revoke-others [post]
+func (ar *AuthRouter) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	applog.Info("HandleRevokeOtherSessions called")
+	user, ok := utils.UserFromContext(r.Context())
+	if !ok {
+		applog.Error("Failed to get user from context")
+		api.WriteInternalError(w)
+		return
+	}
+
+	current, ok := utils.SessionFromContext(r.Context())
+	if !ok {
+		api.WriteMessage(w, 400, "error", "current session not tracked")
+		return
+	}
+
+	count, err := ar.SessionRepo.RevokeOtherSessions(r.Context(), user.ID, current)
+	if err != nil {
+		applog.Error("Failed to revoke other sessions:", err)
+		api.WriteInternalError(w)
+		return
+	}
+
+	applog.Info("Other sessions revoked", "userID:", user.ID, "count:", count)
+	api.WriteMessage(w, 200, "message", "other sessions revoked")
+}
+
+// startSession records a new device session. Its ID is the refresh token
+// family, so the tokens and the session can be matched to each other.
+func (ar *AuthRouter) startSession(r *http.Request, userID, familyID int64) error {
+	return ar.SessionRepo.CreateSession(r.Context(), newSession(r, userID, familyID, config.App.JwtExpirations[string(model.RefreshJwt)]))
+}
+
+// newSession describes a session started by request r that lasts lifetime
+// seconds unless its tokens are refreshed.
+func newSession(r *http.Request, userID, familyID, lifetime int64) model.Session {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now().UTC().Unix()
+	return model.Session{
+		ID:         familyID,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  utils.GetClientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now + lifetime,
+	}
+}
+
+// revokeAllSessions signs the user out everywhere by rotating their jwt session
+// id, and marks every tracked session as revoked so they no longer get listed.
+func (ar *AuthRouter) revokeAllSessions(ctx context.Context, userID int64) error {
+	if err := ar.UserRepo.ChangeJwtSessionID(ctx, userID, utils.GenerateSnowflakeID()); err != nil {
+		return err
+	}
+	return ar.SessionRepo.RevokeAllSessions(ctx, userID)
+}
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"net/http"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
 	"github.com/akramboussanni/gocode/internal/jwt"
 	"github.com/akramboussanni/gocode/internal/mailer"
 	"github.com/akramboussanni/gocode/internal/model"
@@ -27,12 +31,66 @@ func GenerateTokenAndSendEmail(email, templateName, subject, url string, data an
 	return token, nil
 }
 
-func GenerateLogin(jwtToken jwt.Jwt) model.LoginTokens {
-	sessionToken := jwtToken.WithType(model.CredentialJwt).GenerateToken()
-	refreshToken := jwtToken.WithType(model.RefreshJwt).GenerateToken()
+func GenerateLogin(jwtToken jwt.Jwt) (model.LoginTokens, error) {
+	sessionToken, err := jwtToken.WithType(model.CredentialJwt).GenerateToken()
+	if err != nil {
+		return model.LoginTokens{}, err
+	}
+
+	refreshToken, err := jwtToken.WithType(model.RefreshJwt).GenerateToken()
+	if err != nil {
+		return model.LoginTokens{}, err
+	}
 
 	return model.LoginTokens{
 		Session: sessionToken,
 		Refresh: refreshToken,
+	}, nil
+}
+
+type tokenDelivery string
+
+const (
+	deliverCookie tokenDelivery = "cookie"
+	deliverBody   tokenDelivery = "body"
+	deliverBoth   tokenDelivery = "both"
+)
+
+// requestedDelivery picks how issued tokens reach the client: the
+// X-Token-Delivery header when it is valid, otherwise TOKEN_DELIVERY. Browsers
+// should stick to cookies; body delivery is meant for mobile apps and CLI tools
+// that send tokens back as bearer tokens.
+func requestedDelivery(r *http.Request) tokenDelivery {
+	switch d := tokenDelivery(r.Header.Get("X-Token-Delivery")); d {
+	case deliverCookie, deliverBody, deliverBoth:
+		return d
 	}
+
+	switch d := tokenDelivery(config.App.TokenDelivery); d {
+	case deliverBody, deliverBoth:
+		return d
+	}
+	return deliverCookie
+}
+
+func (d tokenDelivery) cookies() bool { return d != deliverBody }
+func (d tokenDelivery) body() bool    { return d != deliverCookie }
+
+// writeLoginTokens hands freshly issued tokens to the client and writes the
+// success response.
+func writeLoginTokens(w http.ResponseWriter, r *http.Request, tokens model.LoginTokens, message string) {
+	delivery := requestedDelivery(r)
+	resp := LoginResponse{Message: message}
+
+	if delivery.cookies() {
+		utils.SetSessionCookie(w, tokens.Session)
+		utils.SetRefreshCookie(w, tokens.Refresh)
+	}
+
+	if delivery.body() {
+		w.Header().Set("Cache-Control", "no-store")
+		resp.LoginTokens = &tokens
+	}
+
+	api.WriteJSON(w, 200, resp)
 }
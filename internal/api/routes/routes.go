@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/api/routes/admin"
 	"github.com/akramboussanni/gocode/internal/api/routes/auth"
+	"github.com/akramboussanni/gocode/internal/api/routes/wellknown"
 	"github.com/akramboussanni/gocode/internal/middleware"
 	"github.com/akramboussanni/gocode/internal/repo"
 	"github.com/go-chi/chi/v5"
@@ -26,7 +28,9 @@ func SetupRouter(repos *repo.Repos) http.Handler {
 
 	api.AddSwaggerRoutes(r)
 
-	r.Mount("/auth", auth.NewAuthRouter(repos.User, repos.Token, repos.Lockout))
+	r.Mount("/auth", auth.NewAuthRouter(repos.User, repos.Token, repos.Lockout, repos.Recovery, repos.Passkey, repos.Security, repos.Session, repos.ApiKey, repos.Role, repos.Export, repos.Code, repos.Identity, repos.OAuth))
+	r.Mount("/admin", admin.NewAdminRouter(repos.User, repos.Token, repos.Session, repos.Lockout, repos.Role, repos.Security, repos.OAuth))
+	r.Mount("/.well-known", wellknown.NewWellKnownRouter())
 
 	return r
 }
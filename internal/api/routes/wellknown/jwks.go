@@ -0,0 +1,20 @@
+package wellknown
+
+import (
+	"net/http"
+
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/jwt"
+)
+
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying session tokens offline, matched to tokens by their kid header. Empty when tokens are signed with HS256, since a shared secret is never published.
+// @Tags Well-Known
+// @Produce json
+// @Success 200 {object} jwt.JWKS "Public signing keys"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Router /.well-known/jwks.json [get]
+func HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	api.WriteJSON(w, 200, jwt.PublicKeys())
+}
@@ -0,0 +1,74 @@
+package wellknown
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/akramboussanni/gocode/config"
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/model"
+)
+
+// @Description OpenID Provider metadata (OpenID Connect Discovery 1.0)
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer" example:"https://auth.example.com"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint" example:"https://auth.example.com/auth/oauth/authorize"`
+	TokenEndpoint                     string   `json:"token_endpoint" example:"https://auth.example.com/auth/oauth/token"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint" example:"https://auth.example.com/auth/oauth/userinfo"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint" example:"https://auth.example.com/auth/oauth/device"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint" example:"https://auth.example.com/auth/oauth/introspect"`
+	RevocationEndpoint                string   `json:"revocation_endpoint" example:"https://auth.example.com/auth/oauth/revoke"`
+	JwksURI                           string   `json:"jwks_uri" example:"https://auth.example.com/.well-known/jwks.json"`
+	ScopesSupported                   []string `json:"scopes_supported" example:"openid,profile,email"`
+	ResponseTypesSupported            []string `json:"response_types_supported" example:"code"`
+	GrantTypesSupported               []string `json:"grant_types_supported" example:"authorization_code,refresh_token,client_credentials"`
+	SubjectTypesSupported             []string `json:"subject_types_supported" example:"public"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported" example:"RS256"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported" example:"client_secret_basic,client_secret_post,none"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported" example:"S256"`
+	ClaimsSupported                   []string `json:"claims_supported" example:"sub,name,email"`
+	AuthorizationResponseIssParameter bool     `json:"authorization_response_iss_parameter_supported" example:"true"`
+}
+
+// @Summary OpenID Connect discovery
+// @Description Metadata other apps use to sign users in through this server, built from OAUTH_ISSUER. ID tokens are signed with the active key from /.well-known/jwks.json, so the openid scope and ID token algorithms are only listed while it is RS256, ES256 or EdDSA.
+// @Tags Well-Known
+// @Produce json
+// @Success 200 {object} OpenIDConfiguration "Provider metadata"
+// @Failure 429 {object} api.ErrorResponse "Rate limit exceeded (60 requests per minute)"
+// @Router /.well-known/openid-configuration [get]
+func HandleOpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	base := strings.TrimSuffix(config.App.OAuthIssuer, "/")
+
+	// ID tokens need a published key, so openid is only offered with one
+	scopes := model.OAuthScopes
+	algorithms := []string{}
+	if jwt.CanSignPublic() {
+		algorithms = append(algorithms, jwt.SigningAlgorithm())
+	} else {
+		scopes = slices.DeleteFunc(slices.Clone(scopes), func(s string) bool { return s == model.ScopeOpenID })
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	api.WriteJSON(w, 200, OpenIDConfiguration{
+		Issuer:                            config.App.OAuthIssuer,
+		AuthorizationEndpoint:             base + "/auth/oauth/authorize",
+		TokenEndpoint:                     base + "/auth/oauth/token",
+		UserinfoEndpoint:                  base + "/auth/oauth/userinfo",
+		DeviceAuthorizationEndpoint:       base + "/auth/oauth/device",
+		IntrospectionEndpoint:             base + "/auth/oauth/introspect",
+		RevocationEndpoint:                base + "/auth/oauth/revoke",
+		JwksURI:                           base + "/.well-known/jwks.json",
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               model.OAuthGrantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "name", "preferred_username", "picture", "locale", "zoneinfo", "email", "email_verified"},
+		AuthorizationResponseIssParameter: true,
+	})
+}
@@ -0,0 +1,22 @@
+package wellknown
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+func NewWellKnownRouter() http.Handler {
+	r := chi.NewRouter()
+
+	//60/min
+	r.Group(func(r chi.Router) {
+		middleware.AddRatelimit(r, 60, 1*time.Minute)
+		r.Get("/jwks.json", HandleJWKS)
+		r.Get("/openid-configuration", HandleOpenIDConfiguration)
+	})
+
+	return r
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/akramboussanni/gocode/internal/model"
 )
 
 func WriteJSON(w http.ResponseWriter, status int, data any) {
@@ -34,3 +36,12 @@ func WriteInternalError(w http.ResponseWriter) {
 func WriteInvalidCredentials(w http.ResponseWriter) {
 	http.Error(w, "invalid credentials", http.StatusUnauthorized)
 }
+
+// WriteAccountUnavailable tells a user who may not sign in why.
+func WriteAccountUnavailable(w http.ResponseWriter, user *model.User) {
+	if user.IsPendingDeletion() {
+		WriteJSON(w, http.StatusForbidden, AccountPendingDeletionResponse{Error: "account scheduled for deletion", DeleteAt: user.StatusUntil})
+		return
+	}
+	WriteJSON(w, http.StatusForbidden, AccountSuspendedResponse{Error: "account suspended", Reason: user.StatusReason, Until: user.StatusUntil})
+}
@@ -0,0 +1,11 @@
+ALTER TABLE users
+ADD COLUMN totp_secret VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN totp_pending_secret VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE users
+ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
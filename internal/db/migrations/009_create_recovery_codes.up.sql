@@ -0,0 +1,11 @@
+CREATE TABLE recovery_codes (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    created_at BIGINT NOT NULL,
+    used_at BIGINT NOT NULL DEFAULT 0,
+    used_ip VARCHAR(45) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
+CREATE UNIQUE INDEX idx_recovery_codes_hash ON recovery_codes(user_id, code_hash);
@@ -0,0 +1,23 @@
+CREATE TABLE webauthn_credentials (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    credential_id VARCHAR(1024) NOT NULL UNIQUE,
+    credential TEXT NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL,
+    last_used_at BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_webauthn_credentials_user ON webauthn_credentials(user_id);
+
+CREATE TABLE webauthn_sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    ceremony VARCHAR(16) NOT NULL,
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    session_data TEXT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_webauthn_sessions_expires_at ON webauthn_sessions(expires_at);
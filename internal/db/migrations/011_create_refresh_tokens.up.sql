@@ -0,0 +1,14 @@
+CREATE TABLE refresh_tokens (
+    jti VARCHAR(255) PRIMARY KEY,
+    family_id BIGINT NOT NULL,
+    parent_jti VARCHAR(255) NOT NULL DEFAULT '',
+    user_id BIGINT NOT NULL,
+    issued_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    rotated_at BIGINT NOT NULL DEFAULT 0,
+    revoked BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(user_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,11 @@
+CREATE TABLE security_events (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_security_events_user ON security_events(user_id);
+CREATE INDEX idx_security_events_created_at ON security_events(created_at);
@@ -0,0 +1,14 @@
+CREATE TABLE api_keys (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL DEFAULT 0,
+    last_used_at BIGINT NOT NULL DEFAULT 0,
+    last_used_ip VARCHAR(45) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
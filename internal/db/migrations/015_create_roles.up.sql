@@ -0,0 +1,32 @@
+CREATE TABLE roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE permissions (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_name VARCHAR(32) NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role_name, permission)
+);
+
+INSERT INTO roles (name, description, created_at) VALUES
+    ('user', 'Default role for registered accounts', 0),
+    ('admin', 'Full access to user and role management', 0);
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'View any user account'),
+    ('users:write', 'Modify any user account'),
+    ('roles:read', 'View roles and their permissions'),
+    ('roles:write', 'Assign roles to users');
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+    ('admin', 'users:read'),
+    ('admin', 'users:write'),
+    ('admin', 'roles:read'),
+    ('admin', 'roles:write');
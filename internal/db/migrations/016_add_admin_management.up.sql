@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN account_status VARCHAR(16) NOT NULL DEFAULT 'active';
+
+ALTER TABLE security_events
+ADD COLUMN actor_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN status_reason VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN status_until BIGINT NOT NULL DEFAULT 0;
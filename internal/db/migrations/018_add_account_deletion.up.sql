@@ -0,0 +1,4 @@
+ALTER TABLE users
+ADD COLUMN deletion_cancel_token VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_users_account_status ON users(account_status, status_until);
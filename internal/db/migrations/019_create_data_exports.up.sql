@@ -0,0 +1,12 @@
+CREATE TABLE data_exports (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    format VARCHAR(8) NOT NULL,
+    content TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_data_exports_user ON data_exports(user_id);
+CREATE INDEX idx_data_exports_expires ON data_exports(expires_at);
@@ -0,0 +1,17 @@
+ALTER TABLE users
+ADD COLUMN display_name VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';
+
+ALTER TABLE users
+ADD COLUMN username_changed_at BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+ALTER TABLE users
+ADD COLUMN email_normalized TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN username_normalized TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX idx_users_email_normalized ON users(email_normalized) WHERE email_normalized <> '';
+
+CREATE UNIQUE INDEX idx_users_username_normalized ON users(username_normalized) WHERE username_normalized <> '';
@@ -0,0 +1,5 @@
+ALTER TABLE users
+ADD COLUMN magic_link_token TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE users
+ADD COLUMN magic_link_issuedat BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+CREATE TABLE email_codes (
+    user_id BIGINT NOT NULL,
+    purpose VARCHAR(16) NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, purpose)
+);
@@ -0,0 +1,22 @@
+CREATE TABLE identities (
+    id BIGINT PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    linked_at BIGINT NOT NULL,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX idx_identities_user ON identities(user_id);
+
+CREATE TABLE oidc_states (
+    id VARCHAR(64) PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    state VARCHAR(64) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_oidc_states_expires_at ON oidc_states(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE oidc_states
+ADD COLUMN user_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,42 @@
+CREATE TABLE oauth_clients (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    secret_hash VARCHAR(255) NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL DEFAULT '',
+    scopes TEXT NOT NULL DEFAULT '',
+    grant_types TEXT NOT NULL DEFAULT '',
+    created_by BIGINT NOT NULL DEFAULT 0,
+    created_at BIGINT NOT NULL
+);
+
+CREATE TABLE oauth_codes (
+    code_hash VARCHAR(255) PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce VARCHAR(255) NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_oauth_codes_expires_at ON oauth_codes(expires_at);
+
+CREATE TABLE oauth_consents (
+    user_id BIGINT NOT NULL,
+    client_id VARCHAR(64) NOT NULL,
+    scope TEXT NOT NULL,
+    granted_at BIGINT NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
+
+ALTER TABLE sessions
+ADD COLUMN client_id VARCHAR(64) NOT NULL DEFAULT '';
+
+INSERT INTO permissions (name, description) VALUES
+    ('clients:read', 'View registered OAuth clients'),
+    ('clients:write', 'Register and delete OAuth clients');
+
+INSERT INTO role_permissions (role_name, permission) VALUES
+    ('admin', 'clients:read'),
+    ('admin', 'clients:write');
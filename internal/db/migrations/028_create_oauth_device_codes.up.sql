@@ -0,0 +1,13 @@
+CREATE TABLE oauth_device_codes (
+    device_code_hash VARCHAR(255) PRIMARY KEY,
+    user_code_hash VARCHAR(255) NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL,
+    scope TEXT NOT NULL,
+    user_id BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    poll_interval BIGINT NOT NULL,
+    last_polled_at BIGINT NOT NULL DEFAULT 0,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_oauth_device_codes_expires_at ON oauth_device_codes(expires_at);
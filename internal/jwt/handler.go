@@ -1,54 +1,101 @@
 package jwt
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"strings"
 	"time"
 
-	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/model"
 	"github.com/akramboussanni/gocode/internal/repo"
 	"github.com/google/uuid"
 )
 
-func (jwt Jwt) GenerateToken() string {
-	header, _ := json.Marshal(jwt.Header)
-	payload, _ := json.Marshal(jwt.Payload)
+func (jwt Jwt) GenerateToken() (string, error) {
+	return sign(currentKeyring().Active(), jwt.Header, jwt.Payload)
+}
+
+// SignPublic signs arbitrary claims, such as those of an OpenID Connect ID
+// token, for other parties to verify against the JWKS. It fails while the
+// active key is an HS256 secret, since that is never published.
+func SignPublic(claims any) (string, error) {
+	signer := currentKeyring().Active()
+	if _, ok := signer.PublicJWK(); !ok {
+		return "", errors.New("the active signing key is not published, sign with RS256, ES256 or EdDSA")
+	}
+	return sign(signer, Header{Type: "JWT"}, claims)
+}
 
-	data := base64.URLEncoding.EncodeToString(header) + "." + base64.URLEncoding.EncodeToString(payload)
+// CanSignPublic reports whether the active key is asymmetric, so SignPublic
+// works. Requests for ID tokens are refused up front when it is not.
+func CanSignPublic() bool {
+	_, ok := currentKeyring().Active().PublicJWK()
+	return ok
+}
+
+// SigningAlgorithm returns the algorithm new tokens are signed with.
+func SigningAlgorithm() string {
+	return currentKeyring().Active().Algorithm()
+}
 
-	h := hmac.New(sha256.New, config.JwtSecretBytes)
-	h.Write([]byte(data))
-	rawSig := h.Sum(nil)
+func sign(signer Signer, header Header, claims any) (string, error) {
+	header.Algorithm = signer.Algorithm()
+	header.KeyID = signer.KeyID()
+
+	headerBytes, _ := json.Marshal(header)
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	data := base64.RawURLEncoding.EncodeToString(headerBytes) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sig, err := signer.Sign([]byte(data))
+	if err != nil {
+		return "", err
+	}
 
-	return data + "." + base64.URLEncoding.EncodeToString(rawSig)
+	return data + "." + base64.RawURLEncoding.EncodeToString(sig), nil
 }
 
-func ValidateToken(token string, secret []byte, tr *repo.TokenRepo) (*Claims, error) {
+func ValidateToken(token string, tr *repo.TokenRepo) (*Claims, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, errors.New("invalid token format")
 	}
 
+	headerBytes, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, errors.New("invalid header encoding")
+	}
+
+	var header Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.New("invalid header json")
+	}
+
+	signer, ok := currentKeyring().Lookup(header.KeyID)
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+
+	// never let the token pick the algorithm
+	if header.Algorithm != signer.Algorithm() {
+		return nil, errors.New("unexpected signing algorithm")
+	}
+
 	data := parts[0] + "." + parts[1]
-	signature, err := base64.URLEncoding.DecodeString(parts[2])
+	signature, err := decodeSegment(parts[2])
 	if err != nil {
 		return nil, errors.New("invalid signature encoding")
 	}
 
-	h := hmac.New(sha256.New, secret)
-	h.Write([]byte(data))
-	expectedSig := h.Sum(nil)
-
-	if !hmac.Equal(signature, expectedSig) {
+	if !signer.Verify([]byte(data), signature) {
 		return nil, errors.New("invalid token signature")
 	}
 
-	payloadBytes, err := base64.URLEncoding.DecodeString(parts[1])
+	payloadBytes, err := decodeSegment(parts[1])
 	if err != nil {
 		return nil, errors.New("invalid payload encoding")
 	}
@@ -73,11 +120,16 @@ func ValidateToken(token string, secret []byte, tr *repo.TokenRepo) (*Claims, er
 	return &claims, nil
 }
 
+// decodeSegment accepts both unpadded base64url, as the JWT spec requires, and
+// the padded form tokens were issued with before.
+func decodeSegment(seg string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+}
+
 func CreateJwt(claims Claims) Jwt {
 	return Jwt{
 		Header: Header{
-			Algorithm: "HS256",
-			Type:      "JWT",
+			Type: "JWT",
 		},
 		Payload: claims,
 	}
@@ -0,0 +1,221 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/applog"
+)
+
+const ManifestFile = "keyring.json"
+
+type KeyStatus string
+
+const (
+	// KeyActive signs new tokens. Exactly one key is active.
+	KeyActive KeyStatus = "active"
+	// KeyVerify is published and accepted for verification but does not sign.
+	// New keys start here so every instance and downstream cache knows them
+	// before they are promoted; demoted keys stay here until their tokens expire.
+	KeyVerify KeyStatus = "verify"
+	// KeyRetired is ignored entirely. Tokens it signed stop validating.
+	KeyRetired KeyStatus = "retired"
+)
+
+// ManifestKey describes one key in the key directory. File is relative to the
+// directory and holds either a PEM private key or, for HS256, a base64 secret.
+type ManifestKey struct {
+	KeyID     string    `json:"kid"`
+	Algorithm string    `json:"alg"`
+	File      string    `json:"file"`
+	Status    KeyStatus `json:"status"`
+	CreatedAt int64     `json:"created_at"`
+	UpdatedAt int64     `json:"updated_at"`
+}
+
+type Manifest struct {
+	Keys []ManifestKey `json:"keys"`
+}
+
+func LoadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{Keys: []ManifestKey{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.New("invalid " + ManifestFile + ": " + err.Error())
+	}
+	return &m, nil
+}
+
+// Save writes the manifest atomically so a server reloading it never sees a
+// partial file.
+func (m *Manifest) Save(dir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+func (m *Manifest) Find(kid string) *ManifestKey {
+	for i := range m.Keys {
+		if m.Keys[i].KeyID == kid {
+			return &m.Keys[i]
+		}
+	}
+	return nil
+}
+
+// Keyring holds the active signing key and every key still accepted for
+// verification.
+type Keyring struct {
+	active Signer
+	keys   map[string]Signer
+	order  []string
+}
+
+func NewKeyring(active Signer, verify ...Signer) *Keyring {
+	k := &Keyring{active: active, keys: map[string]Signer{}}
+	for _, s := range append([]Signer{active}, verify...) {
+		if _, ok := k.keys[s.KeyID()]; ok {
+			continue
+		}
+		k.keys[s.KeyID()] = s
+		k.order = append(k.order, s.KeyID())
+	}
+	return k
+}
+
+// LoadKeyring builds a keyring from the manifest in dir.
+func LoadKeyring(dir string) (*Keyring, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var active Signer
+	var verify []Signer
+	for _, key := range m.Keys {
+		if key.Status == KeyRetired {
+			continue
+		}
+
+		s, err := loadManifestKey(dir, key)
+		if err != nil {
+			return nil, errors.New("key " + key.KeyID + ": " + err.Error())
+		}
+
+		switch key.Status {
+		case KeyActive:
+			if active != nil {
+				return nil, errors.New("more than one active key in " + ManifestFile)
+			}
+			active = s
+		case KeyVerify:
+			verify = append(verify, s)
+		default:
+			return nil, errors.New("key " + key.KeyID + ": unknown status " + string(key.Status))
+		}
+	}
+
+	if active == nil {
+		return nil, errors.New("no active key in " + ManifestFile)
+	}
+	return NewKeyring(active, verify...), nil
+}
+
+func loadManifestKey(dir string, key ManifestKey) (Signer, error) {
+	k, err := LoadKeyFile(key.Algorithm, filepath.Join(dir, key.File))
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(key.Algorithm, key.KeyID, k)
+}
+
+func (k *Keyring) Active() Signer {
+	return k.active
+}
+
+// Lookup finds the key a token names in its kid header. Tokens without a kid
+// predate key ids and are checked against the active key.
+func (k *Keyring) Lookup(kid string) (Signer, bool) {
+	if kid == "" {
+		return k.active, true
+	}
+	s, ok := k.keys[kid]
+	return s, ok
+}
+
+func (k *Keyring) PublicKeys() JWKS {
+	keys := JWKS{Keys: []JWK{}}
+	for _, kid := range k.order {
+		if jwk, ok := k.keys[kid].PublicJWK(); ok {
+			keys.Keys = append(keys.Keys, jwk)
+		}
+	}
+	return keys
+}
+
+var (
+	ringMu sync.RWMutex
+	ring   *Keyring
+)
+
+func currentKeyring() *Keyring {
+	ringMu.RLock()
+	defer ringMu.RUnlock()
+	return ring
+}
+
+func setKeyring(k *Keyring) {
+	ringMu.Lock()
+	ring = k
+	ringMu.Unlock()
+}
+
+// watchKeyring reloads the key directory whenever its manifest changes, so keys
+// can be promoted and retired without a restart. A broken manifest is logged
+// and the current keyring kept.
+func watchKeyring(dir string, interval time.Duration) {
+	path := filepath.Join(dir, ManifestFile)
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil {
+			applog.Error("Failed to stat jwt keyring:", err)
+			continue
+		}
+		if !info.ModTime().After(lastMod) {
+			continue
+		}
+		lastMod = info.ModTime()
+
+		k, err := LoadKeyring(dir)
+		if err != nil {
+			applog.Error("Failed to reload jwt keyring, keeping previous keys:", err)
+			continue
+		}
+
+		setKeyring(k)
+		applog.Info("JWT keyring reloaded", "activeKid:", k.Active().KeyID(), "keys:", len(k.order))
+	}
+}
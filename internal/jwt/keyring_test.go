@@ -0,0 +1,248 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/repo"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+// addKey generates a key for alg in dir and adds it to the manifest with status.
+func addKey(t *testing.T, dir string, m *Manifest, alg, kid string, status KeyStatus) {
+	t.Helper()
+
+	_, contents, err := GenerateKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, kid+".key"), contents, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Keys = append(m.Keys, ManifestKey{KeyID: kid, Algorithm: alg, File: kid + ".key", Status: status})
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setStatus(t *testing.T, dir string, m *Manifest, kid string, status KeyStatus) {
+	t.Helper()
+	m.Find(kid).Status = status
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadKeyring(t *testing.T, dir string) *Keyring {
+	t.Helper()
+	k, err := LoadKeyring(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setKeyring(k)
+	return k
+}
+
+func TestLoadKeyring(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manifest{}
+	addKey(t, dir, m, ES256, "signing", KeyActive)
+	addKey(t, dir, m, EdDSA, "next", KeyVerify)
+	addKey(t, dir, m, HS256, "legacy", KeyVerify)
+	addKey(t, dir, m, ES256, "old", KeyRetired)
+
+	k := loadKeyring(t, dir)
+
+	if kid := k.Active().KeyID(); kid != "signing" {
+		t.Fatalf("active key %s, want signing", kid)
+	}
+	for kid, want := range map[string]bool{"signing": true, "next": true, "legacy": true, "old": false, "unknown": false} {
+		if _, ok := k.Lookup(kid); ok != want {
+			t.Errorf("Lookup(%s) = %v, want %v", kid, ok, want)
+		}
+	}
+	if s, _ := k.Lookup(""); s.KeyID() != "signing" {
+		t.Errorf("token without kid checked against %s, want the active key", s.KeyID())
+	}
+
+	var published []string
+	for _, jwk := range k.PublicKeys().Keys {
+		published = append(published, jwk.KeyID)
+	}
+	if got := strings.Join(published, ","); got != "signing,next" {
+		t.Fatalf("published keys %s, want signing,next", got)
+	}
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string, m *Manifest)
+		err   string
+	}{
+		{"empty", func(t *testing.T, dir string, m *Manifest) {}, "no active key"},
+		{"only verify keys", func(t *testing.T, dir string, m *Manifest) {
+			addKey(t, dir, m, ES256, "a", KeyVerify)
+		}, "no active key"},
+		{"two active keys", func(t *testing.T, dir string, m *Manifest) {
+			addKey(t, dir, m, ES256, "a", KeyActive)
+			addKey(t, dir, m, ES256, "b", KeyActive)
+		}, "more than one active key"},
+		{"unknown status", func(t *testing.T, dir string, m *Manifest) {
+			addKey(t, dir, m, ES256, "a", KeyActive)
+			addKey(t, dir, m, ES256, "b", "pending")
+		}, "unknown status"},
+		{"missing key file", func(t *testing.T, dir string, m *Manifest) {
+			addKey(t, dir, m, ES256, "a", KeyActive)
+			os.Remove(filepath.Join(dir, "a.key"))
+		}, "key a"},
+		{"algorithm mismatch", func(t *testing.T, dir string, m *Manifest) {
+			addKey(t, dir, m, ES256, "a", KeyActive)
+			m.Keys[0].Algorithm = RS256
+			m.Save(dir)
+		}, "key a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(t, dir, &Manifest{})
+
+			_, err := LoadKeyring(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("want error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func newTokenRepo(t *testing.T) *repo.TokenRepo {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile(filepath.Join("..", "db", "migrations", "002_create_jwt_blacklist.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return repo.NewTokenRepo(db)
+}
+
+func issue(t *testing.T, jti string) string {
+	t.Helper()
+	token, err := CreateJwt(Claims{TokenID: jti, IssuedAt: time.Now().Unix(), Expiration: time.Now().Unix() + 60}).GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func kidOf(t *testing.T, token string) string {
+	t.Helper()
+	segment, _, _ := strings.Cut(token, ".")
+	b, _ := decodeSegment(segment)
+	var header Header
+	if err := json.Unmarshal(b, &header); err != nil {
+		t.Fatal(err)
+	}
+	return header.KeyID
+}
+
+// TestKeyRotation walks through the rotation documented in cmd/keys: add a
+// verify-only key, promote it, then retire the old one.
+func TestKeyRotation(t *testing.T) {
+	tr := newTokenRepo(t)
+	dir := t.TempDir()
+	m := &Manifest{}
+	addKey(t, dir, m, ES256, "old", KeyActive)
+	loadKeyring(t, dir)
+
+	before := issue(t, "before")
+
+	addKey(t, dir, m, EdDSA, "new", KeyVerify)
+	loadKeyring(t, dir)
+	if kid := kidOf(t, issue(t, "unpromoted")); kid != "old" {
+		t.Fatalf("verify-only key signed a token: kid %s", kid)
+	}
+
+	setStatus(t, dir, m, "old", KeyVerify)
+	setStatus(t, dir, m, "new", KeyActive)
+	loadKeyring(t, dir)
+
+	after := issue(t, "after")
+	if kid := kidOf(t, after); kid != "new" {
+		t.Fatalf("promoted key not signing: kid %s", kid)
+	}
+	for _, token := range []string{before, after} {
+		if _, err := ValidateToken(token, tr); err != nil {
+			t.Fatalf("token rejected after promotion: %v", err)
+		}
+	}
+
+	setStatus(t, dir, m, "old", KeyRetired)
+	loadKeyring(t, dir)
+
+	if _, err := ValidateToken(before, tr); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("token of a retired key: want unknown signing key, got %v", err)
+	}
+	if _, err := ValidateToken(after, tr); err != nil {
+		t.Fatalf("token of the active key rejected: %v", err)
+	}
+}
+
+func TestValidateTokenRejectsAlgorithmSwitch(t *testing.T) {
+	tr := newTokenRepo(t)
+	dir := t.TempDir()
+	m := &Manifest{}
+	addKey(t, dir, m, ES256, "signing", KeyActive)
+	addKey(t, dir, m, HS256, "secret", KeyVerify)
+	k := loadKeyring(t, dir)
+
+	// a token naming the ES256 key but claiming HS256 must not be checked
+	// with some other secret
+	secret, _ := k.Lookup("secret")
+	forged, err := sign(keyed{signingKey: secret, kid: "signing"}, Header{Type: "JWT"}, Claims{TokenID: "forged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(forged, tr); err == nil || !strings.Contains(err.Error(), "unexpected signing algorithm") {
+		t.Fatalf("want unexpected signing algorithm, got %v", err)
+	}
+}
+
+func TestHmacKeyIDIsNotDerivedFromSecretHash(t *testing.T) {
+	secret := []byte(strings.Repeat("s", 32))
+	a, err := NewSigner(HS256, "", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSigner(HS256, "", secret)
+	if a.KeyID() != b.KeyID() {
+		t.Fatalf("kid not stable: %s and %s", a.KeyID(), b.KeyID())
+	}
+
+	// the RFC 7638 thumbprint would be a plain hash of the secret
+	thumbprint := sha256.Sum256([]byte(`{"k":"` + b64(secret) + `","kty":"oct"}`))
+	if a.KeyID() == b64(thumbprint[:]) {
+		t.Fatal("HS256 kid is the thumbprint of the secret")
+	}
+
+	other, _ := NewSigner(HS256, "", []byte(strings.Repeat("t", 32)))
+	if other.KeyID() == a.KeyID() {
+		t.Fatal("different secrets share a kid")
+	}
+}
@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/config"
+)
+
+// Init sets up token signing from config. With JWT_KEY_DIR set, keys come from
+// the keyring in that directory and are reloaded as it changes. Otherwise a
+// single key is used: HS256 signs with JWT_SECRET, the asymmetric algorithms
+// load their private key from JWT_PRIVATE_KEY_FILE.
+func Init() error {
+	if dir := config.App.JwtKeyDir; dir != "" {
+		k, err := LoadKeyring(dir)
+		if err != nil {
+			return err
+		}
+
+		setKeyring(k)
+		if config.App.JwtKeyReloadInterval > 0 {
+			go watchKeyring(dir, time.Duration(config.App.JwtKeyReloadInterval)*time.Second)
+		}
+		return nil
+	}
+
+	alg := config.App.JwtAlgorithm
+
+	var key any = config.JwtSecretBytes
+	if alg != HS256 {
+		if config.App.JwtPrivateKeyFile == "" {
+			return errors.New("JWT_PRIVATE_KEY_FILE is required for " + alg)
+		}
+
+		var err error
+		key, err = LoadKeyFile(alg, config.App.JwtPrivateKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	s, err := NewSigner(alg, config.App.JwtKeyID, key)
+	if err != nil {
+		return err
+	}
+
+	setKeyring(NewKeyring(s))
+	return nil
+}
+
+// PublicKeys returns the JWKS other services use to verify our tokens: the
+// active key and every key still accepted for verification. HS256 keys are
+// never included.
+func PublicKeys() JWKS {
+	return currentKeyring().PublicKeys()
+}
+
+// LoadKeyFile reads the key for alg from path: a base64 secret for HS256, or a
+// PEM encoded PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) private key.
+func LoadKeyFile(alg, path string) (any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == HS256 {
+		secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, errors.New("invalid base64 secret in " + path)
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret in " + path + " must be at least 32 bytes when decoded")
+		}
+		return secret, nil
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found in " + path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+// GenerateKey creates a new key for alg and returns it together with the file
+// contents LoadKeyFile expects.
+func GenerateKey(alg string) (any, []byte, error) {
+	var key any
+	var err error
+	switch alg {
+	case HS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		return secret, []byte(base64.StdEncoding.EncodeToString(secret) + "\n"), nil
+	case RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, errors.New("unsupported jwt algorithm: " + alg)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
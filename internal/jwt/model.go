@@ -21,15 +21,26 @@ func (j Jwt) WithType(t model.JwtType) Jwt {
 type Header struct {
 	Algorithm string `json:"alg"`
 	Type      string `json:"typ"`
+	KeyID     string `json:"kid,omitempty"`
 }
 
 type Claims struct {
 	UserID     int64         `json:"sub"`
 	TokenID    string        `json:"jti"`
 	SessionID  int64         `json:"sid"`
+	FamilyID   int64         `json:"fid,omitempty"`
 	IssuedAt   int64         `json:"iat"`
 	Expiration int64         `json:"exp"`
 	Email      string        `json:"email"`
 	Role       string        `json:"role"`
 	Type       model.JwtType `json:"type"`
+
+	// Permissions granted by Role when the token was issued, so authorization
+	// checks need no database lookup.
+	Permissions []string `json:"perms,omitempty"`
+
+	// ClientID and Scope describe what an OAuth client was granted, on the
+	// tokens issued to one.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 }
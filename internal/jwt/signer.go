@@ -0,0 +1,270 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
+)
+
+// Signer signs and verifies tokens for a single key.
+type Signer interface {
+	signingKey
+	KeyID() string
+}
+
+type signingKey interface {
+	Algorithm() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, signature []byte) bool
+	// PublicJWK returns the verification key for publishing in the JWKS, or
+	// false for symmetric keys, which must never be published.
+	PublicJWK() (JWK, bool)
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewSigner builds a signer for alg. HS256 expects the raw secret bytes, the
+// asymmetric algorithms expect the matching crypto.Signer private key. An empty
+// kid is replaced by one derived from the key, see defaultKeyID.
+func NewSigner(alg, kid string, key any) (Signer, error) {
+	var s signingKey
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, errors.New("HS256 requires a secret")
+		}
+		s = &hmacSigner{secret: secret}
+	case RS256:
+		priv, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 requires an RSA private key")
+		}
+		if priv.N.BitLen() < 2048 {
+			return nil, errors.New("RS256 requires an RSA key of at least 2048 bits")
+		}
+		s = &rsaSigner{priv: priv}
+	case ES256:
+		priv, ok := key.(*ecdsa.PrivateKey)
+		if !ok || priv.Curve != elliptic.P256() {
+			return nil, errors.New("ES256 requires a P-256 ECDSA private key")
+		}
+		s = &ecdsaSigner{priv: priv}
+	case EdDSA:
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("EdDSA requires an Ed25519 private key")
+		}
+		s = &ed25519Signer{priv: priv}
+	default:
+		return nil, errors.New("unsupported jwt algorithm: " + alg)
+	}
+
+	if kid == "" {
+		kid = defaultKeyID(s)
+	}
+	return keyed{signingKey: s, kid: kid}, nil
+}
+
+// keyed attaches the kid to a signing key.
+type keyed struct {
+	signingKey
+	kid string
+}
+
+func (k keyed) KeyID() string { return k.kid }
+
+func (k keyed) PublicJWK() (JWK, bool) {
+	jwk, ok := k.signingKey.PublicJWK()
+	jwk.KeyID = k.kid
+	return jwk, ok
+}
+
+// defaultKeyID gives a stable kid for a key. Public keys use their RFC 7638
+// thumbprint. A thumbprint of a secret would be a plain hash of it sent in
+// every token header, so HS256 keys use an HMAC of a fixed label keyed with
+// the secret instead.
+func defaultKeyID(s signingKey) string {
+	if s, ok := s.(*hmacSigner); ok {
+		h := hmac.New(sha256.New, s.secret)
+		h.Write([]byte("gocode jwt key id"))
+		return b64(h.Sum(nil))
+	}
+
+	var members any
+	jwk, _ := s.PublicJWK()
+	switch jwk.KeyType {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	}
+
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return b64(sum[:])
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+type hmacSigner struct {
+	secret []byte
+}
+
+func (s *hmacSigner) Algorithm() string { return HS256 }
+
+func (s *hmacSigner) Sign(data []byte) ([]byte, error) {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+func (s *hmacSigner) Verify(data, signature []byte) bool {
+	expected, _ := s.Sign(data)
+	return hmac.Equal(signature, expected)
+}
+
+func (s *hmacSigner) PublicJWK() (JWK, bool) { return JWK{}, false }
+
+type rsaSigner struct {
+	priv *rsa.PrivateKey
+}
+
+func (s *rsaSigner) Algorithm() string { return RS256 }
+
+func (s *rsaSigner) Sign(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, s.priv, crypto.SHA256, sum[:])
+}
+
+func (s *rsaSigner) Verify(data, signature []byte) bool {
+	sum := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(&s.priv.PublicKey, crypto.SHA256, sum[:], signature) == nil
+}
+
+func (s *rsaSigner) PublicJWK() (JWK, bool) {
+	return JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: RS256,
+		N:         b64(s.priv.N.Bytes()),
+		E:         b64(big.NewInt(int64(s.priv.E)).Bytes()),
+	}, true
+}
+
+type ecdsaSigner struct {
+	priv *ecdsa.PrivateKey
+}
+
+func (s *ecdsaSigner) Algorithm() string { return ES256 }
+
+// Sign returns the fixed-size r||s signature JWS uses rather than ASN.1 DER.
+func (s *ecdsaSigner) Sign(data []byte) ([]byte, error) {
+	sum := sha256.Sum256(data)
+	r, ss, err := ecdsa.Sign(rand.Reader, s.priv, sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	ss.FillBytes(sig[32:])
+	return sig, nil
+}
+
+func (s *ecdsaSigner) Verify(data, signature []byte) bool {
+	if len(signature) != 64 {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(signature[:32])
+	ss := new(big.Int).SetBytes(signature[32:])
+	return ecdsa.Verify(&s.priv.PublicKey, sum[:], r, ss)
+}
+
+func (s *ecdsaSigner) PublicJWK() (JWK, bool) {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	s.priv.X.FillBytes(x)
+	s.priv.Y.FillBytes(y)
+	return JWK{
+		KeyType:   "EC",
+		Use:       "sig",
+		Algorithm: ES256,
+		Curve:     "P-256",
+		X:         b64(x),
+		Y:         b64(y),
+	}, true
+}
+
+type ed25519Signer struct {
+	priv ed25519.PrivateKey
+}
+
+func (s *ed25519Signer) Algorithm() string { return EdDSA }
+
+func (s *ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.priv, data), nil
+}
+
+func (s *ed25519Signer) Verify(data, signature []byte) bool {
+	return ed25519.Verify(s.priv.Public().(ed25519.PublicKey), data, signature)
+}
+
+func (s *ed25519Signer) PublicJWK() (JWK, bool) {
+	return JWK{
+		KeyType:   "OKP",
+		Use:       "sig",
+		Algorithm: EdDSA,
+		Curve:     "Ed25519",
+		X:         b64(s.priv.Public().(ed25519.PublicKey)),
+	}, true
+}
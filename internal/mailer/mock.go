@@ -1,9 +1,14 @@
 package mailer
 
-import "github.com/akramboussanni/gocode/internal/applog"
+import (
+	"sync"
+
+	"github.com/akramboussanni/gocode/internal/applog"
+)
 
 type MockMailer struct {
 	config     MailerConfig
+	mu         sync.Mutex // emails are often sent from goroutines
 	sentEmails []MockEmail
 }
 
@@ -36,23 +41,38 @@ func (m *MockMailer) Send(tmpl, from string, to []string, subject string, data a
 		Template: tmpl,
 		Data:     data,
 	}
+	m.mu.Lock()
 	m.sentEmails = append(m.sentEmails, mockEmail)
+	m.mu.Unlock()
 
 	applog.Info("MockMailer: sent email", "from", from, "to", to, "subject", subject, "template", tmpl, "data", data)
 	return nil
 }
 
 func (m *MockMailer) GetSentEmails() []MockEmail {
-	return m.sentEmails
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockEmail(nil), m.sentEmails...)
 }
 
 func (m *MockMailer) ClearSentEmails() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.sentEmails = make([]MockEmail, 0)
 }
 
 func (m *MockMailer) GetLastSentEmail() *MockEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if len(m.sentEmails) == 0 {
 		return nil
 	}
 	return &m.sentEmails[len(m.sentEmails)-1]
 }
+
+// Mock returns the configured mailer when it is the mock one, so tests can read
+// what was sent.
+func Mock() (*MockMailer, bool) {
+	m, ok := globalMailer.(*MockMailer)
+	return m, ok
+}
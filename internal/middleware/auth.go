@@ -2,10 +2,14 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/applog"
 	"github.com/akramboussanni/gocode/internal/jwt"
 	"github.com/akramboussanni/gocode/internal/model"
 	"github.com/akramboussanni/gocode/internal/repo"
@@ -13,16 +17,89 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-func AddAuth(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo) {
+func AddAuth(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo) {
 	r.Use(func(next http.Handler) http.Handler {
-		return JWTAuth(config.JwtSecretBytes, ur, tr, model.CredentialJwt)(next)
+		return JWTAuth(ur, tr, sr, model.CredentialJwt)(next)
 	})
 }
 
-func JWTAuth(secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo, expectedType model.JwtType) func(http.Handler) http.Handler {
+// AddAccessTokenAuth is AddAuth for routes called by OAuth clients with an
+// access token they were issued for a user.
+func AddAccessTokenAuth(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo) {
+	r.Use(JWTAuth(ur, tr, sr, model.OAuthAccessJwt))
+}
+
+// AddAuthOrApiKey is AddAuth for routes that may also be called with a personal
+// API key ("Authorization: Bearer gc_..."). The key must have been granted scope.
+func AddAuthOrApiKey(r chi.Router, ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo, kr *repo.ApiKeyRepo, scope string) {
+	r.Use(AuthOrApiKey(ur, tr, sr, kr, scope))
+}
+
+func AuthOrApiKey(ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo, kr *repo.ApiKeyRepo, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		jwtAuth := JWTAuth(ur, tr, sr, model.CredentialJwt)(next)
+		keyAuth := ApiKeyAuth(ur, kr, scope)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(utils.BearerToken(r), utils.ApiKeyPrefix) {
+				keyAuth.ServeHTTP(w, r)
+				return
+			}
+			jwtAuth.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ApiKeyAuth(ur *repo.UserRepo, kr *repo.ApiKeyRepo, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := utils.BearerToken(r)
+			if !strings.HasPrefix(raw, utils.ApiKeyPrefix) {
+				api.WriteInvalidCredentials(w)
+				return
+			}
+
+			key, err := kr.GetKeyByHash(r.Context(), utils.HashApiKey(raw))
+			if err != nil {
+				api.WriteInternalError(w)
+				return
+			}
+
+			if key == nil || (key.ExpiresAt != 0 && key.ExpiresAt < time.Now().UTC().Unix()) {
+				api.WriteInvalidCredentials(w)
+				return
+			}
+
+			if !key.HasScope(scope) {
+				api.WriteMessage(w, 403, "error", "api key is missing the "+scope+" scope")
+				return
+			}
+
+			user, err := ur.GetUserByID(r.Context(), key.UserID)
+			if err != nil {
+				api.WriteInternalError(w)
+				return
+			}
+
+			if !user.CanSignIn(time.Now().UTC().Unix()) {
+				api.WriteAccountUnavailable(w, user)
+				return
+			}
+
+			if err := kr.TouchKey(r.Context(), key.ID, utils.GetClientIP(r)); err != nil {
+				applog.Error("Failed to record api key usage:", err)
+			}
+
+			ctx := context.WithValue(r.Context(), utils.UserKey, user)
+			ctx = context.WithValue(ctx, utils.ApiKeyKey, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func JWTAuth(ur *repo.UserRepo, tr *repo.TokenRepo, sr *repo.SessionRepo, expectedType model.JwtType) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			claims := GetClaimsFromCookie(w, r, secret, ur, tr)
+			claims := GetClaimsFromRequest(w, r, tr)
 			if claims == nil {
 				return
 			}
@@ -33,6 +110,11 @@ func JWTAuth(secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo, expectedType
 			}
 
 			user, err := ur.GetUserByID(r.Context(), claims.UserID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// the account was deleted while the token was still valid
+				api.WriteInvalidCredentials(w)
+				return
+			}
 			if err != nil {
 				api.WriteInternalError(w)
 				return
@@ -43,24 +125,53 @@ func JWTAuth(secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo, expectedType
 				return
 			}
 
+			if !user.CanSignIn(time.Now().UTC().Unix()) {
+				api.WriteAccountUnavailable(w, user)
+				return
+			}
+
+			if claims.FamilyID != 0 {
+				revoked, err := sr.IsSessionRevoked(r.Context(), claims.FamilyID)
+				if err != nil {
+					api.WriteInternalError(w)
+					return
+				}
+
+				if revoked {
+					api.WriteInvalidCredentials(w)
+					return
+				}
+			}
+
 			ctx := context.WithValue(r.Context(), utils.UserKey, user)
+			ctx = context.WithValue(ctx, utils.SessionKey, claims.FamilyID)
+			// permissions in the token only hold while the user keeps the role
+			// they were issued for, so a demotion applies immediately
+			if claims.Role == user.Role {
+				ctx = context.WithValue(ctx, utils.PermsKey, claims.Permissions)
+			}
+			if claims.Scope != "" {
+				ctx = context.WithValue(ctx, utils.ScopeKey, claims.Scope)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func GetClaimsFromCookie(w http.ResponseWriter, r *http.Request, secret []byte, ur *repo.UserRepo, tr *repo.TokenRepo) *jwt.Claims {
-	sessionCookie, err := r.Cookie("session")
-	if err != nil {
+// GetClaimsFromRequest validates the credential token sent either as an
+// "Authorization: Bearer" header or as the session cookie.
+func GetClaimsFromRequest(w http.ResponseWriter, r *http.Request, tr *repo.TokenRepo) *jwt.Claims {
+	token := utils.TokenFromRequest(r, "session")
+	if token == "" {
 		api.WriteInvalidCredentials(w)
 		return nil
 	}
 
-	return GetClaims(w, r, sessionCookie.Value, secret, tr)
+	return GetClaims(w, r, token, tr)
 }
 
-func GetClaims(w http.ResponseWriter, r *http.Request, token string, secret []byte, tr *repo.TokenRepo) *jwt.Claims {
-	claims, err := jwt.ValidateToken(token, config.JwtSecretBytes, tr)
+func GetClaims(w http.ResponseWriter, r *http.Request, token string, tr *repo.TokenRepo) *jwt.Claims {
+	claims, err := jwt.ValidateToken(token, tr)
 	if err != nil {
 		api.WriteInvalidCredentials(w)
 		return nil
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/akramboussanni/gocode/internal/api"
+	"github.com/akramboussanni/gocode/internal/utils"
+)
+
+// RequirePermission only lets through requests whose session token grants
+// permission, e.g. r.Use(RequirePermission("users:read")). It must come after
+// AddAuth, which puts the token's permissions in the request context.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(utils.PermissionsFromContext(r.Context()), permission) {
+				api.WriteMessage(w, 403, "error", "missing permission "+permission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import "strings"
+
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeSessionsRead = "sessions:read"
+)
+
+// ApiKeyScopes lists every scope an API key can be granted.
+var ApiKeyScopes = []string{ScopeProfileRead, ScopeSessionsRead}
+
+// @Description Personal access token. The key itself is only returned once, when it is created
+type ApiKey struct {
+	ID         int64  `db:"id" safe:"true" json:"id" example:"123456789"`
+	UserID     int64  `db:"user_id" json:"-"`
+	Name       string `db:"name" safe:"true" json:"name" example:"deploy script"`
+	Prefix     string `db:"prefix" safe:"true" json:"prefix" example:"gc_Xk3vQ9aB"`
+	KeyHash    string `db:"key_hash" json:"-"`
+	Scopes     string `db:"scopes" safe:"true" json:"scopes" example:"profile:read sessions:read"`
+	CreatedAt  int64  `db:"created_at" safe:"true" json:"created_at" example:"1640995200"`
+	ExpiresAt  int64  `db:"expires_at" safe:"true" json:"expires_at" example:"0"`
+	LastUsedAt int64  `db:"last_used_at" safe:"true" json:"last_used_at" example:"1640995200"`
+	LastUsedIP string `db:"last_used_ip" safe:"true" json:"last_used_ip" example:"203.0.113.7"`
+}
+
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range strings.Fields(k.Scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,18 @@
+package model
+
+const (
+	ExportFormatJSON = "json"
+	ExportFormatZip  = "zip"
+)
+
+// DataExport is a personal data export generated in the background, kept
+// until it expires so the user can download it with the emailed token.
+type DataExport struct {
+	ID        int64  `db:"id"`
+	UserID    int64  `db:"user_id"`
+	TokenHash string `db:"token_hash"`
+	Format    string `db:"format"`
+	Content   string `db:"content"` // the export as JSON, zipped on download when requested
+	CreatedAt int64  `db:"created_at"`
+	ExpiresAt int64  `db:"expires_at"`
+}
@@ -0,0 +1,18 @@
+package model
+
+type EmailCodePurpose string
+
+const (
+	EmailCodeLogin   EmailCodePurpose = "login"
+	EmailCodeConfirm EmailCodePurpose = "confirm"
+)
+
+// EmailCode is a short numeric code sent by email, for users who cannot follow
+// links. A user has at most one per purpose; sending a new one replaces it.
+type EmailCode struct {
+	UserID    int64            `db:"user_id"`
+	Purpose   EmailCodePurpose `db:"purpose"`
+	CodeHash  string           `db:"code_hash"`
+	Attempts  int              `db:"attempts"` // wrong codes entered so far
+	ExpiresAt int64            `db:"expires_at"`
+}
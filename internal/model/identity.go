@@ -0,0 +1,24 @@
+package model
+
+// @Description Account at an external OpenID Connect provider linked to a user
+type Identity struct {
+	ID       int64  `db:"id" json:"id" example:"123456789"`
+	UserID   int64  `db:"user_id" json:"-"`
+	Provider string `db:"provider" json:"provider" example:"google"`
+	Subject  string `db:"subject" json:"-"`
+	// Email is the address the provider reported when the identity was linked.
+	Email    string `db:"email" json:"email" example:"user@example.com"`
+	LinkedAt int64  `db:"linked_at" json:"linked_at" example:"1640995200"`
+}
+
+// OidcState is a pending login or link at an external provider, tied to the browser
+// that started it by a cookie holding the token hashed into ID.
+type OidcState struct {
+	ID           string `db:"id"`
+	UserID       int64  `db:"user_id"` // user linking the provider, 0 for a login
+	Provider     string `db:"provider"`
+	State        string `db:"state"`
+	Nonce        string `db:"nonce"`
+	CodeVerifier string `db:"code_verifier"`
+	ExpiresAt    int64  `db:"expires_at"`
+}
@@ -5,4 +5,13 @@ type JwtType string
 const (
 	CredentialJwt JwtType = "credential"
 	RefreshJwt    JwtType = "refresh"
+	MfaPendingJwt JwtType = "mfa"
+
+	// OAuthAccessJwt is an access token issued to an OAuth client on behalf of
+	// a user, OAuthRefreshJwt renews it. Neither is accepted as a session token.
+	OAuthAccessJwt  JwtType = "access"
+	OAuthRefreshJwt JwtType = "oauth_refresh"
+	// OAuthClientJwt is an access token a client obtained for itself with the
+	// client_credentials grant. It belongs to no user.
+	OAuthClientJwt JwtType = "client"
 )
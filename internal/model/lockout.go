@@ -1,18 +1,20 @@
 package model
 
+// @Description Failed login attempt
 type FailedLogin struct {
-	ID          int64  `db:"id"`
-	UserID      int64  `db:"user_id"`
-	IPAddress   string `db:"ip_address"`
-	AttemptedAt int64  `db:"attempted_at"`
-	Active      bool   `db:"active"`
+	ID          int64  `db:"id" json:"id" example:"123456789"`
+	UserID      int64  `db:"user_id" json:"-"`
+	IPAddress   string `db:"ip_address" json:"ip_address" example:"203.0.113.7"`
+	AttemptedAt int64  `db:"attempted_at" json:"attempted_at" example:"1640995200"`
+	Active      bool   `db:"active" json:"active" example:"true" description:"Whether the attempt still counts towards a lockout"`
 }
 
+// @Description Login lockout after too many failed attempts from one address
 type Lockout struct {
-	ID          int64  `db:"id"`
-	UserID      int64  `db:"user_id"`
-	IPAddress   string `db:"ip_address"`
-	LockedUntil int64  `db:"locked_until"`
-	Reason      string `db:"reason"`
-	Active      bool   `db:"active"`
+	ID          int64  `db:"id" json:"id" example:"123456789"`
+	UserID      int64  `db:"user_id" json:"-"`
+	IPAddress   string `db:"ip_address" json:"ip_address" example:"203.0.113.7"`
+	LockedUntil int64  `db:"locked_until" json:"locked_until" example:"1640995200"`
+	Reason      string `db:"reason" json:"reason" example:"too many failed login attempts"`
+	Active      bool   `db:"active" json:"active" example:"true"`
 }
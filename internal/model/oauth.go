@@ -0,0 +1,120 @@
+package model
+
+import "strings"
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuthScopes lists every scope an OAuth client can be allowed to request.
+var OAuthScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// OAuthGrantTypes lists every grant an OAuth client can be allowed to use.
+var OAuthGrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantDeviceCode}
+
+// @Description Application registered to sign users in through this server. The secret is only returned once, when it is created
+type OAuthClient struct {
+	ID         string `db:"id" json:"client_id" example:"Xk3vQ9aBc1dE2fG3"`
+	Name       string `db:"name" json:"name" example:"Wiki"`
+	SecretHash string `db:"secret_hash" json:"-"` // empty for public clients
+	// RedirectURIs, Scopes and GrantTypes are space separated lists.
+	RedirectURIs string `db:"redirect_uris" json:"redirect_uris" example:"https://wiki.example.com/callback"`
+	Scopes       string `db:"scopes" json:"scopes" example:"openid profile email"`
+	GrantTypes   string `db:"grant_types" json:"grant_types" example:"authorization_code refresh_token"`
+	CreatedBy    int64  `db:"created_by" json:"created_by" example:"123456789"`
+	CreatedAt    int64  `db:"created_at" json:"created_at" example:"1640995200"`
+}
+
+// IsPublic reports whether the client has no secret, as for single page and
+// native apps, which cannot keep one.
+func (c *OAuthClient) IsPublic() bool {
+	return c.SecretHash == ""
+}
+
+// HasRedirectURI reports whether uri is registered for the client. Only exact
+// matches count.
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	return hasField(c.RedirectURIs, uri)
+}
+
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	return hasField(c.Scopes, scope)
+}
+
+func (c *OAuthClient) AllowsGrant(grant string) bool {
+	return hasField(c.GrantTypes, grant)
+}
+
+func hasField(list, s string) bool {
+	for _, f := range strings.Fields(list) {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthCode is an authorization code handed to a client through its redirect
+// URI, waiting to be exchanged for tokens. Codes are single-use.
+type OAuthCode struct {
+	CodeHash      string `db:"code_hash"`
+	ClientID      string `db:"client_id"`
+	UserID        int64  `db:"user_id"`
+	RedirectURI   string `db:"redirect_uri"`
+	Scope         string `db:"scope"`
+	Nonce         string `db:"nonce"`
+	CodeChallenge string `db:"code_challenge"` // S256 PKCE challenge
+	ExpiresAt     int64  `db:"expires_at"`
+}
+
+// OAuthConsent records the scopes a user allowed a client, so the consent
+// page can tell when it has nothing new to ask.
+type OAuthConsent struct {
+	UserID    int64  `db:"user_id"`
+	ClientID  string `db:"client_id"`
+	Scope     string `db:"scope"`
+	GrantedAt int64  `db:"granted_at"`
+}
+
+// Covers reports whether every scope in the space separated list was already
+// granted.
+func (c *OAuthConsent) Covers(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !hasField(c.Scope, s) {
+			return false
+		}
+	}
+	return true
+}
+
+type DeviceCodeStatus string
+
+const (
+	DeviceCodePending  DeviceCodeStatus = "pending"
+	DeviceCodeApproved DeviceCodeStatus = "approved"
+	DeviceCodeDenied   DeviceCodeStatus = "denied"
+)
+
+// OAuthDeviceCode is a device authorization request (RFC 8628): the device
+// polls with the device code while the user approves the user code in a
+// browser. UserID is set once the user answers.
+type OAuthDeviceCode struct {
+	DeviceCodeHash string           `db:"device_code_hash"`
+	UserCodeHash   string           `db:"user_code_hash"`
+	ClientID       string           `db:"client_id"`
+	Scope          string           `db:"scope"`
+	UserID         int64            `db:"user_id"`
+	Status         DeviceCodeStatus `db:"status"`
+	PollInterval   int64            `db:"poll_interval"` // sec the device must wait between polls
+	LastPolledAt   int64            `db:"last_polled_at"`
+	ExpiresAt      int64            `db:"expires_at"`
+}
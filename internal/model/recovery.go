@@ -0,0 +1,10 @@
+package model
+
+type RecoveryCode struct {
+	ID        int64  `db:"id"`
+	UserID    int64  `db:"user_id"`
+	CodeHash  string `db:"code_hash"`
+	CreatedAt int64  `db:"created_at"`
+	UsedAt    int64  `db:"used_at"`
+	UsedIP    string `db:"used_ip"`
+}
@@ -0,0 +1,15 @@
+package model
+
+// RefreshToken tracks an issued refresh token within its rotation family. Every
+// login starts a new family; each refresh adds a child and marks the parent as
+// rotated.
+type RefreshToken struct {
+	TokenID   string `db:"jti"`
+	FamilyID  int64  `db:"family_id"`
+	ParentID  string `db:"parent_jti"`
+	UserID    int64  `db:"user_id"`
+	IssuedAt  int64  `db:"issued_at"`
+	ExpiresAt int64  `db:"expires_at"`
+	RotatedAt int64  `db:"rotated_at"`
+	Revoked   bool   `db:"revoked"`
+}
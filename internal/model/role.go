@@ -1,6 +1,23 @@
 package model
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	PermUsersRead  = "users:read"
+	PermUsersWrite = "users:write"
+	PermRolesRead  = "roles:read"
+	PermRolesWrite = "roles:write"
+
+	PermClientsRead  = "clients:read"
+	PermClientsWrite = "clients:write"
+)
+
+// @Description Role that can be assigned to users
 type Role struct {
-	ID   int64
-	Name string
+	Name        string `db:"name" json:"name" example:"admin"`
+	Description string `db:"description" json:"description" example:"Full access to user and role management"`
+	CreatedAt   int64  `db:"created_at" json:"created_at" example:"1640995200"`
 }
@@ -0,0 +1,36 @@
+package model
+
+type SecurityEventType string
+
+const (
+	RefreshTokenReuseEvent   SecurityEventType = "refresh_token_reuse"
+	RoleChangedEvent         SecurityEventType = "role_changed"
+	EmailConfirmedEvent      SecurityEventType = "email_confirmed"
+	PasswordResetSentEvent   SecurityEventType = "password_reset_sent"
+	AccountSuspendedEvent    SecurityEventType = "account_suspended"
+	AccountReactivatedEvent  SecurityEventType = "account_reactivated"
+	LockoutClearedEvent      SecurityEventType = "lockout_cleared"
+	SessionsRevokedEvent     SecurityEventType = "sessions_revoked"
+	EmailChangedEvent        SecurityEventType = "email_changed"
+	UsernameChangedEvent     SecurityEventType = "username_changed"
+	IdentityLinkedEvent      SecurityEventType = "identity_linked"
+	IdentityUnlinkedEvent    SecurityEventType = "identity_unlinked"
+	PasswordSetEvent         SecurityEventType = "password_set"
+	OAuthConsentEvent        SecurityEventType = "oauth_consent"
+	OAuthClientCreatedEvent  SecurityEventType = "oauth_client_created"
+	OAuthClientDeletedEvent  SecurityEventType = "oauth_client_deleted"
+	OAuthDeviceApprovedEvent SecurityEventType = "oauth_device_approved"
+)
+
+// @Description Security relevant event on a user account
+type SecurityEvent struct {
+	ID        int64             `db:"id" json:"id" example:"123456789"`
+	UserID    int64             `db:"user_id" json:"user_id" example:"123456789"`
+	Type      SecurityEventType `db:"event_type" json:"event_type" example:"account_suspended"`
+	IPAddress string            `db:"ip_address" json:"ip_address" example:"203.0.113.7"`
+	Details   string            `db:"details" json:"details" example:"spam"`
+	CreatedAt int64             `db:"created_at" json:"created_at" example:"1640995200"`
+	// ActorID is the admin who performed the action, or 0 when the event
+	// was triggered by the user or the system.
+	ActorID int64 `db:"actor_id" json:"actor_id" example:"0"`
+}
@@ -0,0 +1,16 @@
+package model
+
+// @Description Signed-in device session. Its ID is the refresh token family started at login.
+type Session struct {
+	ID         int64  `db:"id" safe:"true" json:"id" example:"123456789"`
+	UserID     int64  `db:"user_id" json:"-"`
+	UserAgent  string `db:"user_agent" safe:"true" json:"user_agent" example:"Mozilla/5.0 (X11; Linux x86_64)"`
+	IPAddress  string `db:"ip_address" safe:"true" json:"ip_address" example:"203.0.113.7"`
+	CreatedAt  int64  `db:"created_at" safe:"true" json:"created_at" example:"1640995200"`
+	LastSeenAt int64  `db:"last_seen_at" safe:"true" json:"last_seen_at" example:"1640995200"`
+	ExpiresAt  int64  `db:"expires_at" safe:"true" json:"expires_at" example:"1641124800"`
+	Revoked    bool   `db:"revoked" json:"revoked,omitempty" example:"false"`
+	// ClientID is the OAuth client the session was authorized for, empty for
+	// a sign-in to this server.
+	ClientID string `db:"client_id" safe:"true" json:"client_id,omitempty" example:"Xk3vQ9aBc1dE2fG3"`
+}
@@ -1,17 +1,103 @@
 package model
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
 // @Description User model with profile information
 type User struct {
-	ID                    int64  `db:"id" safe:"true" json:"id" example:"123456789"`
-	Username              string `db:"username" safe:"true" json:"username" example:"johndoe"`
-	Email                 string `db:"email" safe:"true" json:"email" example:"john@example.com"`
-	PasswordHash          string `db:"password_hash" json:"-"`
-	CreatedAt             int64  `db:"created_at" safe:"true" json:"created_at" example:"1640995200"`
-	Role                  string `db:"user_role" safe:"true" json:"role" example:"user"`
-	EmailConfirmed        bool   `db:"email_confirmed" json:"-"`
-	EmailConfirmToken     string `db:"email_confirm_token" json:"-"`
-	EmailConfirmIssuedAt  int64  `db:"email_confirm_issuedat" json:"-"`
-	PasswordResetToken    string `db:"password_reset_token" json:"-"`
-	PasswordResetIssuedAt int64  `db:"password_reset_issuedat" json:"-"`
-	JwtSessionID          int64  `db:"jwt_session_id" json:"-"`
+	ID                    int64         `db:"id" safe:"true" json:"id" example:"123456789"`
+	Username              string        `db:"username" safe:"true" json:"username" example:"johndoe"`
+	Email                 string        `db:"email" safe:"true" json:"email" example:"john@example.com"`
+	PasswordHash          string        `db:"password_hash" json:"-"`
+	CreatedAt             int64         `db:"created_at" safe:"true" json:"created_at" example:"1640995200"`
+	Role                  string        `db:"user_role" safe:"true" json:"role" example:"user"`
+	EmailConfirmed        bool          `db:"email_confirmed" json:"-"`
+	EmailConfirmToken     string        `db:"email_confirm_token" json:"-"`
+	EmailConfirmIssuedAt  int64         `db:"email_confirm_issuedat" json:"-"`
+	PasswordResetToken    string        `db:"password_reset_token" json:"-"`
+	PasswordResetIssuedAt int64         `db:"password_reset_issuedat" json:"-"`
+	JwtSessionID          int64         `db:"jwt_session_id" json:"-"`
+	TotpSecret            string        `db:"totp_secret" json:"-"`
+	TotpPendingSecret     string        `db:"totp_pending_secret" json:"-"`
+	TotpEnabled           bool          `db:"totp_enabled" json:"-"`
+	TotpLastStep          int64         `db:"totp_last_step" json:"-"`
+	Status                AccountStatus `db:"account_status" json:"-"`
+	StatusReason          string        `db:"status_reason" json:"-"`
+	StatusUntil           int64         `db:"status_until" json:"-"`
+	DeletionCancelToken   string        `db:"deletion_cancel_token" json:"-"`
+	PendingEmail          string        `db:"pending_email" json:"-"`
+	EmailChangeToken      string        `db:"email_change_token" json:"-"`
+	EmailCancelToken      string        `db:"email_change_cancel_token" json:"-"`
+	EmailChangeIssuedAt   int64         `db:"email_change_issuedat" json:"-"`
+	DisplayName           string        `db:"display_name" safe:"true" json:"display_name" example:"John Doe"`
+	Locale                string        `db:"locale" safe:"true" json:"locale" example:"en-US"`
+	Timezone              string        `db:"timezone" safe:"true" json:"timezone" example:"Europe/Paris"`
+	AvatarURL             string        `db:"avatar_url" safe:"true" json:"avatar_url" example:"https://example.com/avatar.png"`
+	Metadata              Metadata      `db:"metadata" safe:"true" json:"metadata" swaggertype:"object"`
+	UsernameChangedAt     int64         `db:"username_changed_at" json:"-"`
+	EmailNormalized       string        `db:"email_normalized" json:"-"`
+	UsernameNormalized    string        `db:"username_normalized" json:"-"`
+	MagicLinkToken        string        `db:"magic_link_token" json:"-"`
+	MagicLinkIssuedAt     int64         `db:"magic_link_issuedat" json:"-"`
+}
+
+// Metadata is free-form data the client keeps on a user, stored as a JSON
+// object.
+type Metadata map[string]any
+
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *Metadata) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Metadata{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	case []byte:
+		return json.Unmarshal(v, m)
+	default:
+		return fmt.Errorf("cannot scan %T into Metadata", src)
+	}
+}
+
+type AccountStatus string
+
+const (
+	StatusActive    AccountStatus = "active"
+	StatusSuspended AccountStatus = "suspended"
+	// StatusPendingDeletion accounts are deleted for good once StatusUntil
+	// passes, unless the user cancels through the emailed link first.
+	StatusPendingDeletion AccountStatus = "pending_deletion"
+)
+
+// IsSuspended reports whether the account is suspended at now. Suspensions
+// with an end time lapse on their own once it has passed.
+func (u *User) IsSuspended(now int64) bool {
+	return u.Status == StatusSuspended && (u.StatusUntil == 0 || u.StatusUntil > now)
+}
+
+func (u *User) IsPendingDeletion() bool {
+	return u.Status == StatusPendingDeletion
+}
+
+// CanSignIn reports whether the account may log in or use its existing
+// credentials at now.
+func (u *User) CanSignIn(now int64) bool {
+	return !u.IsSuspended(now) && !u.IsPendingDeletion()
+}
+
+// HasPassword reports whether the user can sign in with a password. Accounts
+// created through an external provider have none until the user sets one.
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
 }
@@ -0,0 +1,29 @@
+package model
+
+type WebAuthnCeremony string
+
+const (
+	RegistrationCeremony WebAuthnCeremony = "registration"
+	LoginCeremony        WebAuthnCeremony = "login"
+)
+
+// @Description Registered passkey (WebAuthn credential)
+type WebAuthnCredential struct {
+	ID           int64  `db:"id" safe:"true" json:"id" example:"123456789"`
+	UserID       int64  `db:"user_id" json:"-"`
+	Name         string `db:"name" safe:"true" json:"name" example:"MacBook Touch ID"`
+	CredentialID string `db:"credential_id" json:"-"`
+	Credential   string `db:"credential" json:"-"`
+	SignCount    int64  `db:"sign_count" json:"-"`
+	CreatedAt    int64  `db:"created_at" safe:"true" json:"created_at" example:"1640995200"`
+	LastUsedAt   int64  `db:"last_used_at" safe:"true" json:"last_used_at" example:"1640995200"`
+}
+
+type WebAuthnSession struct {
+	ID          string           `db:"id"`
+	UserID      int64            `db:"user_id"`
+	Ceremony    WebAuthnCeremony `db:"ceremony"`
+	Name        string           `db:"name"`
+	SessionData string           `db:"session_data"`
+	ExpiresAt   int64            `db:"expires_at"`
+}
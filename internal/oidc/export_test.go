@@ -0,0 +1,13 @@
+package oidc
+
+import "time"
+
+// ExpireKeys makes the cached keys old enough for a token with an unknown key
+// id to refetch them.
+func (p *Provider) ExpireKeys() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil {
+		p.keys.fetchedAt = time.Time{}
+	}
+}
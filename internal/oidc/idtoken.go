@@ -0,0 +1,214 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/jwt"
+)
+
+// Claims are the ID token claims used to find or create the account.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiration        int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// audience accepts both forms of the aud claim: a string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// UnmarshalJSON also accepts email_verified sent as a string, as some
+// providers do.
+func (c *Claims) UnmarshalJSON(b []byte) error {
+	type plain Claims
+	aux := struct {
+		*plain
+		EmailVerified any `json:"email_verified"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	c.EmailVerified = aux.EmailVerified == true || aux.EmailVerified == "true"
+	return nil
+}
+
+// clockSkew is how far the provider's clock may be off from ours.
+const clockSkew = 60
+
+// keyRefreshInterval limits how often a token with an unknown key id can make
+// us refetch the provider's keys.
+const keyRefreshInterval = time.Minute
+
+type keySet struct {
+	keys      []jwt.JWK
+	fetchedAt time.Time
+}
+
+// verifyIDToken checks the token's signature against the provider's keys and
+// validates its claims for our client and the login's nonce. Only asymmetric
+// algorithms are accepted.
+func (p *Provider) verifyIDToken(ctx context.Context, token, nonce string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid id token format")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("invalid id token header encoding")
+	}
+
+	var header jwt.Header
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, errors.New("invalid id token header json")
+	}
+
+	switch header.Algorithm {
+	case jwt.RS256, jwt.ES256, jwt.EdDSA:
+	default:
+		return nil, errors.New("unsupported id token algorithm " + header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid id token signature encoding")
+	}
+
+	key, err := p.keyFor(ctx, header.KeyID, header.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jwt.VerifySignature(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, errors.New("invalid id token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid id token payload encoding")
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.New("invalid id token payload json")
+	}
+
+	if claims.Issuer != p.Issuer {
+		return nil, errors.New("id token issued by " + claims.Issuer)
+	}
+	if !contains(claims.Audience, p.ClientID) {
+		return nil, errors.New("id token not issued for this client")
+	}
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.ClientID {
+		return nil, errors.New("id token authorized for another party")
+	}
+
+	now := time.Now().UTC().Unix()
+	if claims.Expiration == 0 || now > claims.Expiration+clockSkew {
+		return nil, errors.New("id token expired")
+	}
+	if claims.IssuedAt > now+clockSkew {
+		return nil, errors.New("id token issued in the future")
+	}
+
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	if claims.Subject == "" || len(claims.Subject) > 255 {
+		return nil, errors.New("invalid id token subject")
+	}
+
+	return &claims, nil
+}
+
+// keyFor finds the provider key for kid and alg, refetching the provider's
+// keys when it is unknown, since providers rotate them.
+func (p *Provider) keyFor(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if key := p.keys.find(kid, alg); key != nil {
+			return key, nil
+		}
+		if time.Since(p.keys.fetchedAt) < keyRefreshInterval {
+			return nil, errors.New("unknown id token signing key " + kid)
+		}
+	}
+
+	var set jwt.JWKS
+	if err := getJSON(ctx, d.JwksURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = &keySet{keys: set.Keys, fetchedAt: time.Now()}
+
+	if key := p.keys.find(kid, alg); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("unknown id token signing key " + kid)
+}
+
+// find returns the first usable signing key matching kid, or any key for alg
+// when the token names none.
+func (s *keySet) find(kid, alg string) crypto.PublicKey {
+	for _, k := range s.keys {
+		if kid != "" && k.KeyID != kid {
+			continue
+		}
+		if (k.Use != "" && k.Use != "sig") || (k.Algorithm != "" && k.Algorithm != alg) {
+			continue
+		}
+		if key, err := k.PublicKey(); err == nil {
+			return key
+		}
+	}
+	return nil
+}
+
+// NewVerifier returns a random PKCE code verifier (RFC 7636). It is also
+// suitable for the state and nonce.
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge derives the S256 code challenge sent with the authorization
+// request from the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
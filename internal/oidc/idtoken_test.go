@@ -0,0 +1,155 @@
+package oidc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/oidc"
+	"github.com/akramboussanni/gocode/internal/oidc/oidctest"
+)
+
+func newProvider(t *testing.T) (*oidctest.Issuer, *oidc.Provider) {
+	t.Helper()
+
+	issuer := oidctest.NewIssuer(t)
+	issuer.Register("test")
+	t.Cleanup(func() { oidc.Init("") })
+
+	p, ok := oidc.Get("test")
+	if !ok {
+		t.Fatal("provider not registered")
+	}
+	return issuer, p
+}
+
+// login runs a login through the provider, with the issuer signing claims.
+func login(t *testing.T, issuer *oidctest.Issuer, p *oidc.Provider, claims map[string]any) (*oidc.Claims, error) {
+	t.Helper()
+
+	verifier, _ := oidc.NewVerifier()
+	nonce, _ := oidc.NewVerifier()
+	authURL, err := p.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := issuer.Authorize(authURL, claims)
+	return p.Exchange(context.Background(), code, verifier, nonce)
+}
+
+func TestExchange(t *testing.T) {
+	issuer, p := newProvider(t)
+
+	claims, err := login(t, issuer, p, issuer.Claims("alice@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "alice@example.com" || !claims.EmailVerified || claims.Subject == "" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejectsClaims(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name   string
+		change map[string]any
+		err    string
+	}{
+		{"nonce mismatch", map[string]any{"nonce": "another-login"}, "nonce mismatch"},
+		{"missing nonce", map[string]any{"nonce": ""}, "nonce mismatch"},
+		{"other audience", map[string]any{"aud": "another-client"}, "not issued for this client"},
+		{"shared audience without azp", map[string]any{"aud": []string{oidctest.ClientID, "another-client"}}, "another party"},
+		{"authorized for another party", map[string]any{"azp": "another-client"}, "another party"},
+		{"other issuer", map[string]any{"iss": "https://evil.example.com"}, "issued by"},
+		{"expired", map[string]any{"exp": now - 120}, "expired"},
+		{"no expiry", map[string]any{"exp": 0}, "expired"},
+		{"issued in the future", map[string]any{"iat": now + 600}, "future"},
+		{"no subject", map[string]any{"sub": ""}, "subject"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer, p := newProvider(t)
+
+			claims := issuer.Claims("alice@example.com")
+			for k, v := range tt.change {
+				claims[k] = v
+			}
+
+			_, err := login(t, issuer, p, claims)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("want error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestExchangeAcceptsAuthorizedParty(t *testing.T) {
+	issuer, p := newProvider(t)
+
+	claims := issuer.Claims("alice@example.com")
+	claims["aud"] = []string{oidctest.ClientID, "another-client"}
+	claims["azp"] = oidctest.ClientID
+
+	if _, err := login(t, issuer, p, claims); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExchangeRefetchesUnknownKey(t *testing.T) {
+	issuer, p := newProvider(t)
+
+	if _, err := login(t, issuer, p, issuer.Claims("alice@example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if n := issuer.KeyFetches(); n != 1 {
+		t.Fatalf("want 1 key fetch, got %d", n)
+	}
+
+	// a key rotated in right after our fetch is not looked up again at once
+	issuer.RotateKey(true)
+	if _, err := login(t, issuer, p, issuer.Claims("alice@example.com")); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("want unknown key error, got %v", err)
+	}
+	if n := issuer.KeyFetches(); n != 1 {
+		t.Fatalf("refetched keys within the refresh interval: %d fetches", n)
+	}
+
+	p.ExpireKeys()
+	if _, err := login(t, issuer, p, issuer.Claims("alice@example.com")); err != nil {
+		t.Fatalf("rotated key not picked up: %v", err)
+	}
+	if n := issuer.KeyFetches(); n != 2 {
+		t.Fatalf("want 2 key fetches, got %d", n)
+	}
+
+	// a key the provider does not publish stays unknown after the refetch
+	issuer.RotateKey(false)
+	p.ExpireKeys()
+	if _, err := login(t, issuer, p, issuer.Claims("alice@example.com")); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("want unknown key error, got %v", err)
+	}
+	if n := issuer.KeyFetches(); n != 3 {
+		t.Fatalf("want 3 key fetches, got %d", n)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	issuer, p := newProvider(t)
+
+	verifier, _ := oidc.NewVerifier()
+	nonce, _ := oidc.NewVerifier()
+	authURL, err := p.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := issuer.Authorize(authURL, issuer.Claims("alice@example.com"))
+	other, _ := oidc.NewVerifier()
+	if _, err := p.Exchange(context.Background(), code, other, nonce); err == nil {
+		t.Fatal("code redeemed with another verifier")
+	}
+}
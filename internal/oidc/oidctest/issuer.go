@@ -0,0 +1,226 @@
+// Package oidctest runs a stand-in OpenID provider for tests: discovery, keys
+// and a token endpoint redeeming codes that the test approves itself.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/jwt"
+	"github.com/akramboussanni/gocode/internal/oidc"
+)
+
+const ClientID = "test-client"
+
+// Issuer is a provider served over loopback http, which the oidc package
+// accepts in place of https.
+type Issuer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu          sync.Mutex
+	signer      jwt.Signer
+	published   []jwt.Signer
+	keyFetches  int
+	pending     map[string]grant
+	nextKeyID   int
+	nextSubject int
+}
+
+type grant struct {
+	challenge string
+	token     string
+}
+
+// NewIssuer starts an issuer with one signing key. It stops with the test.
+func NewIssuer(t *testing.T) *Issuer {
+	t.Helper()
+
+	i := &Issuer{t: t, pending: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", i.handleDiscovery)
+	mux.HandleFunc("GET /jwks", i.handleKeys)
+	mux.HandleFunc("POST /token", i.handleToken)
+	i.Server = httptest.NewServer(mux)
+	t.Cleanup(i.Close)
+
+	i.RotateKey(true)
+	return i
+}
+
+// Config returns the provider configuration for this issuer under name.
+func (i *Issuer) Config(name string) oidc.ProviderConfig {
+	return oidc.ProviderConfig{
+		Name:         name,
+		Issuer:       i.URL,
+		ClientID:     ClientID,
+		ClientSecret: "test-secret",
+		RedirectURI:  "https://app.example.com/oidc/callback",
+	}
+}
+
+// Register configures this issuer as the only provider, named name.
+func (i *Issuer) Register(name string) {
+	i.t.Helper()
+
+	raw, _ := json.Marshal([]oidc.ProviderConfig{i.Config(name)})
+	if err := oidc.Init(string(raw)); err != nil {
+		i.t.Fatal(err)
+	}
+}
+
+// RotateKey signs from now on with a new key, which is published right away
+// unless publish is false.
+func (i *Issuer) RotateKey(publish bool) {
+	i.t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.nextKeyID++
+	signer, err := jwt.NewSigner(jwt.ES256, "key-"+strconv.Itoa(i.nextKeyID), key)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	i.signer = signer
+	if publish {
+		i.published = append(i.published, signer)
+	}
+}
+
+// KeyFetches counts requests for the key set.
+func (i *Issuer) KeyFetches() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.keyFetches
+}
+
+// Claims returns the ID token claims a provider sends for a fresh account
+// with a verified email.
+func (i *Issuer) Claims(email string) map[string]any {
+	i.mu.Lock()
+	i.nextSubject++
+	subject := "subject-" + strconv.Itoa(i.nextSubject)
+	i.mu.Unlock()
+
+	now := time.Now().Unix()
+	return map[string]any{
+		"iss":            i.URL,
+		"sub":            subject,
+		"aud":            ClientID,
+		"iat":            now,
+		"exp":            now + 300,
+		"email":          email,
+		"email_verified": true,
+	}
+}
+
+// Authorize plays the user approving the login at authURL, as returned by
+// the begin step. The ID token carries claims plus the request's nonce,
+// unless claims sets one. It returns the code and state the provider
+// redirects back with.
+func (i *Issuer) Authorize(authURL string, claims map[string]any) (code, state string) {
+	i.t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != ClientID || q.Get("code_challenge_method") != "S256" {
+		i.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = q.Get("nonce")
+	}
+
+	code = i.randomString()
+	i.mu.Lock()
+	i.pending[code] = grant{challenge: q.Get("code_challenge"), token: i.sign(claims)}
+	i.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (i *Issuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(jwt.Header{Algorithm: i.signer.Algorithm(), Type: "JWT", KeyID: i.signer.KeyID()})
+	payload, _ := json.Marshal(claims)
+
+	data := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := i.signer.Sign([]byte(data))
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return data + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (i *Issuer) randomString() string {
+	v, err := oidc.NewVerifier()
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return v
+}
+
+func (i *Issuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) handleKeys(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.keyFetches++
+	set := jwt.JWKS{Keys: []jwt.JWK{}}
+	for _, s := range i.published {
+		jwk, _ := s.PublicJWK()
+		set.Keys = append(set.Keys, jwk)
+	}
+	writeJSON(w, 200, set)
+}
+
+func (i *Issuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if id, secret, ok := r.BasicAuth(); !ok || id != ClientID || secret != "test-secret" {
+		writeJSON(w, 401, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	i.mu.Lock()
+	g, ok := i.pending[r.PostForm.Get("code")]
+	delete(i.pending, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	if !ok || oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		writeJSON(w, 400, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, 200, map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": g.token})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
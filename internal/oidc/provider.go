@@ -0,0 +1,272 @@
+// Package oidc signs users in through external OpenID Connect providers, using
+// the authorization code flow with PKCE and verifying the ID token against the
+// provider's published keys.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderConfig is one entry of OIDC_PROVIDERS.
+type ProviderConfig struct {
+	// Name identifies the provider in URLs and linked identities, so it must
+	// not change once users have signed in with it.
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"` // empty for public clients
+	RedirectURI  string   `json:"redirect_uri"`  // frontend page receiving the code and state
+	Scopes       []string `json:"scopes"`        // defaults to openid, email and profile
+}
+
+// Provider is a configured OpenID provider. Its discovery document and keys
+// are fetched on first use and cached.
+type Provider struct {
+	ProviderConfig
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      *keySet
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+var (
+	providers []*Provider
+	byName    = map[string]*Provider{}
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// maxResponseSize caps what is read from a provider.
+const maxResponseSize = 1 << 20
+
+// Init loads the providers from the JSON array in OIDC_PROVIDERS. An empty
+// value configures none.
+func Init(raw string) error {
+	providers = nil
+	byName = map[string]*Provider{}
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	var configs []ProviderConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return errors.New("invalid OIDC_PROVIDERS: " + err.Error())
+	}
+
+	for _, c := range configs {
+		if err := validate(&c); err != nil {
+			return errors.New("OIDC provider " + c.Name + ": " + err.Error())
+		}
+		if byName[c.Name] != nil {
+			return errors.New("duplicate OIDC provider " + c.Name)
+		}
+
+		p := &Provider{ProviderConfig: c}
+		providers = append(providers, p)
+		byName[c.Name] = p
+	}
+	return nil
+}
+
+func validate(c *ProviderConfig) error {
+	if c.Name == "" || len(c.Name) > 64 || strings.ContainsAny(c.Name, "/?#% ") {
+		return errors.New("name must be 1 to 64 characters without spaces or URL delimiters")
+	}
+	if c.ClientID == "" {
+		return errors.New("client_id is required")
+	}
+	if err := checkEndpoint(c.Issuer); err != nil {
+		return errors.New("issuer " + err.Error())
+	}
+	if _, err := url.ParseRequestURI(c.RedirectURI); err != nil {
+		return errors.New("redirect_uri must be an absolute URL")
+	}
+	if c.DisplayName == "" {
+		c.DisplayName = c.Name
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !contains(c.Scopes, "openid") {
+		c.Scopes = append([]string{"openid"}, c.Scopes...)
+	}
+	return nil
+}
+
+// checkEndpoint requires https, except on loopback hosts so a local stand-in
+// issuer can be used for development and testing.
+func checkEndpoint(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("must be an absolute URL")
+	}
+	if u.Scheme == "https" {
+		return nil
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); u.Scheme == "http" && (host == "localhost" || (ip != nil && ip.IsLoopback())) {
+		return nil
+	}
+	return errors.New("must use https")
+}
+
+// Get returns the provider configured under name.
+func Get(name string) (*Provider, bool) {
+	p, ok := byName[name]
+	return p, ok
+}
+
+// List returns the configured providers in configuration order.
+func List() []*Provider {
+	return providers
+}
+
+// AuthCodeURL returns the provider's authorization URL for a new login. The
+// state, nonce and PKCE verifier must be kept server side until the user comes
+// back.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURI)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns the
+// claims of the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURI},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return nil, errors.New("invalid token response: " + err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("token endpoint refused the code: " + token.Error + " " + token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.verifyIDToken(ctx, token.IDToken, nonce)
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.Issuer {
+		return nil, errors.New("discovery document is for issuer " + d.Issuer)
+	}
+	for _, endpoint := range []string{d.AuthorizationEndpoint, d.TokenEndpoint, d.JwksURI} {
+		if err := checkEndpoint(endpoint); err != nil {
+			return nil, errors.New("discovery endpoint " + endpoint + " " + err.Error())
+		}
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("GET " + u + ": " + resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// apiKeyTouchInterval limits how often last-used details are written for a key
+// that is called repeatedly.
+const apiKeyTouchInterval = 60
+
+type ApiKeyRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewApiKeyRepo(db *sqlx.DB) *ApiKeyRepo {
+	repo := &ApiKeyRepo{db: db}
+	repo.Columns = ExtractColumns[model.ApiKey]()
+	return repo
+}
+
+func (r *ApiKeyRepo) CreateKey(ctx context.Context, key *model.ApiKey) error {
+	query := fmt.Sprintf(
+		"INSERT INTO api_keys (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, key)
+	return err
+}
+
+func (r *ApiKeyRepo) GetKeysByUserSafe(ctx context.Context, userID int64) ([]model.ApiKey, error) {
+	keys := []model.ApiKey{}
+	query := fmt.Sprintf("SELECT %s FROM api_keys WHERE user_id = $1 ORDER BY created_at", r.SafeRaw)
+	err := r.db.SelectContext(ctx, &keys, query, userID)
+	return keys, err
+}
+
+func (r *ApiKeyRepo) CountKeysByUser(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM api_keys WHERE user_id = $1`, userID)
+	return count, err
+}
+
+// GetKeyByHash returns nil when no key matches.
+func (r *ApiKeyRepo) GetKeyByHash(ctx context.Context, keyHash string) (*model.ApiKey, error) {
+	var key model.ApiKey
+	query := fmt.Sprintf("SELECT %s FROM api_keys WHERE key_hash = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &key, query, keyHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// TouchKey records when and from where a key was last used, at most once per
+// apiKeyTouchInterval.
+func (r *ApiKeyRepo) TouchKey(ctx context.Context, id int64, ip string) error {
+	now := time.Now().UTC().Unix()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = $1,
+		    last_used_ip = $2
+		WHERE id = $3 AND (last_used_at < $4 OR last_used_ip != $2)
+	`, now, ip, id, now-apiKeyTouchInterval)
+	return err
+}
+
+func (r *ApiKeyRepo) DeleteKey(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type DataExportRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewDataExportRepo(db *sqlx.DB) *DataExportRepo {
+	repo := &DataExportRepo{db: db}
+	repo.Columns = ExtractColumns[model.DataExport]()
+	return repo
+}
+
+func (r *DataExportRepo) CreateExport(ctx context.Context, export *model.DataExport) error {
+	query := fmt.Sprintf(
+		"INSERT INTO data_exports (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, export)
+	return err
+}
+
+// GetExportByHash returns the export with the given token hash if it has not
+// expired at now.
+func (r *DataExportRepo) GetExportByHash(ctx context.Context, tokenHash string, now int64) (*model.DataExport, error) {
+	var export model.DataExport
+	query := fmt.Sprintf("SELECT %s FROM data_exports WHERE token_hash = $1 AND expires_at > $2", r.AllRaw)
+	err := r.db.GetContext(ctx, &export, query, tokenHash, now)
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (r *DataExportRepo) DeleteExpiredExports(ctx context.Context, now int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM data_exports WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
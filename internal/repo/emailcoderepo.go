@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type EmailCodeRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewEmailCodeRepo(db *sqlx.DB) *EmailCodeRepo {
+	repo := &EmailCodeRepo{db: db}
+	repo.Columns = ExtractColumns[model.EmailCode]()
+	return repo
+}
+
+// ReplaceCode stores the code, discarding the user's previous one for the same
+// purpose.
+func (r *EmailCodeRepo) ReplaceCode(ctx context.Context, code *model.EmailCode) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_codes WHERE user_id = $1 AND purpose = $2`, code.UserID, code.Purpose); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO email_codes (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	if _, err := tx.NamedExecContext(ctx, query, code); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *EmailCodeRepo) GetCode(ctx context.Context, userID int64, purpose model.EmailCodePurpose) (*model.EmailCode, error) {
+	var code model.EmailCode
+	query := fmt.Sprintf("SELECT %s FROM email_codes WHERE user_id = $1 AND purpose = $2", r.AllRaw)
+	err := r.db.GetContext(ctx, &code, query, userID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RecordAttempt counts an attempt at entering the code. It returns false once
+// maxAttempts have been made, so concurrent guesses cannot go past the limit.
+func (r *EmailCodeRepo) RecordAttempt(ctx context.Context, userID int64, purpose model.EmailCodePurpose, maxAttempts int) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE email_codes
+		SET attempts = attempts + 1
+		WHERE user_id = $1 AND purpose = $2 AND attempts < $3
+	`, userID, purpose, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ConsumeCode deletes the matching code. It returns false when no such code
+// exists, including when it was already consumed.
+func (r *EmailCodeRepo) ConsumeCode(ctx context.Context, userID int64, purpose model.EmailCodePurpose, codeHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM email_codes WHERE user_id = $1 AND purpose = $2 AND code_hash = $3`, userID, purpose, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *EmailCodeRepo) DeleteCode(ctx context.Context, userID int64, purpose model.EmailCodePurpose) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM email_codes WHERE user_id = $1 AND purpose = $2`, userID, purpose)
+	return err
+}
@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type IdentityRepo struct {
+	Columns
+	stateColumns Columns
+	db           *sqlx.DB
+}
+
+func NewIdentityRepo(db *sqlx.DB) *IdentityRepo {
+	repo := &IdentityRepo{db: db}
+	repo.Columns = ExtractColumns[model.Identity]()
+	repo.stateColumns = ExtractColumns[model.OidcState]()
+	return repo
+}
+
+func (r *IdentityRepo) CreateIdentity(ctx context.Context, identity *model.Identity) error {
+	query := fmt.Sprintf(
+		"INSERT INTO identities (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, identity)
+	return err
+}
+
+func (r *IdentityRepo) GetIdentity(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	var identity model.Identity
+	query := fmt.Sprintf("SELECT %s FROM identities WHERE provider = $1 AND subject = $2", r.AllRaw)
+	err := r.db.GetContext(ctx, &identity, query, provider, subject)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepo) GetIdentitiesByUser(ctx context.Context, userID int64) ([]model.Identity, error) {
+	identities := []model.Identity{}
+	query := fmt.Sprintf("SELECT %s FROM identities WHERE user_id = $1 ORDER BY linked_at", r.AllRaw)
+	err := r.db.SelectContext(ctx, &identities, query, userID)
+	return identities, err
+}
+
+// DeleteIdentity unlinks one of the user's identities. It returns false if no
+// identity with that id belongs to the user.
+func (r *IdentityRepo) DeleteIdentity(ctx context.Context, userID int64, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM identities WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// CreateState stores a pending login, clearing out expired ones on the way
+// since abandoned logins are never consumed.
+func (r *IdentityRepo) CreateState(ctx context.Context, state *model.OidcState) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oidc_states WHERE expires_at < $1`, time.Now().UTC().Unix()); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO oidc_states (%s) VALUES (%s)",
+		r.stateColumns.AllRaw,
+		r.stateColumns.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, state)
+	return err
+}
+
+// ConsumeState fetches and deletes a pending login so that each authorization
+// response can only be used once. Expired logins are treated as missing.
+func (r *IdentityRepo) ConsumeState(ctx context.Context, id string) (*model.OidcState, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var state model.OidcState
+	query := fmt.Sprintf("SELECT %s FROM oidc_states WHERE id = $1 AND expires_at > $2", r.stateColumns.AllRaw)
+	if err := tx.GetContext(ctx, &state, query, id, time.Now().UTC().Unix()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM oidc_states WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		tx.Rollback()
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &state, tx.Commit()
+}
@@ -96,3 +96,29 @@ func (r *LockoutRepo) CountRecentFailures(ctx context.Context, userID int64, ipA
 	`, userID, ipAddress, ago)
 	return count, err
 }
+
+// GetLockedIPs lists every address the user has an active lockout or failed
+// login count from.
+func (r *LockoutRepo) GetLockedIPs(ctx context.Context, userID int64) ([]string, error) {
+	ips := []string{}
+	err := r.db.SelectContext(ctx, &ips, `
+		SELECT ip_address FROM lockouts WHERE user_id = $1 AND active = TRUE
+		UNION
+		SELECT ip_address FROM failed_logins WHERE user_id = $1 AND active = TRUE
+	`, userID)
+	return ips, err
+}
+
+func (r *LockoutRepo) GetFailedLoginsByUser(ctx context.Context, userID int64) ([]model.FailedLogin, error) {
+	attempts := []model.FailedLogin{}
+	query := fmt.Sprintf("SELECT %s FROM failed_logins WHERE user_id = $1 ORDER BY attempted_at DESC", r.attemptColumns.AllRaw)
+	err := r.db.SelectContext(ctx, &attempts, query, userID)
+	return attempts, err
+}
+
+func (r *LockoutRepo) GetLockoutsByUser(ctx context.Context, userID int64) ([]model.Lockout, error) {
+	lockouts := []model.Lockout{}
+	query := fmt.Sprintf("SELECT %s FROM lockouts WHERE user_id = $1 ORDER BY locked_until DESC", r.lockoutColumns.AllRaw)
+	err := r.db.SelectContext(ctx, &lockouts, query, userID)
+	return lockouts, err
+}
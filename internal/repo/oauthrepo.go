@@ -0,0 +1,256 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type OAuthRepo struct {
+	Columns
+	codeColumns    Columns
+	consentColumns Columns
+	deviceColumns  Columns
+	db             *sqlx.DB
+}
+
+func NewOAuthRepo(db *sqlx.DB) *OAuthRepo {
+	repo := &OAuthRepo{db: db}
+	repo.Columns = ExtractColumns[model.OAuthClient]()
+	repo.codeColumns = ExtractColumns[model.OAuthCode]()
+	repo.consentColumns = ExtractColumns[model.OAuthConsent]()
+	repo.deviceColumns = ExtractColumns[model.OAuthDeviceCode]()
+	return repo
+}
+
+func (r *OAuthRepo) CreateClient(ctx context.Context, client *model.OAuthClient) error {
+	query := fmt.Sprintf(
+		"INSERT INTO oauth_clients (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, client)
+	return err
+}
+
+// GetClient returns nil when no client has that id.
+func (r *OAuthRepo) GetClient(ctx context.Context, id string) (*model.OAuthClient, error) {
+	var client model.OAuthClient
+	query := fmt.Sprintf("SELECT %s FROM oauth_clients WHERE id = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &client, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *OAuthRepo) GetClients(ctx context.Context) ([]model.OAuthClient, error) {
+	clients := []model.OAuthClient{}
+	query := fmt.Sprintf("SELECT %s FROM oauth_clients ORDER BY created_at", r.AllRaw)
+	err := r.db.SelectContext(ctx, &clients, query)
+	return clients, err
+}
+
+// DeleteClient removes the client along with its pending codes, device
+// authorizations and the consents users gave it. It returns false if there is no such client.
+func (r *OAuthRepo) DeleteClient(ctx context.Context, id string) (bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	for _, table := range []string{"oauth_codes", "oauth_device_codes", "oauth_consents"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE client_id = $1", id); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// CreateCode stores an authorization code, clearing out expired ones on the
+// way since clients do not always redeem them.
+func (r *OAuthRepo) CreateCode(ctx context.Context, code *model.OAuthCode) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at < $1`, time.Now().UTC().Unix()); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO oauth_codes (%s) VALUES (%s)",
+		r.codeColumns.AllRaw,
+		r.codeColumns.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, code)
+	return err
+}
+
+// ConsumeCode fetches and deletes an authorization code so it can only be
+// exchanged once. Expired codes are treated as missing.
+func (r *OAuthRepo) ConsumeCode(ctx context.Context, codeHash string) (*model.OAuthCode, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var code model.OAuthCode
+	query := fmt.Sprintf("SELECT %s FROM oauth_codes WHERE code_hash = $1 AND expires_at > $2", r.codeColumns.AllRaw)
+	if err := tx.GetContext(ctx, &code, query, codeHash, time.Now().UTC().Unix()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM oauth_codes WHERE code_hash = $1`, codeHash)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		tx.Rollback()
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &code, tx.Commit()
+}
+
+// GetConsent returns nil when the user never allowed the client anything.
+func (r *OAuthRepo) GetConsent(ctx context.Context, userID int64, clientID string) (*model.OAuthConsent, error) {
+	var consent model.OAuthConsent
+	query := fmt.Sprintf("SELECT %s FROM oauth_consents WHERE user_id = $1 AND client_id = $2", r.consentColumns.AllRaw)
+	err := r.db.GetContext(ctx, &consent, query, userID, clientID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SaveConsent records the scopes the user allowed the client, replacing what
+// they allowed before.
+func (r *OAuthRepo) SaveConsent(ctx context.Context, consent *model.OAuthConsent) error {
+	query := fmt.Sprintf(`
+		INSERT INTO oauth_consents (%s) VALUES (%s)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scope = excluded.scope,
+		    granted_at = excluded.granted_at
+	`, r.consentColumns.AllRaw, r.consentColumns.AllPrefixed)
+	_, err := r.db.NamedExecContext(ctx, query, consent)
+	return err
+}
+
+// CreateDeviceCode stores a device authorization request. Requests expired
+// for over a day are cleared out on the way; younger ones are kept so devices
+// still polling learn that theirs expired.
+func (r *OAuthRepo) CreateDeviceCode(ctx context.Context, code *model.OAuthDeviceCode) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM oauth_device_codes WHERE expires_at < $1`, time.Now().UTC().Unix()-86400); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO oauth_device_codes (%s) VALUES (%s)",
+		r.deviceColumns.AllRaw,
+		r.deviceColumns.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, code)
+	return err
+}
+
+// GetDeviceCode returns nil when no request has that device code.
+func (r *OAuthRepo) GetDeviceCode(ctx context.Context, deviceCodeHash string) (*model.OAuthDeviceCode, error) {
+	var code model.OAuthDeviceCode
+	query := fmt.Sprintf("SELECT %s FROM oauth_device_codes WHERE device_code_hash = $1", r.deviceColumns.AllRaw)
+	err := r.db.GetContext(ctx, &code, query, deviceCodeHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// GetPendingDeviceCode returns the unexpired request waiting for an answer
+// with that user code, or nil when there is none.
+func (r *OAuthRepo) GetPendingDeviceCode(ctx context.Context, userCodeHash string) (*model.OAuthDeviceCode, error) {
+	var code model.OAuthDeviceCode
+	query := fmt.Sprintf(
+		"SELECT %s FROM oauth_device_codes WHERE user_code_hash = $1 AND status = $2 AND expires_at > $3",
+		r.deviceColumns.AllRaw,
+	)
+	err := r.db.GetContext(ctx, &code, query, userCodeHash, model.DeviceCodePending, time.Now().UTC().Unix())
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// AnswerDeviceCode records the user's answer to a pending request. It returns
+// false if the request was already answered or has expired.
+func (r *OAuthRepo) AnswerDeviceCode(ctx context.Context, userCodeHash string, userID int64, status model.DeviceCodeStatus) (bool, error) {
+	query := `
+		UPDATE oauth_device_codes
+		SET status = $1,
+		    user_id = $2
+		WHERE user_code_hash = $3 AND status = $4 AND expires_at > $5
+	`
+	res, err := r.db.ExecContext(ctx, query, status, userID, userCodeHash, model.DeviceCodePending, time.Now().UTC().Unix())
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RecordDevicePoll stores when the device last polled and the interval it must
+// now wait, which grows when it polls too fast.
+func (r *OAuthRepo) RecordDevicePoll(ctx context.Context, deviceCodeHash string, polledAt, interval int64) error {
+	query := `
+		UPDATE oauth_device_codes
+		SET last_polled_at = $1,
+		    poll_interval = $2
+		WHERE device_code_hash = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, polledAt, interval, deviceCodeHash)
+	return err
+}
+
+// DeleteDeviceCode removes a request once the device has its answer. It
+// returns false if it was already gone, so concurrent polls cannot both be
+// issued tokens.
+func (r *OAuthRepo) DeleteDeviceCode(ctx context.Context, deviceCodeHash string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM oauth_device_codes WHERE device_code_hash = $1`, deviceCodeHash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type PasskeyRepo struct {
+	Columns
+	sessionColumns Columns
+	db             *sqlx.DB
+}
+
+func NewPasskeyRepo(db *sqlx.DB) *PasskeyRepo {
+	repo := &PasskeyRepo{db: db}
+	repo.Columns = ExtractColumns[model.WebAuthnCredential]()
+	repo.sessionColumns = ExtractColumns[model.WebAuthnSession]()
+	return repo
+}
+
+func (r *PasskeyRepo) CreateCredential(ctx context.Context, credential *model.WebAuthnCredential) error {
+	query := fmt.Sprintf(
+		"INSERT INTO webauthn_credentials (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, credential)
+	return err
+}
+
+func (r *PasskeyRepo) GetCredentialsByUser(ctx context.Context, userID int64) ([]model.WebAuthnCredential, error) {
+	var credentials []model.WebAuthnCredential
+	query := fmt.Sprintf("SELECT %s FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at", r.AllRaw)
+	err := r.db.SelectContext(ctx, &credentials, query, userID)
+	return credentials, err
+}
+
+func (r *PasskeyRepo) GetCredentialsByUserSafe(ctx context.Context, userID int64) ([]model.WebAuthnCredential, error) {
+	credentials := []model.WebAuthnCredential{}
+	query := fmt.Sprintf("SELECT %s FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at", r.SafeRaw)
+	err := r.db.SelectContext(ctx, &credentials, query, userID)
+	return credentials, err
+}
+
+func (r *PasskeyRepo) GetCredentialByCredentialID(ctx context.Context, credentialID string) (*model.WebAuthnCredential, error) {
+	var credential model.WebAuthnCredential
+	query := fmt.Sprintf("SELECT %s FROM webauthn_credentials WHERE credential_id = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &credential, query, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *PasskeyRepo) UpdateCredentialUsage(ctx context.Context, id int64, credential string, signCount int64) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET credential = $1,
+		    sign_count = $2,
+		    last_used_at = $3
+		WHERE id = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, credential, signCount, time.Now().UTC().Unix(), id)
+	return err
+}
+
+// DeleteCredential removes one of the user's credentials. It returns false if
+// no credential with that id belongs to the user.
+func (r *PasskeyRepo) DeleteCredential(ctx context.Context, userID int64, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *PasskeyRepo) CreateSession(ctx context.Context, session *model.WebAuthnSession) error {
+	query := fmt.Sprintf(
+		"INSERT INTO webauthn_sessions (%s) VALUES (%s)",
+		r.sessionColumns.AllRaw,
+		r.sessionColumns.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, session)
+	return err
+}
+
+// ConsumeSession fetches and deletes a pending ceremony so that each challenge
+// can only be answered once. Expired sessions are treated as missing.
+func (r *PasskeyRepo) ConsumeSession(ctx context.Context, id string, ceremony model.WebAuthnCeremony) (*model.WebAuthnSession, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var session model.WebAuthnSession
+	query := fmt.Sprintf("SELECT %s FROM webauthn_sessions WHERE id = $1 AND ceremony = $2 AND expires_at > $3", r.sessionColumns.AllRaw)
+	if err := tx.GetContext(ctx, &session, query, id, ceremony, time.Now().UTC().Unix()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM webauthn_sessions WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		tx.Rollback()
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return nil, err
+	}
+
+	return &session, tx.Commit()
+}
+
+func (r *PasskeyRepo) CleanupSessions(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM webauthn_sessions WHERE expires_at < $1`, time.Now().UTC().Unix())
+	return err
+}
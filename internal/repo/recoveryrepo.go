@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type RecoveryRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewRecoveryRepo(db *sqlx.DB) *RecoveryRepo {
+	repo := &RecoveryRepo{db: db}
+	repo.Columns = ExtractColumns[model.RecoveryCode]()
+	return repo
+}
+
+// ReplaceCodes discards every recovery code the user has, used or not, and
+// stores the given set in its place.
+func (r *RecoveryRepo) ReplaceCodes(ctx context.Context, userID int64, codes []model.RecoveryCode) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO recovery_codes (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	for _, code := range codes {
+		if _, err := tx.NamedExecContext(ctx, query, code); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeCode marks the matching unused code as used. It returns false when no
+// such code exists, including when it was already consumed.
+func (r *RecoveryRepo) ConsumeCode(ctx context.Context, userID int64, codeHash string, ip string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE recovery_codes
+		SET used_at = $1,
+		    used_ip = $2
+		WHERE user_id = $3 AND code_hash = $4 AND used_at = 0
+	`, time.Now().UTC().Unix(), ip, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *RecoveryRepo) CountUnused(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM recovery_codes
+		WHERE user_id = $1 AND used_at = 0
+	`, userID)
+	return count, err
+}
+
+func (r *RecoveryRepo) DeleteCodes(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
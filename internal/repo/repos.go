@@ -8,9 +8,19 @@ import (
 )
 
 type Repos struct {
-	User    *UserRepo
-	Token   *TokenRepo
-	Lockout *LockoutRepo
+	User     *UserRepo
+	Token    *TokenRepo
+	Lockout  *LockoutRepo
+	Recovery *RecoveryRepo
+	Passkey  *PasskeyRepo
+	Security *SecurityRepo
+	Session  *SessionRepo
+	ApiKey   *ApiKeyRepo
+	Role     *RoleRepo
+	Export   *DataExportRepo
+	Code     *EmailCodeRepo
+	Identity *IdentityRepo
+	OAuth    *OAuthRepo
 }
 
 type Columns struct {
@@ -24,9 +34,19 @@ type Columns struct {
 
 func NewRepos(db *sqlx.DB) *Repos {
 	return &Repos{
-		User:    NewUserRepo(db),
-		Token:   NewTokenRepo(db),
-		Lockout: NewLockoutRepo(db),
+		User:     NewUserRepo(db),
+		Token:    NewTokenRepo(db),
+		Lockout:  NewLockoutRepo(db),
+		Recovery: NewRecoveryRepo(db),
+		Passkey:  NewPasskeyRepo(db),
+		Security: NewSecurityRepo(db),
+		Session:  NewSessionRepo(db),
+		ApiKey:   NewApiKeyRepo(db),
+		Role:     NewRoleRepo(db),
+		Export:   NewDataExportRepo(db),
+		Code:     NewEmailCodeRepo(db),
+		Identity: NewIdentityRepo(db),
+		OAuth:    NewOAuthRepo(db),
 	}
 }
 
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type RoleRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewRoleRepo(db *sqlx.DB) *RoleRepo {
+	repo := &RoleRepo{db: db}
+	repo.Columns = ExtractColumns[model.Role]()
+	return repo
+}
+
+func (r *RoleRepo) GetRoles(ctx context.Context) ([]model.Role, error) {
+	roles := []model.Role{}
+	query := fmt.Sprintf("SELECT %s FROM roles ORDER BY name", r.AllRaw)
+	err := r.db.SelectContext(ctx, &roles, query)
+	return roles, err
+}
+
+func (r *RoleRepo) RoleExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM roles WHERE name=$1)", name)
+	return exists, err
+}
+
+func (r *RoleRepo) GetPermissions(ctx context.Context, role string) ([]string, error) {
+	perms := []string{}
+	err := r.db.SelectContext(ctx, &perms, `SELECT permission FROM role_permissions WHERE role_name = $1 ORDER BY permission`, role)
+	return perms, err
+}
+
+// GetAllPermissions maps every role that has permissions to them.
+func (r *RoleRepo) GetAllPermissions(ctx context.Context) (map[string][]string, error) {
+	var rows []struct {
+		Role       string `db:"role_name"`
+		Permission string `db:"permission"`
+	}
+	err := r.db.SelectContext(ctx, &rows, `SELECT role_name, permission FROM role_permissions ORDER BY role_name, permission`)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := map[string][]string{}
+	for _, row := range rows {
+		perms[row.Role] = append(perms[row.Role], row.Permission)
+	}
+	return perms, nil
+}
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type SecurityRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewSecurityRepo(db *sqlx.DB) *SecurityRepo {
+	repo := &SecurityRepo{db: db}
+	repo.Columns = ExtractColumns[model.SecurityEvent]()
+	return repo
+}
+
+func (r *SecurityRepo) LogEvent(ctx context.Context, event model.SecurityEvent) error {
+	query := fmt.Sprintf(
+		"INSERT INTO security_events (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, event)
+	return err
+}
+
+// GetEventsByUser returns the user's most recent events, newest first.
+func (r *SecurityRepo) GetEventsByUser(ctx context.Context, userID int64, limit int) ([]model.SecurityEvent, error) {
+	events := []model.SecurityEvent{}
+	query := fmt.Sprintf("SELECT %s FROM security_events WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2", r.AllRaw)
+	err := r.db.SelectContext(ctx, &events, query, userID, limit)
+	return events, err
+}
+
+// GetAllEventsByUser returns every event on the user's account, newest first.
+func (r *SecurityRepo) GetAllEventsByUser(ctx context.Context, userID int64) ([]model.SecurityEvent, error) {
+	events := []model.SecurityEvent{}
+	query := fmt.Sprintf("SELECT %s FROM security_events WHERE user_id = $1 ORDER BY created_at DESC, id DESC", r.AllRaw)
+	err := r.db.SelectContext(ctx, &events, query, userID)
+	return events, err
+}
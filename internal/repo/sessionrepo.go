@@ -0,0 +1,120 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+type SessionRepo struct {
+	Columns
+	db *sqlx.DB
+}
+
+func NewSessionRepo(db *sqlx.DB) *SessionRepo {
+	repo := &SessionRepo{db: db}
+	repo.Columns = ExtractColumns[model.Session]()
+	return repo
+}
+
+func (r *SessionRepo) CreateSession(ctx context.Context, session model.Session) error {
+	query := fmt.Sprintf(
+		"INSERT INTO sessions (%s) VALUES (%s)",
+		r.AllRaw,
+		r.AllPrefixed,
+	)
+	_, err := r.db.NamedExecContext(ctx, query, session)
+	return err
+}
+
+// GetActiveSessionsSafe lists the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func (r *SessionRepo) GetActiveSessionsSafe(ctx context.Context, userID int64) ([]model.Session, error) {
+	sessions := []model.Session{}
+	query := fmt.Sprintf(`
+		SELECT %s FROM sessions
+		WHERE user_id = $1 AND revoked = false AND expires_at > $2
+		ORDER BY last_seen_at DESC
+	`, r.SafeRaw)
+	err := r.db.SelectContext(ctx, &sessions, query, userID, time.Now().UTC().Unix())
+	return sessions, err
+}
+
+// GetSessionsByUser lists every session the user ever started, including
+// revoked and expired ones, newest first.
+func (r *SessionRepo) GetSessionsByUser(ctx context.Context, userID int64) ([]model.Session, error) {
+	sessions := []model.Session{}
+	query := fmt.Sprintf("SELECT %s FROM sessions WHERE user_id = $1 ORDER BY created_at DESC", r.AllRaw)
+	err := r.db.SelectContext(ctx, &sessions, query, userID)
+	return sessions, err
+}
+
+func (r *SessionRepo) IsSessionRevoked(ctx context.Context, id int64) (bool, error) {
+	var revoked bool
+	err := r.db.GetContext(ctx, &revoked, `
+		SELECT EXISTS(SELECT 1 FROM sessions WHERE id = $1 AND revoked = true)
+	`, id)
+	return revoked, err
+}
+
+// TouchSession records activity on a session when its tokens are refreshed.
+func (r *SessionRepo) TouchSession(ctx context.Context, id int64, ip string, expiresAt int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions
+		SET last_seen_at = $1,
+		    ip_address = $2,
+		    expires_at = $3
+		WHERE id = $4
+	`, time.Now().UTC().Unix(), ip, expiresAt, id)
+	return err
+}
+
+// RevokeSession revokes one of the user's sessions. It returns false when the
+// user has no such active session.
+func (r *SessionRepo) RevokeSession(ctx context.Context, userID, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked = true
+		WHERE id = $1 AND user_id = $2 AND revoked = false
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeOtherSessions revokes every session of the user except keepID and
+// returns how many were revoked.
+func (r *SessionRepo) RevokeOtherSessions(ctx context.Context, userID, keepID int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked = true
+		WHERE user_id = $1 AND id != $2 AND revoked = false
+	`, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *SessionRepo) RevokeAllSessions(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked = true
+		WHERE user_id = $1 AND revoked = false
+	`, userID)
+	return err
+}
+
+// RevokeClientSessions revokes every session authorized for an OAuth client,
+// of any user.
+func (r *SessionRepo) RevokeClientSessions(ctx context.Context, clientID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked = true
+		WHERE client_id = $1 AND revoked = false
+	`, clientID)
+	return err
+}
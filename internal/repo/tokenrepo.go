@@ -2,6 +2,8 @@ package repo
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/akramboussanni/gocode/internal/model"
 	"github.com/jmoiron/sqlx"
@@ -39,3 +41,72 @@ func (r *TokenRepo) CleanupTokens(ctx context.Context) error {
 	`)
 	return err
 }
+
+func (r *TokenRepo) CreateRefreshToken(ctx context.Context, token model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (jti, family_id, parent_jti, user_id, issued_at, expires_at, rotated_at, revoked)
+		VALUES (:jti, :family_id, :parent_jti, :user_id, :issued_at, :expires_at, :rotated_at, :revoked)
+	`
+	_, err := r.db.NamedExecContext(ctx, query, token)
+	return err
+}
+
+func (r *TokenRepo) GetRefreshToken(ctx context.Context, jti string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.GetContext(ctx, &token, `
+		SELECT jti, family_id, parent_jti, user_id, issued_at, expires_at, rotated_at, revoked
+		FROM refresh_tokens
+		WHERE jti = $1
+	`, jti)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks the token as used to mint its successor. It returns
+// false when the token was already rotated or its family revoked, meaning the
+// caller is replaying an old token.
+func (r *TokenRepo) RotateRefreshToken(ctx context.Context, jti string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET rotated_at = $1
+		WHERE jti = $2 AND rotated_at = 0 AND revoked = false
+	`, time.Now().UTC().Unix(), jti)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeTokenFamily revokes every refresh token in the family and blacklists
+// their JTIs, which also invalidates the session tokens issued alongside them.
+func (r *TokenRepo) RevokeTokenFamily(ctx context.Context, familyID int64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked = true WHERE family_id = $1
+	`, familyID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO jwt_blacklist (jti, user_id, expires_at)
+		SELECT jti, user_id, expires_at FROM refresh_tokens WHERE family_id = $1
+		ON CONFLICT(jti) DO NOTHING
+	`, familyID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -3,8 +3,12 @@ package repo
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/akramboussanni/gocode/internal/model"
+	"github.com/akramboussanni/gocode/internal/utils"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -20,6 +24,8 @@ func NewUserRepo(db *sqlx.DB) *UserRepo {
 }
 
 func (r *UserRepo) CreateUser(ctx context.Context, user *model.User) error {
+	user.EmailNormalized = utils.NormalizeEmail(user.Email)
+	user.UsernameNormalized = utils.NormalizeUsername(user.Username)
 	query := fmt.Sprintf(
 		"INSERT INTO users (%s) VALUES (%s)",
 		r.AllRaw,
@@ -43,31 +49,197 @@ func (r *UserRepo) GetUserByIDSafe(ctx context.Context, id int64) (*model.User,
 	return &user, err
 }
 
+// DuplicateName reports whether the username is taken once normalized, so
+// names differing only by case or Unicode form count as the same.
 func (r *UserRepo) DuplicateName(ctx context.Context, username string) (bool, error) {
 	var exists bool
-	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE username=$1)", username)
+	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE username_normalized = $1)", utils.NormalizeUsername(username))
 	return exists, err
 }
 
+// DuplicateEmail reports whether the email is taken once normalized.
 func (r *UserRepo) DuplicateEmail(ctx context.Context, email string) (bool, error) {
 	var exists bool
-	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE email=$1)", email)
+	err := r.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE email_normalized = $1)", utils.NormalizeEmail(email))
 	return exists, err
 }
 
+// GetUserByEmail looks the user up by normalized email. Accounts left without
+// a normalized email because they collide with an older one can still be
+// found by their exact email, which wins over the normalized match.
 func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	query := fmt.Sprintf("SELECT %s FROM users WHERE email=$1", r.AllRaw)
-	err := r.db.GetContext(ctx, &user, query, email)
+	query := fmt.Sprintf("SELECT %s FROM users WHERE email_normalized = $1 OR (email_normalized = '' AND email = $2) ORDER BY email = $2 DESC LIMIT 1", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, utils.NormalizeEmail(email), email)
 	return &user, err
 }
 
+// GetUserByUsername looks the user up by normalized username, like
+// GetUserByEmail.
+func (r *UserRepo) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE username_normalized = $1 OR (username_normalized = '' AND username = $2) ORDER BY username = $2 DESC LIMIT 1", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, utils.NormalizeUsername(username), username)
+	return &user, err
+}
+
+// GetIdentities returns the identifying columns of every user, for checking
+// normalized values against each other.
+func (r *UserRepo) GetIdentities(ctx context.Context) ([]model.User, error) {
+	var users []model.User
+	query := "SELECT id, username, email, created_at, email_normalized, username_normalized FROM users ORDER BY id"
+	err := r.db.SelectContext(ctx, &users, query)
+	return users, err
+}
+
+// SetNormalizedIdentity overwrites the user's normalized email and username.
+func (r *UserRepo) SetNormalizedIdentity(ctx context.Context, userID int64, email, username string) error {
+	query := `
+		UPDATE users
+		SET email_normalized = $1,
+		    username_normalized = $2
+		WHERE id = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, email, username, userID)
+	return err
+}
+
+// FillNormalizedIdentities sets the normalized email and username of accounts
+// missing them, such as those created before the columns existed, oldest
+// first. A value another account already holds is left empty, so colliding
+// accounts keep signing in by their exact email or username. It returns how
+// many accounts were updated.
+func (r *UserRepo) FillNormalizedIdentities(ctx context.Context) (int, error) {
+	var users []model.User
+	query := "SELECT id, username, email, created_at, email_normalized, username_normalized FROM users WHERE email_normalized = '' OR username_normalized = '' ORDER BY id"
+	if err := r.db.SelectContext(ctx, &users, query); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, user := range users {
+		email, username := user.EmailNormalized, user.UsernameNormalized
+		if email == "" {
+			taken, err := r.DuplicateEmail(ctx, user.Email)
+			if err != nil {
+				return updated, err
+			}
+			if !taken {
+				email = utils.NormalizeEmail(user.Email)
+			}
+		}
+		if username == "" {
+			taken, err := r.DuplicateName(ctx, user.Username)
+			if err != nil {
+				return updated, err
+			}
+			if !taken {
+				username = utils.NormalizeUsername(user.Username)
+			}
+		}
+
+		if email == user.EmailNormalized && username == user.UsernameNormalized {
+			continue
+		}
+		if err := r.SetNormalizedIdentity(ctx, user.ID, email, username); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// userTables lists every table holding rows keyed by user_id, which are
+// removed together with the user.
+var userTables = []string{
+	"failed_logins",
+	"lockouts",
+	"jwt_blacklist",
+	"refresh_tokens",
+	"sessions",
+	"recovery_codes",
+	"webauthn_credentials",
+	"webauthn_sessions",
+	"api_keys",
+	"security_events",
+	"data_exports",
+	"email_codes",
+	"identities",
+	"oidc_states",
+	"oauth_codes",
+	"oauth_device_codes",
+	"oauth_consents",
+}
+
+// DeleteUser permanently removes the user and all data tied to them.
 func (r *UserRepo) DeleteUser(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range userTables {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = $1", id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CountUserRecords returns how many rows across the user's data tables belong
+// to them, as a measure of how large their data export will be.
+func (r *UserRepo) CountUserRecords(ctx context.Context, id int64) (int, error) {
+	total := 0
+	for _, table := range userTables {
+		var count int
+		if err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM "+table+" WHERE user_id = $1", id); err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// ScheduleDeletion marks the account for deletion at deleteAt. tokenHash lets
+// the user cancel until then.
+func (r *UserRepo) ScheduleDeletion(ctx context.Context, userID int64, tokenHash string, deleteAt int64) error {
+	query := `
+		UPDATE users
+		SET account_status = $1,
+		    status_reason = '',
+		    status_until = $2,
+		    deletion_cancel_token = $3
+		WHERE id = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, model.StatusPendingDeletion, deleteAt, tokenHash, userID)
 	return err
 }
 
+func (r *UserRepo) GetUserByDeletionToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE deletion_cancel_token = $1 AND account_status = $2", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash, model.StatusPendingDeletion)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUsersDueForDeletion returns the ids of accounts whose deletion grace
+// period ended before now.
+func (r *UserRepo) GetUsersDueForDeletion(ctx context.Context, now int64) ([]int64, error) {
+	ids := []int64{}
+	err := r.db.SelectContext(ctx, &ids, `SELECT id FROM users WHERE account_status = $1 AND status_until <= $2`, model.StatusPendingDeletion, now)
+	return ids, err
+}
+
 func (r *UserRepo) GetUserByConfirmationToken(ctx context.Context, tokenHash string) (*model.User, error) {
 	var user model.User
 	query := fmt.Sprintf("SELECT %s FROM users WHERE email_confirm_token = $1", r.AllRaw)
@@ -89,6 +261,92 @@ func (r *UserRepo) AssignUserConfirmToken(ctx context.Context, token string, iat
 	return err
 }
 
+// UpdateProfile saves the user's editable profile fields.
+func (r *UserRepo) UpdateProfile(ctx context.Context, user *model.User) error {
+	user.UsernameNormalized = utils.NormalizeUsername(user.Username)
+	query := `
+		UPDATE users
+		SET username = :username,
+		    username_normalized = :username_normalized,
+		    display_name = :display_name,
+		    locale = :locale,
+		    timezone = :timezone,
+		    avatar_url = :avatar_url,
+		    metadata = :metadata,
+		    username_changed_at = :username_changed_at
+		WHERE id = :id
+	`
+	_, err := r.db.NamedExecContext(ctx, query, user)
+	return err
+}
+
+// SetPendingEmail records a requested email change, replacing any earlier one.
+// tokenHash confirms it from the new address and cancelHash cancels it from
+// the old one.
+func (r *UserRepo) SetPendingEmail(ctx context.Context, userID int64, email, tokenHash, cancelHash string, issuedAt int64) error {
+	query := `
+		UPDATE users
+		SET pending_email = $1,
+		    email_change_token = $2,
+		    email_change_cancel_token = $3,
+		    email_change_issuedat = $4
+		WHERE id = $5
+	`
+	_, err := r.db.ExecContext(ctx, query, email, tokenHash, cancelHash, issuedAt, userID)
+	return err
+}
+
+func (r *UserRepo) GetUserByEmailChangeToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE email_change_token = $1 AND pending_email <> ''", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) GetUserByEmailChangeCancelToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE email_change_cancel_token = $1 AND pending_email <> ''", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ConfirmEmailChange swaps in the pending email, which counts as confirmed
+// since the user proved they own it.
+func (r *UserRepo) ConfirmEmailChange(ctx context.Context, userID int64, email string) error {
+	query := `
+		UPDATE users
+		SET email = pending_email,
+		    email_normalized = $2,
+		    email_confirmed = TRUE,
+		    pending_email = '',
+		    email_change_token = '',
+		    email_change_cancel_token = '',
+		    email_change_issuedat = 0
+		WHERE id = $1 AND pending_email = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, userID, utils.NormalizeEmail(email), email)
+	return err
+}
+
+func (r *UserRepo) CancelEmailChange(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET pending_email = '',
+		    email_change_token = '',
+		    email_change_cancel_token = '',
+		    email_change_issuedat = 0
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
 func (r *UserRepo) MarkUserConfirmed(ctx context.Context, userID int64) error {
 	query := `
 		UPDATE users
@@ -112,6 +370,45 @@ func (r *UserRepo) AssignUserResetToken(ctx context.Context, token string, iat i
 	return err
 }
 
+// AssignMagicLinkToken stores a login link token, replacing any earlier one.
+func (r *UserRepo) AssignMagicLinkToken(ctx context.Context, token string, iat int64, userID int64) error {
+	query := `
+		UPDATE users
+		SET magic_link_token = $1,
+		    magic_link_issuedat = $2
+		WHERE id = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, token, iat, userID)
+	return err
+}
+
+func (r *UserRepo) GetUserByMagicLinkToken(ctx context.Context, tokenHash string) (*model.User, error) {
+	var user model.User
+	query := fmt.Sprintf("SELECT %s FROM users WHERE magic_link_token = $1", r.AllRaw)
+	err := r.db.GetContext(ctx, &user, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ConsumeMagicLinkToken clears the login link token, reporting false if it was
+// already used or replaced so the link only ever works once.
+func (r *UserRepo) ConsumeMagicLinkToken(ctx context.Context, userID int64, tokenHash string) (bool, error) {
+	query := `
+		UPDATE users
+		SET magic_link_token = '',
+		    magic_link_issuedat = 0
+		WHERE id = $1 AND magic_link_token = $2
+	`
+	res, err := r.db.ExecContext(ctx, query, userID, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
 func (r *UserRepo) GetUserByResetToken(ctx context.Context, tokenHash string) (*model.User, error) {
 	var user model.User
 	query := fmt.Sprintf("SELECT %s FROM users WHERE password_reset_token = $1", r.AllRaw)
@@ -143,3 +440,148 @@ func (r *UserRepo) ChangeJwtSessionID(ctx context.Context, userID int64, newID i
 	_, err := r.db.ExecContext(ctx, query, newID, userID)
 	return err
 }
+
+func (r *UserRepo) ChangeRole(ctx context.Context, userID int64, role string) error {
+	query := `
+		UPDATE users
+		SET user_role = $1
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, role, userID)
+	return err
+}
+
+// SuspendUser blocks the account until the given time, or indefinitely when
+// until is 0.
+func (r *UserRepo) SuspendUser(ctx context.Context, userID int64, reason string, until int64) error {
+	query := `
+		UPDATE users
+		SET account_status = $1,
+		    status_reason = $2,
+		    status_until = $3
+		WHERE id = $4
+	`
+	_, err := r.db.ExecContext(ctx, query, model.StatusSuspended, reason, until, userID)
+	return err
+}
+
+// ReactivateUser clears any suspension or pending deletion.
+func (r *UserRepo) ReactivateUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET account_status = $1,
+		    status_reason = '',
+		    status_until = 0,
+		    deletion_cancel_token = ''
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, model.StatusActive, userID)
+	return err
+}
+
+func (r *UserRepo) SetPendingTotpSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		UPDATE users
+		SET totp_pending_secret = $1
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, secret, userID)
+	return err
+}
+
+func (r *UserRepo) ActivateTotp(ctx context.Context, userID int64, step int64) error {
+	query := `
+		UPDATE users
+		SET totp_secret = totp_pending_secret,
+		    totp_pending_secret = '',
+		    totp_enabled = TRUE,
+		    totp_last_step = $1
+		WHERE id = $2 AND totp_pending_secret <> ''
+	`
+	_, err := r.db.ExecContext(ctx, query, step, userID)
+	return err
+}
+
+func (r *UserRepo) DisableTotp(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users
+		SET totp_secret = '',
+		    totp_pending_secret = '',
+		    totp_enabled = FALSE,
+		    totp_last_step = 0
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// ConsumeTotpStep records step as the last accepted TOTP step. It returns false
+// if the step (or a later one) was already used, so codes cannot be replayed.
+func (r *UserRepo) ConsumeTotpStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := `
+		UPDATE users
+		SET totp_last_step = $1
+		WHERE id = $2 AND totp_last_step < $1
+	`
+	res, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// UserFilter narrows ListUsers. Zero values match everything.
+type UserFilter struct {
+	Search         string // case-insensitive substring of username or email
+	Role           string
+	EmailConfirmed *bool
+	Suspended      *bool
+}
+
+// ListUsers returns one page of users matching filter, newest first, along with
+// the total number of matches.
+func (r *UserRepo) ListUsers(ctx context.Context, filter UserFilter, limit, offset int) ([]model.User, int, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.Search != "" {
+		// % and _ typed in the search are literal characters, not wildcards
+		search := likeEscaper.Replace(strings.ToLower(filter.Search))
+		add(`(LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\')`, "%"+search+"%")
+	}
+	if filter.Role != "" {
+		add("user_role = ?", filter.Role)
+	}
+	if filter.EmailConfirmed != nil {
+		add("email_confirmed = ?", *filter.EmailConfirmed)
+	}
+	if filter.Suspended != nil {
+		cond := "(account_status = 'suspended' AND (status_until = 0 OR status_until > ?))"
+		if !*filter.Suspended {
+			cond = "NOT " + cond
+		}
+		add(cond, time.Now().UTC().Unix())
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM users"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	users := []model.User{}
+	query := fmt.Sprintf("SELECT %s FROM users%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", r.AllRaw, where, len(args)+1, len(args)+2)
+	err := r.db.SelectContext(ctx, &users, query, append(args, limit, offset)...)
+	return users, total, err
+}
@@ -8,9 +8,42 @@ import (
 
 type contextKey string
 
-const UserKey contextKey = "user"
+const (
+	UserKey    contextKey = "user"
+	SessionKey contextKey = "session"
+	ApiKeyKey  contextKey = "apikey"
+	PermsKey   contextKey = "permissions"
+	ScopeKey   contextKey = "scope"
+)
 
 func UserFromContext(ctx context.Context) (*model.User, bool) {
 	user, ok := ctx.Value(UserKey).(*model.User)
 	return user, ok
 }
+
+// SessionFromContext returns the ID of the session the request was authenticated
+// with. Tokens issued before sessions were tracked have none.
+func SessionFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(SessionKey).(int64)
+	return id, ok && id != 0
+}
+
+// PermissionsFromContext returns the permissions the request's token carries.
+func PermissionsFromContext(ctx context.Context) []string {
+	perms, _ := ctx.Value(PermsKey).([]string)
+	return perms
+}
+
+// ApiKeyFromContext returns the API key the request was authenticated with, if
+// it was not made with a session token.
+func ApiKeyFromContext(ctx context.Context) (*model.ApiKey, bool) {
+	key, ok := ctx.Value(ApiKeyKey).(*model.ApiKey)
+	return key, ok
+}
+
+// ScopeFromContext returns the space separated scopes granted to the OAuth
+// client the request's access token was issued to.
+func ScopeFromContext(ctx context.Context) string {
+	scope, _ := ctx.Value(ScopeKey).(string)
+	return scope
+}
@@ -39,4 +39,31 @@ func ClearRefreshCookie(w http.ResponseWriter) {
 func ClearAllCookies(w http.ResponseWriter) {
 	ClearSessionCookie(w)
 	ClearRefreshCookie(w)
+	ClearMfaCookie(w)
+	ClearPasskeyCookie(w)
+	ClearOidcCookie(w)
+}
+
+func SetMfaCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, cookieOp("mfa", token, "/auth/mfa", int(config.App.JwtExpirations[string(model.MfaPendingJwt)])))
+}
+
+func ClearMfaCookie(w http.ResponseWriter) {
+	http.SetCookie(w, cookieOp("mfa", "", "/auth/mfa", -1))
+}
+
+func SetPasskeyCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, cookieOp("passkey", token, "/auth/passkeys", int(config.App.WebAuthnTimeout)))
+}
+
+func ClearPasskeyCookie(w http.ResponseWriter) {
+	http.SetCookie(w, cookieOp("passkey", "", "/auth/passkeys", -1))
+}
+
+func SetOidcCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, cookieOp("oidc", token, "/auth/oidc", int(config.App.OidcStateTimeout)))
+}
+
+func ClearOidcCookie(w http.ResponseWriter) {
+	http.SetCookie(w, cookieOp("oidc", "", "/auth/oidc", -1))
 }
@@ -4,8 +4,12 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
 
 	"github.com/akramboussanni/gocode/config"
 	"github.com/akramboussanni/gocode/internal/model"
@@ -23,7 +27,12 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), err
 }
 
+// ComparePassword reports whether plain matches the stored hash. Accounts
+// without a password never match.
 func ComparePassword(hashed, plain string) bool {
+	if hashed == "" {
+		return false
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
 	return err == nil
 }
@@ -53,3 +62,124 @@ func GetRandomToken(bytes uint8) (*model.Token, error) {
 		Hash: base64.URLEncoding.EncodeToString(hashed[:]),
 	}, err
 }
+
+// HashToken hashes a raw token produced by GetRandomToken, yielding the value
+// stored in the database.
+func HashToken(raw string) (string, error) {
+	b, err := base64.URLEncoding.DecodeString(raw)
+	if err != nil {
+		return "", err
+	}
+
+	hashed := sha256.Sum256(b)
+	return base64.URLEncoding.EncodeToString(hashed[:]), nil
+}
+
+// ApiKeyPrefix starts every API key so they are easy to tell apart from JWTs
+// and to spot when leaked.
+const ApiKeyPrefix = "gc_"
+
+// GetRandomApiKey returns a new API key along with its hash. Only the hash
+// should be persisted.
+func GetRandomApiKey() (*model.Token, error) {
+	b, err := GenerateRandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := ApiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return &model.Token{
+		Raw:  raw,
+		Hash: HashApiKey(raw),
+	}, nil
+}
+
+func HashApiKey(key string) string {
+	hashed := sha256.Sum256([]byte(key))
+	return base64.URLEncoding.EncodeToString(hashed[:])
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GetRandomRecoveryCode returns a human-typeable single-use code such as
+// "k3x7q-9mzpa" along with its hash. Only the hash should be persisted.
+func GetRandomRecoveryCode() (*model.Token, error) {
+	b, err := GenerateRandomBytes(7)
+	if err != nil {
+		return nil, err
+	}
+
+	enc := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+
+	return &model.Token{
+		Raw:  enc[:5] + "-" + enc[5:],
+		Hash: HashRecoveryCode(enc),
+	}, nil
+}
+
+// HashRecoveryCode hashes a recovery code as typed by the user, ignoring case,
+// spaces and dashes. At 50 bits a code could be brute-forced from a plain hash,
+// so it is keyed with the code secret like email codes.
+func HashRecoveryCode(code string) string {
+	return hashCode(strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code)))
+}
+
+// GetRandomEmailCode returns a 6-digit code to be typed in from an email, along
+// with its hash. Only the hash should be persisted.
+func GetRandomEmailCode() (*model.Token, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return nil, err
+	}
+
+	code := fmt.Sprintf("%06d", n.Int64())
+	return &model.Token{
+		Raw:  code,
+		Hash: HashEmailCode(code),
+	}, nil
+}
+
+// HashEmailCode hashes an email code as typed by the user. With only a million
+// possible codes a plain hash is trivially reversed, so it is keyed with the
+// code secret.
+func HashEmailCode(code string) string {
+	return hashCode(strings.TrimSpace(code))
+}
+
+// userCodeAlphabet leaves out vowels, so user codes cannot spell words, and
+// letters easily mistaken for digits (RFC 8628 section 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// GetRandomUserCode returns a device flow user code such as "WDJB-MJHT" to be
+// typed in a browser, along with its hash. Only the hash should be persisted.
+func GetRandomUserCode() (*model.Token, error) {
+	code := make([]byte, 8)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeAlphabet))))
+		if err != nil {
+			return nil, err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+
+	raw := string(code[:4]) + "-" + string(code[4:])
+	return &model.Token{
+		Raw:  raw,
+		Hash: HashUserCode(raw),
+	}, nil
+}
+
+// HashUserCode hashes a user code as typed by the user, ignoring case, spaces
+// and dashes. Like email codes it is keyed with the code secret, since there
+// are few enough codes to try them all against a plain hash.
+func HashUserCode(code string) string {
+	return hashCode(strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code)))
+}
+
+// hashCode keys short codes with CODE_SECRET rather than JWT_SECRET, so
+// rotating the signing secret leaves stored codes working.
+func hashCode(normalized string) string {
+	h := hmac.New(sha256.New, config.CodeSecretBytes)
+	h.Write([]byte(normalized))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -8,6 +8,28 @@ import (
 	"github.com/akramboussanni/gocode/config"
 )
 
+// BearerToken returns the token from an "Authorization: Bearer" header, or an
+// empty string when there is none.
+func BearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(auth[7:])
+}
+
+// TokenFromRequest returns the bearer token if one was sent, falling back to the
+// named cookie for browser clients.
+func TokenFromRequest(r *http.Request, cookie string) string {
+	if token := BearerToken(r); token != "" {
+		return token
+	}
+	if c, err := r.Cookie(cookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
 func GetClientIP(r *http.Request) string {
 	if config.App.TrustIpHeaders {
 		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
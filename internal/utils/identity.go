@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeEmail returns the form emails are compared and stored for lookup
+// in, so addresses differing only by case belong to the same account.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CleanUsername applies Unicode NFKC to a username as typed, folding
+// compatibility forms such as fullwidth letters into their plain equivalents.
+// This is the form usernames are displayed and validated in.
+func CleanUsername(username string) string {
+	return norm.NFKC.String(strings.TrimSpace(username))
+}
+
+// NormalizeUsername returns the form usernames are compared and stored for
+// lookup in: cleaned and lowercased.
+func NormalizeUsername(username string) string {
+	return norm.NFKC.String(strings.ToLower(CleanUsername(username)))
+}
+
+// IsValidUsername checks a cleaned username: 3 to 30 letters, digits, dashes
+// and underscores, so usernames can never be mistaken for an email. Letters may
+// come from any script, but not from several at once and not only from ones
+// that imitate Latin letters, which rules out lookalikes of other names.
+func IsValidUsername(username string) bool {
+	n := utf8.RuneCountInString(username)
+	if n < 3 || n > 30 {
+		return false
+	}
+
+	for i, r := range username {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '_', r == '-':
+		case unicode.IsMark(r) && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return !hasMixedScripts(username) && !isLatinLookalike(username)
+}
+
+// hasMixedScripts reports whether s has letters from more than one script, the
+// usual trick behind names like "аdmin" with a Cyrillic "а".
+func hasMixedScripts(s string) bool {
+	var first *unicode.RangeTable
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		script := scriptOf(r)
+		if first == nil {
+			first = script
+		} else if script != first {
+			return true
+		}
+	}
+	return false
+}
+
+func scriptOf(r rune) *unicode.RangeTable {
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return table
+		}
+	}
+	return nil
+}
+
+// latinConfusables maps Cyrillic and Greek letters to the Latin letters they
+// are indistinguishable from in most fonts.
+var latinConfusables = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'һ': 'h', 'і': 'i', 'ј': 'j', 'к': 'k', 'м': 'm',
+	'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's',
+	'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ӏ': 'l',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y',
+}
+
+// isLatinLookalike reports whether every letter of a non-Latin username has a
+// Latin twin, meaning the whole name could pass for a Latin one.
+func isLatinLookalike(s string) bool {
+	letters := 0
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if _, ok := latinConfusables[r]; !ok {
+			return false
+		}
+		letters++
+	}
+	return letters > 0
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"alice", "alice"},
+		{"Alice", "alice"},
+		{"  ALICE  ", "alice"},
+		{"ａｌｉｃｅ", "alice"},     // fullwidth
+		{"ＡＬＩＣＥ", "alice"},     // fullwidth capitals
+		{"ﬁnn", "finn"},        // ligature
+		{"ｊｏｓｅ\u0301", "josé"}, // fullwidth with a combining accent
+		{"Jose\u0301", "josé"}, // decomposed accent composes
+		{"JOSÉ", "josé"},       // precomposed capital
+		{"user²", "user2"},     // superscript digit
+		{"Ⅻ_king", "xii_king"}, // roman numeral
+		{"ÉLODIE", "élodie"},   // non-ASCII capital
+		{"Иван", "иван"},       // Cyrillic
+		{"ΣΟΦΙΑ", "σοφια"},     // Greek
+		{"bob_the-builder", "bob_the-builder"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeUsername(tt.in); got != tt.want {
+			t.Errorf("NormalizeUsername(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeUsernameMatchesLookalikeForms(t *testing.T) {
+	forms := []string{"josé", "JOSÉ", "Jose\u0301", "ＪＯＳＥ\u0301", " josé "}
+	for _, form := range forms[1:] {
+		if NormalizeUsername(form) != NormalizeUsername(forms[0]) {
+			t.Errorf("%q and %q normalize differently", form, forms[0])
+		}
+	}
+}
+
+func TestIsValidUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     bool
+	}{
+		{"latin", "alice", true},
+		{"digits and separators", "bob_99-x", true},
+		{"digits only", "123", true},
+		{"accented", "élodie", true},
+		{"combining mark", "jose\u0301", true},
+		{"cyrillic", "иван", true},
+		{"greek", "ελενη", true},
+		{"han", "小明明", true},
+		{"fullwidth once cleaned", CleanUsername("ａｌｉｃｅ"), true},
+		{"too short", "ab", false},
+		{"too long", strings.Repeat("a", 31), false},
+		{"longest", strings.Repeat("a", 30), true},
+		{"email", "alice@example.com", false},
+		{"at sign", "ali@ce", false},
+		{"space", "alice smith", false},
+		{"dot", "alice.smith", false},
+		{"leading mark", "\u0301alice", false},
+		{"emoji", "alice🙂", false},
+		{"zero width joiner", "ali\u200dce", false},
+		{"cyrillic a in latin name", "аdmin", false},
+		{"greek o in latin name", "rοot", false},
+		{"latin and han", "bob小明", false},
+		{"all cyrillic lookalikes", "раура", false},
+		{"cyrillic lookalike of admin", "аԁмін", false},
+		{"all greek lookalikes", "οκτα", false},
+		{"capital cyrillic lookalikes", "РАУРА", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidUsername(tt.username); got != tt.want {
+				t.Errorf("IsValidUsername(%q) = %v, want %v", tt.username, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,14 @@
 package utils
 
-import "regexp"
+import (
+	"net/url"
+	"regexp"
+	"time"
+
+	// time zones are validated against the embedded database so it works on
+	// hosts without one installed
+	_ "time/tzdata"
+)
 
 var (
 	lowerRegex = regexp.MustCompile(`[a-z]`)
@@ -22,3 +30,28 @@ var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-
 func IsValidEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
+
+var localeRegex = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// IsValidLocale checks for a BCP 47 style language tag such as "en" or "pt-BR".
+func IsValidLocale(locale string) bool {
+	return len(locale) <= 35 && localeRegex.MatchString(locale)
+}
+
+// IsValidTimezone checks for an IANA time zone name such as "Europe/Paris".
+func IsValidTimezone(tz string) bool {
+	if tz == "" || tz == "Local" || len(tz) > 64 {
+		return false
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
+
+// IsValidAvatarURL accepts absolute http(s) URLs of reasonable length.
+func IsValidAvatarURL(raw string) bool {
+	if len(raw) > 2048 {
+		return false
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/repo"
+)
+
+// DeleteExpiredAccounts permanently deletes accounts whose deletion grace
+// period has ended, checking once at startup and then every interval. It
+// never returns and is meant to run in its own goroutine.
+func DeleteExpiredAccounts(ur *repo.UserRepo, interval time.Duration) {
+	deleteDueAccounts(ur)
+	for range time.Tick(interval) {
+		deleteDueAccounts(ur)
+	}
+}
+
+func deleteDueAccounts(ur *repo.UserRepo) {
+	ctx := context.Background()
+	ids, err := ur.GetUsersDueForDeletion(ctx, time.Now().UTC().Unix())
+	if err != nil {
+		applog.Error("Failed to list accounts due for deletion:", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := ur.DeleteUser(ctx, id); err != nil {
+			applog.Error("Failed to delete account", "userID:", id, "error:", err)
+			continue
+		}
+		applog.Info("Account deleted after grace period", "userID:", id)
+	}
+}
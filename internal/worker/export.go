@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/akramboussanni/gocode/internal/applog"
+	"github.com/akramboussanni/gocode/internal/repo"
+)
+
+// PurgeExpiredExports removes emailed data exports once their download link
+// has expired, every interval. It never returns and is meant to run in its own
+// goroutine.
+func PurgeExpiredExports(er *repo.DataExportRepo, interval time.Duration) {
+	for range time.Tick(interval) {
+		n, err := er.DeleteExpiredExports(context.Background(), time.Now().UTC().Unix())
+		if err != nil {
+			applog.Error("Failed to purge expired data exports:", err)
+			continue
+		}
+		if n > 0 {
+			applog.Info("Purged expired data exports", "count:", n)
+		}
+	}
+}